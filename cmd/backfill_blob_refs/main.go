@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+)
+
+// backfill_blob_refs registra en la tabla de referencias los blobs de los documentos
+// creados antes de ella y, al terminar, la marca como completa: desde entonces borrar un
+// documento ya no recorre la tabla de documentos para saber si sus blobs siguen en uso.
+// Se ejecuta después de desplegar las lambdas que mantienen las referencias.
+func main() {
+	table := flag.String("table", os.Getenv("TABLE_NAME"), "tabla DynamoDB de documentos")
+	refs := flag.String("refs", os.Getenv("BLOB_REF_TABLE_NAME"), "tabla DynamoDB de referencias de blobs")
+	dryRun := flag.Bool("dry-run", true, "solo cuenta los documentos sin escribir referencias")
+	flag.Parse()
+
+	logger.Init()
+	application.BLOB_REF_TABLE_NAME = *refs
+
+	if *table == "" || *refs == "" {
+		fmt.Fprintln(os.Stderr, "-table y -refs son obligatorios")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	if err := run(ctx, *table, *dryRun); err != nil {
+		slog.Error("backfill failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, table string, dryRun bool) error {
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, table, ctx)
+	documentos, err := dynamoService.GetAllDocuments()
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		slog.Info("backfill finished", "documentos", len(documentos), "dry_run", dryRun)
+		return nil
+	}
+
+	// FileChecksum cubre los documentos anteriores a los adjuntos
+	for _, documento := range documentos {
+		checksums := append(domain.Checksums(documento.Adjuntos), documento.FileChecksum)
+		if err := dynamoService.AddChecksumRefs(documento.Documento_ID, checksums); err != nil {
+			return err
+		}
+	}
+
+	// Solo con todos los documentos registrados un checksum sin referencias es fiable
+	if err := dynamoService.CompleteChecksumRefs(); err != nil {
+		return err
+	}
+
+	slog.Info("backfill finished", "documentos", len(documentos), "dry_run", dryRun)
+	return nil
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/google/uuid"
+)
+
+// import_documents carga documentos históricos desde un manifiesto CSV y un ZIP con sus
+// comprobantes. Cada fila se valida con las mismas reglas que create_document; las
+// válidas se guardan en lotes y el resultado de cada fila queda en el reporte.
+func main() {
+	table := flag.String("table", os.Getenv("TABLE_NAME"), "tabla DynamoDB de documentos")
+	bucket := flag.String("bucket", os.Getenv("BUCKET_NAME"), "bucket S3 de documentos")
+	prefix := flag.String("prefix", os.Getenv("BUCKET_KEY"), "prefijo de los documentos en el bucket")
+	queue := flag.String("queue", os.Getenv("SQS_NAME"), "cola SQS de sqs_consumer que analiza los archivos")
+	departamentos := flag.String("departamentos", os.Getenv("DEPARTAMENTO_TABLE_NAME"), "tabla de departamentos (opcional)")
+	residentes := flag.String("residentes", os.Getenv("RESIDENTE_TABLE_NAME"), "tabla de residentes (opcional)")
+	servicios := flag.String("servicios", os.Getenv("SERVICIO_TABLE_NAME"), "catálogo de servicios (opcional)")
+	refs := flag.String("refs", os.Getenv("BLOB_REF_TABLE_NAME"), "tabla de referencias de blobs; obligatoria si el stack la tiene")
+	manifestPath := flag.String("manifest", "", "manifiesto CSV con una fila por documento")
+	zipPath := flag.String("zip", "", "ZIP con los archivos referenciados en la columna archivo")
+	reportPath := flag.String("report", "", "reporte CSV con el resultado de cada fila (por defecto <manifest>.resultado.csv)")
+	resume := flag.String("resume", "", "reporte de una ejecución anterior: sus filas creadas no se vuelven a importar")
+	batchSize := flag.Int("batch", 25, "documentos por lote de escritura (máximo 25)")
+	dryRun := flag.Bool("dry-run", true, "solo valida las filas sin escribir en DynamoDB ni en S3")
+	flag.Parse()
+
+	logger.Init()
+	domain.BUCKET_NAME = *bucket
+	domain.BUCKET_KEY = *prefix
+	application.DEPARTAMENTO_TABLE_NAME = *departamentos
+	application.RESIDENTE_TABLE_NAME = *residentes
+	application.SERVICIO_TABLE_NAME = *servicios
+	application.BLOB_REF_TABLE_NAME = *refs
+
+	if *manifestPath == "" || *table == "" {
+		fmt.Fprintln(os.Stderr, "-manifest y -table son obligatorios")
+		flag.Usage()
+		os.Exit(2)
+	}
+	// Los archivos pasan por el antivirus igual que los subidos por la API
+	if *zipPath != "" && !*dryRun && (*queue == "" || *bucket == "") {
+		fmt.Fprintln(os.Stderr, "-queue y -bucket son obligatorios para importar archivos")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *reportPath == "" {
+		*reportPath = strings.TrimSuffix(*manifestPath, ".csv") + ".resultado.csv"
+	}
+
+	importer := &importer{
+		table:     *table,
+		queue:     *queue,
+		dryRun:    *dryRun,
+		batchSize: max(1, min(*batchSize, 25)),
+	}
+
+	ctx := context.Background()
+	if err := importer.run(ctx, *manifestPath, *zipPath, *reportPath, *resume); err != nil {
+		slog.Error("import failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// pendiente es un documento validado que espera su lote.
+type pendiente struct {
+	result    result
+	documento domain.Documento
+	archivos  []archivo
+	// enqueueOnly marca un documento que ya está en la tabla pero cuyos archivos no
+	// llegaron a la cola en la ejecución anterior
+	enqueueOnly bool
+}
+
+type importer struct {
+	table     string
+	queue     string
+	dryRun    bool
+	batchSize int
+
+	service *application.DocumentoServiceDynamo
+	report  *csv.Writer
+
+	pending []pendiente
+	// checksums del archivo principal importados en esta ejecución, que aún no están en
+	// la tabla cuando se valida el resto del manifiesto
+	checksums map[string]string
+
+	created, valid, failed, skipped int
+}
+
+func (imp *importer) run(ctx context.Context, manifestPath, zipPath, reportPath, resumePath string) error {
+	previous, err := loadResume(resumePath)
+	if err != nil {
+		return err
+	}
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		return err
+	}
+	imp.service = application.NewDocumentoServiceDynamo(dynamoClient, imp.table, ctx)
+
+	var files *archive
+	if zipPath != "" {
+		zipReader, err := zip.OpenReader(zipPath)
+		if err != nil {
+			return err
+		}
+		defer zipReader.Close()
+		files = openArchive(&zipReader.Reader)
+	}
+
+	manifestFile, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer manifestFile.Close()
+	rows, err := openManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+
+	reportFile, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer reportFile.Close()
+	imp.report = csv.NewWriter(reportFile)
+	if err := imp.report.Write(reportHeader); err != nil {
+		return err
+	}
+
+	imp.checksums = map[string]string{}
+	for {
+		r, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("manifiesto, línea %d: %w", rows.line, err)
+		}
+
+		// Las filas creadas en una ejecución anterior se copian al nuevo reporte
+		if done, ok := previous[r.line]; ok && done.huella == r.huella {
+			imp.skipped++
+			imp.write(done)
+			continue
+		}
+
+		// El id sale de la fila: si una ejecución interrumpida ya la guardó sin llegar a
+		// reportarlo, se reconoce aquí en lugar de crear el documento otra vez. Si sus
+		// archivos siguen sin analizar, solo se vuelven a encolar
+		id := importID(r)
+		existing, exists, err := imp.existing(id)
+		if err != nil {
+			return err
+		}
+		if exists && existing.EstadoArchivo != domain.EstadoArchivoPendiente {
+			imp.skipped++
+			imp.write(result{line: r.line, huella: r.huella, estado: estadoCreado, documentoID: id, archivo: r.value("archivo")})
+			continue
+		}
+
+		var item pendiente
+		if exists {
+			item, err = imp.requeue(r, files, existing)
+		} else {
+			item, err = imp.validate(r, files)
+		}
+		if err != nil {
+			imp.failed++
+			imp.write(result{line: r.line, huella: r.huella, estado: estadoError, archivo: r.value("archivo"), err: err})
+			continue
+		}
+
+		imp.pending = append(imp.pending, item)
+		if len(imp.pending) >= imp.batchSize {
+			if err := imp.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if err := imp.flush(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("import finished", "created", imp.created, "valid", imp.valid, "failed", imp.failed,
+		"skipped", imp.skipped, "report", reportPath, "dry_run", imp.dryRun)
+	return nil
+}
+
+// validate arma el documento de la fila con sus archivos, sin escribir nada.
+func (imp *importer) validate(r row, files *archive) (pendiente, error) {
+	req, err := r.request()
+	if err != nil {
+		return pendiente{}, err
+	}
+
+	var archivos []archivo
+	for _, name := range r.paths() {
+		if files == nil {
+			return pendiente{}, errors.New("la fila referencia archivos pero no se indicó -zip")
+		}
+		file, err := files.read(name)
+		if err != nil {
+			return pendiente{}, err
+		}
+		req.Adjuntos = append(req.Adjuntos, domain.NewAdjunto(file.FileName, file.ContentType, file.Data))
+		archivos = append(archivos, archivo{fileName: file.FileName, contentType: file.ContentType, data: file.Data})
+	}
+
+	documento, err := imp.service.PrepareDocument(req)
+	if err != nil {
+		return pendiente{}, err
+	}
+	documento.Documento_ID = importID(r)
+
+	// Un comprobante repetido dentro del mismo manifiesto sigue DUPLICATE_POLICY igual
+	// que uno ya registrado en la tabla; si la fila original falla, fail lo libera
+	if documento.FileChecksum != "" {
+		if original, ok := imp.checksums[documento.FileChecksum]; ok {
+			if application.DUPLICATE_POLICY == application.DuplicatePolicyReject {
+				return pendiente{}, &domain.DuplicateError{DocumentoID: original}
+			}
+			documento.DuplicadoDe = original
+		} else {
+			imp.checksums[documento.FileChecksum] = documento.Documento_ID
+		}
+	}
+
+	for i := range archivos {
+		archivos[i].key = documento.Adjuntos[i].Key
+	}
+
+	return pendiente{
+		result:    result{line: r.line, huella: r.huella, documentoID: documento.Documento_ID, archivo: r.value("archivo")},
+		documento: documento,
+		archivos:  archivos,
+	}, nil
+}
+
+// requeue arma la fila de un documento ya guardado cuyos archivos quedaron sin encolar:
+// se leen del ZIP y se asignan a las keys de los adjuntos guardados, sin validar de
+// nuevo el documento.
+func (imp *importer) requeue(r row, files *archive, existing domain.DocumentoResponse) (pendiente, error) {
+	paths := r.paths()
+	if files == nil || len(paths) != len(existing.Adjuntos) {
+		return pendiente{}, errors.New("los archivos de la fila no coinciden con los del documento ya importado")
+	}
+
+	archivos := make([]archivo, len(paths))
+	for i, name := range paths {
+		file, err := files.read(name)
+		if err != nil {
+			return pendiente{}, err
+		}
+		archivos[i] = archivo{key: existing.Adjuntos[i].Key, fileName: file.FileName, contentType: file.ContentType, data: file.Data}
+	}
+
+	return pendiente{
+		result:      result{line: r.line, huella: r.huella, documentoID: existing.Documento_ID, archivo: r.value("archivo")},
+		documento:   domain.Documento{Documento_ID: existing.Documento_ID, Adjuntos: existing.Adjuntos},
+		archivos:    archivos,
+		enqueueOnly: true,
+	}, nil
+}
+
+// flush deja los archivos del lote en incoming/, guarda sus documentos y después
+// encola el análisis de cada archivo, igual que create_document: sqs_consumer copia los
+// limpios a su key definitiva. El reporte se vuelca al terminar cada lote para que
+// -resume pueda retomar una ejecución interrumpida.
+func (imp *importer) flush(ctx context.Context) error {
+	if len(imp.pending) == 0 {
+		return nil
+	}
+	batch := imp.pending
+	imp.pending = nil
+
+	if imp.dryRun {
+		for _, item := range batch {
+			item.result.estado = estadoValido
+			imp.valid++
+			imp.write(item.result)
+		}
+		return imp.sync()
+	}
+
+	var documentos []domain.Documento
+	var ready []pendiente
+	for _, item := range batch {
+		if err := imp.stage(ctx, item.archivos); err != nil {
+			imp.fail(item, fmt.Errorf("no se pudo subir el archivo: %w", err))
+			continue
+		}
+		if !item.enqueueOnly {
+			documentos = append(documentos, item.documento)
+		}
+		ready = append(ready, item)
+	}
+
+	unprocessed, err := imp.service.BatchCreateDocuments(documentos)
+	if err != nil {
+		// Parte del lote pudo guardarse antes del error: se consulta cada documento para
+		// que el reporte refleje lo que quedó en la tabla, y se detiene
+		for _, item := range ready {
+			if _, exists, existsErr := imp.existing(item.documento.Documento_ID); existsErr == nil && exists {
+				imp.complete(ctx, item)
+				continue
+			}
+			imp.fail(item, err)
+		}
+		imp.sync()
+		return err
+	}
+
+	failed := map[string]bool{}
+	for _, id := range unprocessed {
+		failed[id] = true
+	}
+	for _, item := range ready {
+		if failed[item.documento.Documento_ID] {
+			imp.fail(item, errors.New("DynamoDB no procesó el documento después de varios reintentos"))
+			continue
+		}
+		imp.complete(ctx, item)
+	}
+	return imp.sync()
+}
+
+// complete encola el análisis de los archivos de un documento ya guardado y reporta la
+// fila. Si no se pudieron encolar todos, el documento nuevo se elimina para no dejarlo
+// esperando un análisis que nunca llega: sqs_consumer descarta los mensajes que sí
+// entraron y la fila puede importarse de nuevo.
+func (imp *importer) complete(ctx context.Context, item pendiente) {
+	messages := make([]infrastructure.FileMessage, len(item.archivos))
+	for i, file := range item.archivos {
+		messages[i] = infrastructure.NewFileMessage(item.documento.Documento_ID, file.fileName, file.contentType,
+			file.key, file.sourceKey)
+		messages[i].Principal = item.documento.Adjuntos[i].Principal
+	}
+
+	if err := infrastructure.SendFileMessages(ctx, imp.queue, messages); err != nil {
+		// Un documento que solo se reencolaba conserva sus archivos en incoming/: los
+		// mensajes que sí entraron los necesitan
+		if !item.enqueueOnly {
+			if _, deleteErr := imp.service.DeleteDocument(item.documento.Documento_ID); deleteErr != nil {
+				slog.Error("error rolling back documento", "id_documento", item.documento.Documento_ID, "error", deleteErr)
+			} else if discardErr := infrastructure.DiscardStagedFiles(ctx, domain.BUCKET_NAME, sourceKeys(item.archivos)); discardErr != nil {
+				slog.Warn("error discarding staged files", "id_documento", item.documento.Documento_ID, "error", discardErr)
+			}
+		}
+		imp.fail(item, fmt.Errorf("no se pudo encolar el análisis de los archivos: %w", err))
+		return
+	}
+
+	item.result.estado = estadoCreado
+	imp.created++
+	imp.write(item.result)
+}
+
+// fail reporta la fila como fallida. Si era la primera del manifiesto con su
+// comprobante, lo libera para que una fila posterior con el mismo archivo no quede como
+// duplicada de un documento que no llegó a crearse.
+func (imp *importer) fail(item pendiente, err error) {
+	if checksum := item.documento.FileChecksum; checksum != "" && imp.checksums[checksum] == item.documento.Documento_ID {
+		delete(imp.checksums, checksum)
+	}
+	item.result.estado = estadoError
+	item.result.err = err
+	imp.failed++
+	imp.write(item.result)
+}
+
+// existing busca el documento en la tabla; exists es false si no está.
+func (imp *importer) existing(id string) (documento domain.DocumentoResponse, exists bool, err error) {
+	documento, err = imp.service.GetDocument(id)
+	if errors.Is(err, domain.ErrDocumentoNotFound) {
+		return domain.DocumentoResponse{}, false, nil
+	}
+	if err != nil {
+		return domain.DocumentoResponse{}, false, err
+	}
+	return documento, true, nil
+}
+
+// importNamespace deriva los ids de los documentos importados.
+var importNamespace = uuid.NewSHA1(uuid.NameSpaceOID, []byte("import_documents"))
+
+// importID es el id del documento de una fila: depende solo de su número y su huella, así
+// volver a importar el mismo manifiesto reescribe los mismos documentos en lugar de
+// duplicarlos.
+func importID(r row) string {
+	return uuid.NewSHA1(importNamespace, []byte(fmt.Sprintf("%d:%s", r.line, r.huella))).String()
+}
+
+// stage deja los archivos del documento en incoming/ para que sqs_consumer los analice.
+func (imp *importer) stage(ctx context.Context, archivos []archivo) error {
+	for i, file := range archivos {
+		key, err := infrastructure.StageFile(ctx, domain.BUCKET_NAME, file.contentType, file.data)
+		if err != nil {
+			return err
+		}
+		archivos[i].sourceKey = key
+	}
+	return nil
+}
+
+func sourceKeys(archivos []archivo) []string {
+	keys := make([]string, len(archivos))
+	for i, file := range archivos {
+		keys[i] = file.sourceKey
+	}
+	return keys
+}
+
+func (imp *importer) write(r result) {
+	if r.err != nil {
+		slog.Warn("row failed", "fila", r.line, "error", r.err)
+	}
+	imp.report.Write(r.record())
+}
+
+func (imp *importer) sync() error {
+	imp.report.Flush()
+	return imp.report.Error()
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"main/src/domain"
+	"main/src/extraction"
+	"main/src/upload"
+)
+
+// Estados de cada fila en el reporte.
+const (
+	estadoCreado = "creado"
+	estadoValido = "valido"
+	estadoError  = "error"
+)
+
+// Columnas del manifiesto. archivo lista las rutas dentro del ZIP separadas por ";"; la
+// primera es el archivo principal del documento.
+var manifestColumns = []string{
+	"departamento", "residente", "id_residente", "fecha_de_pago", "tipo_de_servicio",
+	"estado_documento", "monto", "moneda", "tipo_de_cambio", "archivo",
+}
+
+var reportHeader = []string{"fila", "huella", "estado", "id_documento", "archivo", "error"}
+
+// row es una fila del manifiesto con sus valores por nombre de columna.
+type row struct {
+	line   int
+	values map[string]string
+	// huella identifica el contenido de la fila para que -resume no salte una fila que
+	// cambió desde la importación anterior.
+	huella string
+}
+
+func (r row) value(name string) string {
+	return strings.TrimSpace(r.values[name])
+}
+
+// archivo es un archivo del ZIP listo para subir con la key de su adjunto.
+type archivo struct {
+	key         string
+	fileName    string
+	contentType string
+	data        []byte
+	// sourceKey es la key en incoming/ donde el archivo espera su análisis
+	sourceKey string
+}
+
+// result es la línea del reporte de una fila.
+type result struct {
+	line        int
+	huella      string
+	estado      string
+	documentoID string
+	archivo     string
+	err         error
+}
+
+func (r result) record() []string {
+	message := ""
+	if r.err != nil {
+		message = r.err.Error()
+	}
+	return []string{strconv.Itoa(r.line), r.huella, r.estado, r.documentoID, r.archivo, message}
+}
+
+// manifest lee el CSV fila por fila, validando primero que tenga las columnas mínimas.
+type manifest struct {
+	reader  *csv.Reader
+	columns []string
+	line    int
+}
+
+func openManifest(r io.Reader) (*manifest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cabecera del manifiesto: %w", err)
+	}
+	columns := make([]string, len(header))
+	known := map[string]bool{}
+	for _, name := range manifestColumns {
+		known[name] = true
+	}
+	for i, name := range header {
+		columns[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[columns[i]] {
+			return nil, fmt.Errorf("columna desconocida en el manifiesto: %q", name)
+		}
+	}
+	for _, required := range []string{"fecha_de_pago", "tipo_de_servicio"} {
+		if !containsColumn(columns, required) {
+			return nil, fmt.Errorf("el manifiesto necesita la columna %s", required)
+		}
+	}
+	if !containsColumn(columns, "departamento") && !containsColumn(columns, "id_residente") {
+		return nil, errors.New("el manifiesto necesita la columna departamento o id_residente")
+	}
+
+	return &manifest{reader: reader, columns: columns, line: 1}, nil
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// next devuelve la siguiente fila no vacía; io.EOF al terminar.
+func (m *manifest) next() (row, error) {
+	for {
+		record, err := m.reader.Read()
+		m.line++
+		if err != nil {
+			return row{}, err
+		}
+
+		r := row{line: m.line, values: map[string]string{}}
+		empty := true
+		for i, value := range record {
+			if i < len(m.columns) {
+				r.values[m.columns[i]] = value
+			}
+			if strings.TrimSpace(value) != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(strings.Join(record, "\x1f")))
+		r.huella = hex.EncodeToString(sum[:8])
+		return r, nil
+	}
+}
+
+// request convierte la fila en la solicitud de creación. La fecha acepta día/mes/año o
+// ISO y el monto se escribe en decimal ("1,250.50"), como en la hoja de cálculo.
+func (r row) request() (domain.DocumentoRequest, error) {
+	fecha, ok := extraction.NormalizeFecha(r.value("fecha_de_pago"))
+	if !ok {
+		return domain.DocumentoRequest{}, fmt.Errorf("fecha_de_pago %q no es una fecha válida", r.value("fecha_de_pago"))
+	}
+	if r.value("tipo_de_servicio") == "" {
+		return domain.DocumentoRequest{}, errors.New("tipo_de_servicio es obligatorio")
+	}
+	if r.value("departamento") == "" && r.value("id_residente") == "" {
+		return domain.DocumentoRequest{}, errors.New("departamento o id_residente es obligatorio")
+	}
+
+	var monto int64
+	if value := r.value("monto"); value != "" {
+		normalized, ok := extraction.NormalizeMonto(value)
+		var err error
+		if ok {
+			monto, err = domain.ParseMontoDecimal(normalized)
+		}
+		if !ok || err != nil {
+			return domain.DocumentoRequest{}, fmt.Errorf("monto %q no es un importe válido", value)
+		}
+	}
+
+	return domain.DocumentoRequest{
+		Departamento:   r.value("departamento"),
+		Residente:      r.value("residente"),
+		ResidenteID:    r.value("id_residente"),
+		FechaDePago:    fecha,
+		TipoDeServicio: r.value("tipo_de_servicio"),
+		StateDocument:  r.value("estado_documento"),
+		Monto:          monto,
+		Moneda:         r.value("moneda"),
+		TipoCambio:     r.value("tipo_de_cambio"),
+	}, nil
+}
+
+// paths devuelve las rutas de archivo de la fila, sin vacías.
+func (r row) paths() []string {
+	var paths []string
+	for _, name := range strings.Split(r.value("archivo"), ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			paths = append(paths, name)
+		}
+	}
+	return paths
+}
+
+// archive busca los archivos del manifiesto dentro del ZIP: por ruta exacta o, si no
+// existe, por nombre de archivo cuando hay uno solo con ese nombre.
+type archive struct {
+	byPath map[string]*zip.File
+	byName map[string][]*zip.File
+	limit  int64
+}
+
+func openArchive(reader *zip.Reader) *archive {
+	a := &archive{
+		byPath: map[string]*zip.File{},
+		byName: map[string][]*zip.File{},
+		limit:  upload.DefaultLimits().MaxFileBytes,
+	}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		a.byPath[strings.ToLower(file.Name)] = file
+		name := strings.ToLower(path.Base(file.Name))
+		a.byName[name] = append(a.byName[name], file)
+	}
+	return a
+}
+
+func (a *archive) find(name string) (*zip.File, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.ReplaceAll(name, `\`, "/"), "./"))
+	if file, ok := a.byPath[name]; ok {
+		return file, nil
+	}
+	switch candidates := a.byName[path.Base(name)]; len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("el archivo %q no está en el ZIP", name)
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, fmt.Errorf("hay %d archivos %q en el ZIP, indique la ruta completa", len(candidates), path.Base(name))
+	}
+}
+
+// read abre el archivo y comprueba su tamaño y tipo con las mismas reglas que la subida
+// por la API.
+func (a *archive) read(name string) (upload.File, error) {
+	file, err := a.find(name)
+	if err != nil {
+		return upload.File{}, err
+	}
+	if int64(file.UncompressedSize64) > a.limit {
+		return upload.File{}, fmt.Errorf("el archivo %q supera el tamaño máximo de %d bytes", name, a.limit)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return upload.File{}, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, a.limit+1))
+	if err != nil {
+		return upload.File{}, fmt.Errorf("no se pudo leer %q: %w", name, err)
+	}
+	if int64(len(data)) > a.limit {
+		return upload.File{}, fmt.Errorf("el archivo %q supera el tamaño máximo de %d bytes", name, a.limit)
+	}
+
+	contentType := upload.DetectContentType(data)
+	if !upload.IsAllowed(contentType) {
+		return upload.File{}, fmt.Errorf("el archivo %q es de tipo %s, que no está permitido", name, contentType)
+	}
+	return upload.File{FileName: path.Base(file.Name), ContentType: contentType, Data: data}, nil
+}
+
+// loadResume lee un reporte anterior y devuelve las filas ya creadas por número de fila.
+func loadResume(name string) (map[int]result, error) {
+	created := map[int]result{}
+	if name == "" {
+		return created, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = len(reportHeader)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reporte %s: %w", name, err)
+	}
+	for _, record := range records[min(1, len(records)):] {
+		line, err := strconv.Atoi(record[0])
+		if err != nil || record[2] != estadoCreado {
+			continue
+		}
+		created[line] = result{line: line, huella: record[1], estado: estadoCreado, documentoID: record[3], archivo: record[4]}
+	}
+	return created, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// migrate_file_keys corrige los documentos creados antes de la política única de keys:
+// busca en el bucket el archivo principal real de cada documento y persiste su key y URL.
+func main() {
+	table := flag.String("table", os.Getenv("TABLE_NAME"), "tabla DynamoDB de documentos")
+	bucket := flag.String("bucket", os.Getenv("BUCKET_NAME"), "bucket S3 de documentos")
+	prefix := flag.String("prefix", os.Getenv("BUCKET_KEY"), "prefijo de los documentos en el bucket")
+	dryRun := flag.Bool("dry-run", true, "solo muestra los cambios sin escribirlos")
+	flag.Parse()
+
+	logger.Init()
+	domain.BUCKET_NAME = *bucket
+	domain.BUCKET_KEY = *prefix
+
+	ctx := context.Background()
+	if err := run(ctx, *table, *dryRun); err != nil {
+		slog.Error("migration failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, table string, dryRun bool) error {
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		return err
+	}
+	s3client, err := infrastructure.GetS3Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, table, ctx)
+	documentos, err := dynamoService.GetAllDocuments()
+	if err != nil {
+		return err
+	}
+
+	var fixed, skipped, missing, ambiguous int
+	for _, documento := range documentos {
+		log := slog.With("id_documento", documento.Documento_ID)
+
+		// FileKey viene de file_key o, en los documentos antiguos, de la URL guardada
+		if documento.FileKey != "" {
+			exists, err := infrastructure.ObjectExists(ctx, s3client, domain.BUCKET_NAME, documento.FileKey)
+			if err != nil {
+				return err
+			}
+			if exists {
+				skipped++
+				continue
+			}
+		}
+
+		keys, err := principalKeys(ctx, s3client, documento.Documento_ID)
+		if err != nil {
+			return err
+		}
+
+		switch len(keys) {
+		case 0:
+			log.Warn("no stored file found for documento")
+			missing++
+			continue
+		case 1:
+		default:
+			log.Warn("several candidate files found, skipping", "keys", keys)
+			ambiguous++
+			continue
+		}
+
+		log.Info("fixing file location", "key", keys[0], "old_key", documento.FileKey, "dry_run", dryRun)
+		if !dryRun {
+			if _, err := dynamoService.UpdateFileLocation(documento.Documento_ID, keys[0]); err != nil {
+				return err
+			}
+		}
+		fixed++
+	}
+
+	slog.Info("migration finished", "total", len(documentos), "fixed", fixed, "skipped", skipped,
+		"missing", missing, "ambiguous", ambiguous, "dry_run", dryRun)
+	return nil
+}
+
+// principalKeys lista los objetos "<prefijo><id>.<ext>" del documento, sin sus adjuntos.
+func principalKeys(ctx context.Context, s3client *s3.Client, documentoID string) ([]string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(domain.BUCKET_NAME),
+		Prefix: aws.String(domain.DocumentoObjectKey(documentoID, ".")),
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s3client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/metrics"
+	"main/src/upload"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME = os.Getenv("TABLE_NAME")
+	SQS_NAME   = os.Getenv("SQS_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	form, err := upload.Parse(request, upload.DefaultLimits())
+	if err != nil {
+		log.Warn("Invalid multipart request", "error", err)
+		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: upload.StatusCode(err)}, nil
+	}
+	if len(form.Files) == 0 {
+		return events.APIGatewayProxyResponse{Body: "At least one file part is required", StatusCode: http.StatusBadRequest}, nil
+	}
+
+	var adjuntos []domain.Adjunto
+	var uploadBytes int64
+	for _, file := range form.Files {
+		adjuntos = append(adjuntos, domain.NewAdjunto(file.FileName, file.ContentType, file.Data))
+		uploadBytes += file.Size()
+	}
+
+	// Los archivos se suben antes de registrarlos: por la cola solo viaja su key
+	sourceKeys := make([]string, len(form.Files))
+	for i, file := range form.Files {
+		sourceKeys[i], err = infrastructure.StageFile(ctx, domain.BUCKET_NAME, file.ContentType, file.Data)
+		if err != nil {
+			log.Error("Error staging file", "error", err)
+			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: 504}, nil
+		}
+	}
+
+	id_documento := request.PathParameters["id_documento"]
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	response, err := dynamoService.AddAttachments(id_documento, adjuntos)
+	if err != nil {
+		log.Error("error adding attachments in database", "error", err)
+		discardStaged(ctx, sourceKeys)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrDocumentoNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	// Los adjuntos nuevos quedan al final de la lista devuelta por el servicio
+	added := response.Adjuntos[len(response.Adjuntos)-len(form.Files):]
+	messages := make([]infrastructure.FileMessage, len(form.Files))
+	for i, file := range form.Files {
+		messages[i] = infrastructure.NewFileMessage(id_documento, file.FileName, file.ContentType, added[i].Key, sourceKeys[i])
+		messages[i].Principal = added[i].Principal
+	}
+	if err := infrastructure.SendFileMessages(ctx, SQS_NAME, messages); err != nil {
+		log.Error("Error sending SQS messages", "error", err)
+		// Los adjuntos de esta subida se quitan del documento para que no queden
+		// registrados archivos que nunca llegarán al bucket; sqs_consumer descarta los
+		// mensajes que sí entraron
+		removed := true
+		for _, adjunto := range added {
+			if _, removeErr := dynamoService.RemoveAttachment(id_documento, adjunto.Adjunto_ID); removeErr != nil {
+				log.Error("Error rolling back attachment", "id_adjunto", adjunto.Adjunto_ID, "error", removeErr)
+				removed = false
+			}
+		}
+		if removed {
+			discardStaged(ctx, sourceKeys)
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: 504,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	metrics.Emit(metrics.UploadBytes, float64(uploadBytes), metrics.Bytes,
+		metrics.DocumentDimensions(response.TipoDeServicio))
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+// discardStaged borra los archivos de una subida que no llegó a registrarse; si falla,
+// la regla de ciclo de vida de incoming/ los elimina después.
+func discardStaged(ctx context.Context, sourceKeys []string) {
+	if err := infrastructure.DiscardStagedFiles(ctx, domain.BUCKET_NAME, sourceKeys); err != nil {
+		logger.FromContext(ctx).Warn("Error discarding staged files", "keys", sourceKeys, "error", err)
+	}
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
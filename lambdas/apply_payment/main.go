@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME = os.Getenv("TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			log.Warn("Error decoding base64 request body", "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Error decoding base64: %s", err), StatusCode: 400}, nil
+		}
+	}
+
+	// Sin cuerpo el pago se reparte entre los cargos pendientes del documento
+	var pagoRequest domain.AplicarPagoRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &pagoRequest); err != nil {
+			log.Warn("Error parsing request body as JSON", "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+		}
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+
+	id_documento := request.PathParameters["id_documento"]
+
+	log.Info("Applying payment", "id_documento", id_documento, "id_cargo", pagoRequest.Cargo_ID, "monto", pagoRequest.Monto)
+
+	response, err := dynamoService.ApplyPayment(id_documento, pagoRequest)
+	if err != nil {
+		log.Error("error applying payment in database", "error", err)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, domain.ErrDocumentoNotFound), errors.Is(err, domain.ErrCargoNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, domain.ErrPagoConcurrente):
+			status = http.StatusConflict
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
@@ -2,28 +2,22 @@ package main
 
 import (
 	"context"
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"os"
-	"mime"
-	"mime/multipart"
 	"net/http"
-	"strings"
-
-	"log"
+	"os"
 
 	"main/src/application"
 	"main/src/domain"
 	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/metrics"
+	"main/src/tracing"
+	"main/src/upload"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
-	"github.com/aws/aws-sdk-go-v2/config"
 )
 
 var (
@@ -31,230 +25,121 @@ var (
 	SQS_NAME = os.Getenv("SQS_NAME")
 )
 
-// Definir una estructura que represente el objeto JSON
-type FileData struct {
-	FileContents string `json:"file_contents"` // Contenido del archivo codificado en base64
-	FileName     string `json:"file_name"`     // Nombre real del archivo
-	RealFileName     string `json:"real_file_name"`     // Nombre real del archivo
-}
-
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	log.Println("Inicio de la función Lambda")
-
-	// Load the Shared AWS Configuration (~/.aws/config)
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: 504,
-			Body:       err.Error(),
-		}, fmt.Errorf("error loading AWS configuration: %w", err)
-	}
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+	log.Info("Inicio de la función Lambda")
+	tracing.Annotate(ctx, "correlation_id", logger.CorrelationID(ctx))
 
 	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
 	if err != nil {
-		log.Println("Failed to get dynamodb client:", err)
+		log.Error("Failed to get dynamodb client", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
 			StatusCode: 504}, nil
 	}
 
-	log.Println("Starting Lambda handler")
-	contentType := request.Headers["content-type"]
-	if !strings.Contains(contentType, "multipart/form-data") {
-		log.Println("Error: content type not multipart/form-data")
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest}, nil
-	}
-
-	mediaType, params, err := mime.ParseMediaType(contentType)
+	form, err := upload.Parse(request, upload.DefaultLimits())
 	if err != nil {
-		log.Println("Error parsing media type:", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		log.Warn("Invalid multipart request", "error", err)
+		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: upload.StatusCode(err)}, nil
 	}
-	
-	log.Println("Request.Body:")
-	log.Println(request.Body)
 
-	var fileData []byte
-	if request.IsBase64Encoded {
-		log.Println("Request body is Base64Encoded")
-		fileData, err = base64.StdEncoding.DecodeString(request.Body)
-		if err != nil {
-			log.Println("Error decoding base64 body:", err)
-			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-		}
-	} else {
-		log.Println("Request body is not Base64Encoded")
-		fileData = []byte(request.Body)
+	// Cada parte "file" se convierte en un adjunto; el primero es el archivo principal
+	var adjuntos []domain.Adjunto
+	var uploadBytes int64
+	for _, file := range form.Files {
+		adjuntos = append(adjuntos, domain.NewAdjunto(file.FileName, file.ContentType, file.Data))
+		uploadBytes += file.Size()
 	}
 
-	log.Println("fileData Based64Encoded:")
-	log.Println(fileData)
-
-	var fileName string
-	var fileDepartamento string
-	var fileResidente string
-	var fileFechaPago string
-	var fileTipoServicio string
-	var fileStateDocument string 
-	var fileBuffer bytes.Buffer
-	var realFileName string 
-	
-
-	if strings.HasPrefix(mediaType, "multipart/") {
-		// Crea un multipart reader
-		reader := multipart.NewReader(bytes.NewReader(fileData), params["boundary"])
-		for {
-			part, err := reader.NextPart()
-			if err == io.EOF {
-				log.Println("Reached end of multipart content")
-				break
-			}
-			if err != nil {
-				log.Println("Error reading multipart section:", err)
-				return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-			}
-			switch part.FormName() {
-			case "file_name":
-				nameData, err := io.ReadAll(part)
-				if err != nil {
-					log.Println("Error reading file_name part:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-				fileName = string(nameData)
-				log.Println("Received file name:", fileName)
-			case "file":
-				log.Println("Reading file content")
-				realFileName = part.FileName()
-				if _, err := io.Copy(&fileBuffer, part); err != nil {
-					log.Println("Error copying file content to buffer:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-			case "departamento":
-				nameData, err := io.ReadAll(part)
-				if err != nil {
-					log.Println("Error reading departamento part:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-				fileDepartamento = string(nameData)
-				log.Println("Received departamento name:", fileDepartamento)
-			case "residente":
-				nameData, err := io.ReadAll(part)
-				if err != nil {
-					log.Println("Error reading residente part:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-				fileResidente = string(nameData)
-				log.Println("Received residente name:", fileResidente)
-			case "fecha_de_pago":
-				nameData, err := io.ReadAll(part)
-				if err != nil {
-					log.Println("Error reading fecha_de_pago part:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-				fileFechaPago = string(nameData)
-				log.Println("Received fecha_de_pago:", fileFechaPago)
-			case "tipo_de_servicio":
-				nameData, err := io.ReadAll(part)
-				if err != nil {
-					log.Println("Error reading tipo_de_servicio part:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-				fileTipoServicio = string(nameData)
-				log.Println("Received tipo_de_servicio:", fileTipoServicio)
-			
-			case "estado_documento":
-				nameData, err := io.ReadAll(part)
-				if err != nil {
-					log.Println("Error reading tipo_de_servicio part:", err)
-					return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-				}
-				fileStateDocument = string(nameData)
-				log.Println("Received tipo_de_servicio:", fileStateDocument)
-			}
-		}
+	monto, err := domain.ParseMonto(form.Value("monto"))
+	if err != nil {
+		log.Warn("Invalid amount", "error", err)
+		return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: http.StatusBadRequest}, nil
 	}
 
-	log.Println("fileName: ",fileName)
-	log.Println("fileDepartamento: ",fileDepartamento)
-	log.Println("fileResidente: ",fileResidente)
-	log.Println("fileFechaPago: ",fileFechaPago)
-	log.Println("fileTipoServicio: ",fileTipoServicio)
-	log.Println("fileStateDocument: ",fileStateDocument)
-	log.Println("fileBuffer: ",fileBuffer)
-	log.Println("realFileName: ",realFileName)
-	
-	
-
 	documentoRequest := domain.DocumentoRequest{
-		Departamento: fileDepartamento,
-		Residente: fileResidente,
-		FechaDePago: fileFechaPago,
-		TipoDeServicio: fileTipoServicio,
-		StateDocument: fileStateDocument,
+		Departamento:   form.Value("departamento"),
+		Residente:      form.Value("residente"),
+		ResidenteID:    form.Value("id_residente"),
+		FechaDePago:    form.Value("fecha_de_pago"),
+		TipoDeServicio: form.Value("tipo_de_servicio"),
+		StateDocument:  form.Value("estado_documento"),
+		Monto:          monto,
+		Moneda:         form.Value("moneda"),
+		TipoCambio:     form.Value("tipo_de_cambio"),
+		Adjuntos:       adjuntos,
 	}
 
-	// Convertir el bytes.Buffer a base64 para que pueda ser representado en JSON
-	encodedFileContents := base64.StdEncoding.EncodeToString(fileBuffer.Bytes())
+	log.Info("Multipart form parsed", "documento", documentoRequest, "files", len(form.Files), "file_size", uploadBytes)
+
+	// Los archivos se suben antes de crear el documento: por la cola solo viaja su key
+	sourceKeys := make([]string, len(form.Files))
+	for i, file := range form.Files {
+		sourceKeys[i], err = infrastructure.StageFile(ctx, domain.BUCKET_NAME, file.ContentType, file.Data)
+		if err != nil {
+			log.Error("Error staging file", "error", err)
+			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: 504}, nil
+		}
+	}
 
-	log.Println("Creando documento en la base de datos...")
+	log.Info("Creando documento en la base de datos")
 	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
 	response, err := dynamoService.CreateDocument(documentoRequest)
 	if err != nil {
-		log.Printf("Error creating documento in database: %s", err)
+		log.Error("Error creating documento in database", "error", err)
+		discardStaged(ctx, sourceKeys)
+		// Un comprobante repetido se rechaza indicando el documento donde ya está registrado
+		var duplicateErr *domain.DuplicateError
+		if errors.As(err, &duplicateErr) {
+			body, _ := json.Marshal(map[string]string{"message": err.Error(), "id_documento": duplicateErr.DocumentoID})
+			return events.APIGatewayProxyResponse{Body: string(body), StatusCode: http.StatusConflict}, nil
+		}
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
 	}
 
-	// Crear una instancia de la estructura con los datos codificados y el nombre del archivo
-	newData := FileData{
-		FileContents: encodedFileContents,
-		FileName:     realFileName,
-		RealFileName: response.Documento_ID,
-	}
-
-	// Serializar la estructura a JSON
-	jsonData, err := json.Marshal(newData)
-	if err != nil {
-		fmt.Println("Error al serializar a JSON:", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-	}
-
-	// Convertir los datos JSON a una cadena y mostrarla
-	jsonString := string(jsonData)
-	fmt.Println(jsonString)
-
 	// ======================== SQS code ==========================================
 
-	// Create an Amazon SQS client
-	sqsClient := sqs.NewFromConfig(cfg)
-
-	// Send message
-	msgInput := &sqs.SendMessageInput{
-		QueueUrl:               aws.String(SQS_NAME),
-		MessageBody:            aws.String(jsonString),
+	// Un mensaje por archivo, con la key ya asignada a su adjunto
+	messages := make([]infrastructure.FileMessage, len(form.Files))
+	for i, file := range form.Files {
+		messages[i] = infrastructure.NewFileMessage(response.Documento_ID, file.FileName, file.ContentType,
+			response.Adjuntos[i].Key, sourceKeys[i])
+		messages[i].Principal = response.Adjuntos[i].Principal
 	}
-	
-	_, err = sqsClient.SendMessage(ctx, msgInput)
-	if err != nil {
+	if err := infrastructure.SendFileMessages(ctx, SQS_NAME, messages); err != nil {
+		log.Error("Error sending SQS messages", "error", err)
+		// Sin todos sus archivos en la cola el documento quedaría incompleto: se elimina,
+		// sqs_consumer descarta los mensajes que sí entraron y el cliente puede reintentar
+		// la subida completa
+		if _, deleteErr := dynamoService.DeleteDocument(response.Documento_ID); deleteErr != nil {
+			log.Error("Error rolling back documento", "id_documento", response.Documento_ID, "error", deleteErr)
+		} else {
+			discardStaged(ctx, sourceKeys)
+		}
 		return events.APIGatewayProxyResponse{
 			StatusCode: 504,
 			Body:       err.Error(),
-		}, fmt.Errorf("error sending SQS message: %w", err)
+		}, nil
 	}
 
-	log.Println("Convirtiendo la respuesta a JSON...")
 	responseBody, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("Error marshaling response to JSON: %s", err)
+		log.Error("Error marshaling response to JSON", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
 	}
 
 	headers := map[string]string{
 		"Access-Control-Allow-Origin":  "*",
 		"Access-Control-Allow-Methods": "POST,OPTIONS,DELETE,GET,HEAD,PUT",
-		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token,X-Custom-Header",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token,X-Custom-Header,X-Correlation-Id",
 		"Content-Type":                 "application/json",
+		logger.CorrelationHeader:       logger.CorrelationID(ctx),
 	}
 
-	log.Println("Finalizando la función Lambda con éxito")
+	metrics.Emit(metrics.UploadBytes, float64(uploadBytes), metrics.Bytes,
+		metrics.DocumentDimensions(documentoRequest.TipoDeServicio))
+
+	log.Info("Finalizando la función Lambda con éxito", "id_documento", response.Documento_ID)
 	return events.APIGatewayProxyResponse{
 		Headers: headers,
 		Body:       string(responseBody),
@@ -262,6 +147,15 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}, nil
 }
 
+// discardStaged borra los archivos de una subida que no llegó a registrarse; si falla,
+// la regla de ciclo de vida de incoming/ los elimina después.
+func discardStaged(ctx context.Context, sourceKeys []string) {
+	if err := infrastructure.DiscardStagedFiles(ctx, domain.BUCKET_NAME, sourceKeys); err != nil {
+		logger.FromContext(ctx).Warn("Error discarding staged files", "keys", sourceKeys, "error", err)
+	}
+}
+
 func main() {
+	logger.Init()
 	lambda.Start(handler)
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var (
+	TABLE_NAME  = os.Getenv("TABLE_NAME")
+	BUCKET_NAME = os.Getenv("BUCKET_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	s3client, err := infrastructure.GetS3Client(ctx)
+	if err != nil {
+		log.Error("Failed to get s3 client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get s3 client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+
+	id_documento := request.PathParameters["id_documento"]
+	id_adjunto := request.PathParameters["id_adjunto"]
+
+	removed, err := dynamoService.RemoveAttachment(id_documento, id_adjunto)
+	if err != nil {
+		log.Error("error removing attachment in database", "error", err)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, domain.ErrDocumentoNotFound), errors.Is(err, domain.ErrAdjuntoNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, domain.ErrAdjuntoPrincipal):
+			status = http.StatusConflict
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	// El blob solo se borra si ningún documento sigue referenciando su contenido
+	keys, err := dynamoService.UnreferencedKeys([]string{removed.Key})
+	if err != nil {
+		log.Warn("error checking object references", "key", removed.Key, "error", err)
+	}
+	for _, key := range keys {
+		_, err = s3client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(BUCKET_NAME),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			// El adjunto ya no está referenciado; el objeto huérfano no bloquea la respuesta
+			log.Warn("error deleting attachment object", "key", key, "error", err)
+		}
+	}
+
+	responseBody, err := json.Marshal(removed)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
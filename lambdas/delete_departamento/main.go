@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME              = os.Getenv("TABLE_NAME")
+	DEPARTAMENTO_TABLE_NAME = os.Getenv("DEPARTAMENTO_TABLE_NAME")
+	RESIDENTE_TABLE_NAME    = os.Getenv("RESIDENTE_TABLE_NAME")
+	CARGO_TABLE_NAME        = os.Getenv("CARGO_TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	id_departamento := domain.NormalizeDepartamentoID(request.PathParameters["id_departamento"])
+
+	// Un departamento con documentos, residentes o cargos no se elimina: quedarían sin referencia
+	checks := map[string]func(string) (bool, error){
+		"documentos": application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx).HasDocuments,
+	}
+	if RESIDENTE_TABLE_NAME != "" {
+		checks["residentes"] = application.NewResidenteServiceDynamo(dynamoClient, RESIDENTE_TABLE_NAME, ctx).HasDepartamentoResidentes
+	}
+	if CARGO_TABLE_NAME != "" {
+		checks["cargos"] = application.NewCargoServiceDynamo(dynamoClient, CARGO_TABLE_NAME, ctx).HasDepartamentoCargos
+	}
+	for name, check := range checks {
+		inUse, err := check(id_departamento)
+		if err != nil {
+			log.Error("error checking departamento references", "tabla", name, "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+		}
+		if inUse {
+			log.Warn("Departamento is referenced", "id_departamento", id_departamento, "tabla", name)
+			return events.APIGatewayProxyResponse{Body: domain.ErrDepartamentoEnUso.Error(), StatusCode: http.StatusConflict}, nil
+		}
+	}
+
+	dynamoService := application.NewDepartamentoServiceDynamo(dynamoClient, DEPARTAMENTO_TABLE_NAME, ctx)
+
+	response, err := dynamoService.DeleteDepartamento(id_departamento)
+	if err != nil {
+		log.Error("error deleting departamento in database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrDepartamentoNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
@@ -3,26 +3,34 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 
-	"log"
-
 	"main/src/application"
+	"main/src/domain"
 	"main/src/infrastructure"
+	"main/src/logger"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 var (
-	TABLE_NAME = os.Getenv("TABLE_NAME")
+	TABLE_NAME  = os.Getenv("TABLE_NAME")
+	BUCKET_NAME = os.Getenv("BUCKET_NAME")
 )
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
 	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
 	if err != nil {
-		log.Fatalln("Failed to get dynamodb client")
+		log.Error("Failed to get dynamodb client", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
 			StatusCode: 504}, nil
 	}
@@ -33,15 +41,28 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 	response, err := dynamoService.DeleteDocument(id_documento)
 	if err != nil {
-		log.Printf("error creating documento in database :%s\n", err)
-		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+		log.Error("error deleting documento in database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrDocumentoAplicado) {
+			status = http.StatusConflict
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
 	}
 
 	response.Documento_ID = id_documento
 
+	// Los archivos del documento se eliminan con las keys persistidas, no reconstruidas;
+	// los blobs compartidos con otros documentos se conservan
+	keys, err := dynamoService.UnreferencedKeys(response.ObjectKeys())
+	if err != nil {
+		log.Warn("error checking object references", "error", err)
+	} else if len(keys) > 0 {
+		deleteObjects(ctx, keys)
+	}
+
 	responseBody, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("error marshaling response to JSON: %s\n", err)
+		log.Error("error marshaling response to JSON", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
 	}
 
@@ -59,6 +80,31 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}, nil
 }
 
+func deleteObjects(ctx context.Context, keys []string) {
+	log := logger.FromContext(ctx)
+
+	s3client, err := infrastructure.GetS3Client(ctx)
+	if err != nil {
+		log.Warn("Failed to get s3 client", "error", err)
+		return
+	}
+
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+
+	_, err = s3client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Delete: &types.Delete{Objects: objects, Quiet: true},
+	})
+	if err != nil {
+		// El documento ya no existe; los objetos huérfanos no bloquean la respuesta
+		log.Warn("error deleting document objects", "keys", keys, "error", err)
+	}
+}
+
 func main() {
+	logger.Init()
 	lambda.Start(handler)
 }
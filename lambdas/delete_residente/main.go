@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME           = os.Getenv("TABLE_NAME")
+	RESIDENTE_TABLE_NAME = os.Getenv("RESIDENTE_TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	id_residente := request.PathParameters["id_residente"]
+
+	// Un residente con documentos no se elimina: se registra su fecha de salida
+	documentoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	inUse, err := documentoService.HasResidenteDocuments(id_residente)
+	if err != nil {
+		log.Error("error checking residente documents", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+	if inUse {
+		log.Warn("Residente has documents", "id_residente", id_residente)
+		return events.APIGatewayProxyResponse{Body: domain.ErrResidenteEnUso.Error(), StatusCode: http.StatusConflict}, nil
+	}
+
+	dynamoService := application.NewResidenteServiceDynamo(dynamoClient, RESIDENTE_TABLE_NAME, ctx)
+
+	response, err := dynamoService.DeleteResidente(id_residente)
+	if err != nil {
+		log.Error("error deleting residente in database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrResidenteNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME          = os.Getenv("TABLE_NAME")
+	SERVICIO_TABLE_NAME = os.Getenv("SERVICIO_TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	codigo := domain.NormalizeServicioCodigo(request.PathParameters["codigo"])
+
+	// Un servicio con documentos no se elimina: se desactiva para que no acepte nuevos
+	documentoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	inUse, err := documentoService.HasServicioDocuments(codigo)
+	if err != nil {
+		log.Error("error checking servicio documents", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+	if inUse {
+		log.Warn("Servicio has documents", "codigo", codigo)
+		return events.APIGatewayProxyResponse{Body: domain.ErrServicioEnUso.Error(), StatusCode: http.StatusConflict}, nil
+	}
+
+	dynamoService := application.NewServicioServiceDynamo(dynamoClient, SERVICIO_TABLE_NAME, ctx)
+
+	response, err := dynamoService.DeleteServicio(codigo)
+	if err != nil {
+		log.Error("error deleting servicio in database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrServicioNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
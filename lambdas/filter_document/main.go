@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"main/src/domain"
+	"main/src/download"
+	"main/src/export"
+	"main/src/infrastructure"
+	"main/src/logger"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
 )
 
 var (
@@ -21,21 +33,44 @@ var (
 )
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	log.Println("Starting the handler")
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+	log.Info("Starting the handler")
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+	client, err := infrastructure.GetDynamoClient(ctx)
 	if err != nil {
-		log.Printf("Failed to load SDK config: %s", err)
-		return errorResponse(fmt.Sprintf("Failed to load SDK config: %s", err)), nil
+		log.Error("Failed to get dynamodb client", "error", err)
+		return errorResponse(log, fmt.Sprintf("Failed to get dynamodb client: %s", err)), nil
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
-
 	departamento := request.QueryStringParameters["departamento"]
 	residente := request.QueryStringParameters["residente"]
 	fechaDePago := request.QueryStringParameters["fecha_de_pago"]
+	moneda := strings.ToUpper(request.QueryStringParameters["moneda"])
+
+	// El rango de montos se expresa en céntimos, igual que el campo monto
+	montoMin, errMin := domain.ParseMonto(request.QueryStringParameters["monto_min"])
+	montoMax, errMax := domain.ParseMonto(request.QueryStringParameters["monto_max"])
+	if err := errors.Join(errMin, errMax); err != nil {
+		log.Warn("Invalid amount range", "error", err)
+		return events.APIGatewayProxyResponse{Body: domain.ErrMontoInvalido.Error(), StatusCode: 400}, nil
+	}
+	if request.QueryStringParameters["monto_max"] != "" && montoMax < montoMin {
+		return events.APIGatewayProxyResponse{Body: "monto_max debe ser mayor o igual que monto_min", StatusCode: 400}, nil
+	}
+
+	// Con Accept: text/csv, el tipo de XLSX o ?formato=csv|xlsx se descarga una hoja de cálculo
+	format := export.Format(request)
+	var columns []export.Column
+	if format != export.FormatJSON {
+		columns, err = export.Columns(request)
+		if err != nil {
+			log.Warn("Invalid export columns", "error", err)
+			return events.APIGatewayProxyResponse{Body: err.Error(), StatusCode: 400}, nil
+		}
+	}
 
-	log.Printf("Received filters: departamento: %s, residente: %s, fechaDePago: %s", departamento, residente, fechaDePago)
+	log.Info("Received filters", "departamento", departamento, "residente", residente, "fecha_de_pago", fechaDePago,
+		"moneda", moneda, "monto_min", montoMin, "monto_max", montoMax, "format", format)
 
 	filterExpression := ""
 	expressionAttributeValues := map[string]types.AttributeValue{}
@@ -61,39 +96,40 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		expressionAttributeValues[":fechaDePagoVal"] = &types.AttributeValueMemberS{Value: fechaDePago}
 	}
 
-	log.Printf("Constructed filter expression: %s", filterExpression)
-
-	queryInput := &dynamodb.ScanInput{
-		TableName:                 &TABLE_NAME,
-		FilterExpression:          &filterExpression,
-		ExpressionAttributeValues: expressionAttributeValues,
+	if moneda != "" {
+		if filterExpression != "" {
+			filterExpression += " AND "
+		}
+		filterExpression += "moneda = :monedaVal"
+		expressionAttributeValues[":monedaVal"] = &types.AttributeValueMemberS{Value: moneda}
 	}
 
-	output, err := client.Scan(ctx, queryInput)
-	if err != nil {
-		log.Printf("Failed to scan DynamoDB: %s", err)
-		return errorResponse(fmt.Sprintf("Failed to scan DynamoDB: %s", err)), nil
+	if request.QueryStringParameters["monto_min"] != "" {
+		if filterExpression != "" {
+			filterExpression += " AND "
+		}
+		filterExpression += "monto >= :montoMinVal"
+		expressionAttributeValues[":montoMinVal"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(montoMin, 10)}
 	}
 
-	var documentos []domain.Documento
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &documentos)
-	if err != nil {
-		return errorResponse(fmt.Sprintf("Failed parsing DynamoDB response: %s", err)), nil
+	if request.QueryStringParameters["monto_max"] != "" {
+		if filterExpression != "" {
+			filterExpression += " AND "
+		}
+		filterExpression += "monto <= :montoMaxVal"
+		expressionAttributeValues[":montoMaxVal"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(montoMax, 10)}
 	}
 
-	var documentosResponse []domain.DocumentoResponse
+	log.Debug("Constructed filter expression", "filter_expression", filterExpression)
 
-	for _, documento := range documentos {
-		documentosResponse = append(documentosResponse, documento.ToDocumentoResponse())
+	queryInput := &dynamodb.ScanInput{
+		TableName: &TABLE_NAME,
 	}
-
-	body, err := json.Marshal(documentosResponse)
-	if err != nil {
-		log.Printf("Failed to marshal response: %s", err)
-		return errorResponse(fmt.Sprintf("Failed to marshal response: %s", err)), nil
+	if filterExpression != "" {
+		queryInput.FilterExpression = &filterExpression
+		queryInput.ExpressionAttributeValues = expressionAttributeValues
 	}
 
-	log.Println("Handler completed successfully")
 	headers := map[string]string{
 		"Access-Control-Allow-Origin":  "*",
 		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
@@ -101,6 +137,119 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		"Content-Type":                 "application/json",
 	}
 
+	// El filtro se aplica página por página: hay que recorrer toda la tabla
+	paginator := dynamodb.NewScanPaginator(client, queryInput)
+
+	if format != export.FormatJSON {
+		var file bytes.Buffer
+		writer, err := export.NewDocumentWriter(&file, format, columns, export.OptionsFor(request))
+		if err != nil {
+			return errorResponse(log, fmt.Sprintf("Failed to start export: %s", err)), nil
+		}
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				log.Error("Failed to scan DynamoDB", "error", err)
+				return errorResponse(log, fmt.Sprintf("Failed to scan DynamoDB: %s", err)), nil
+			}
+
+			var documentos []domain.Documento
+			if err := attributevalue.UnmarshalListOfMaps(output.Items, &documentos); err != nil {
+				return errorResponse(log, fmt.Sprintf("Failed parsing DynamoDB response: %s", err)), nil
+			}
+			for _, documento := range documentos {
+				if err := writer.Write(documento.ToDocumentoResponse()); err != nil {
+					return errorResponse(log, fmt.Sprintf("Failed to write export: %s", err)), nil
+				}
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return errorResponse(log, fmt.Sprintf("Failed to write export: %s", err)), nil
+		}
+
+		log.Info("Export completed successfully", "count", writer.Count(), "format", format, "bytes", file.Len())
+		headers["Content-Type"] = export.ContentType(format)
+		headers["Content-Disposition"] = export.Disposition(
+			fmt.Sprintf("documentos-%s.%s", time.Now().UTC().Format("2006-01-02"), format))
+
+		// Lambda no admite respuestas de más de 6 MB: una exportación grande se deja en el
+		// bucket y el cliente la descarga con una URL prefirmada
+		opts := download.DefaultOptions()
+		if int64(file.Len()) > opts.MaxInlineBytes {
+			s3client, err := infrastructure.GetS3Client(ctx)
+			if err != nil {
+				return errorResponse(log, fmt.Sprintf("Failed to get s3 client: %s", err)), nil
+			}
+			key := domain.ExportObjectKey(uuid.NewString() + "." + format)
+			_, err = s3client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket:       aws.String(domain.BUCKET_NAME),
+				Key:          aws.String(key),
+				Body:         bytes.NewReader(file.Bytes()),
+				ContentType:  aws.String(headers["Content-Type"]),
+				CacheControl: aws.String("private, no-store"),
+			})
+			if err != nil {
+				return errorResponse(log, fmt.Sprintf("Failed to store export: %s", err)), nil
+			}
+			log.Info("Export stored for download", "key", key)
+
+			opts.Private = true
+			opts.ContentDisposition = headers["Content-Disposition"]
+			return download.Serve(ctx, s3client, request, key, opts)
+		}
+
+		// El XLSX es binario: API Gateway lo recibe en base64
+		if format == export.FormatXLSX {
+			return events.APIGatewayProxyResponse{
+				Headers:         headers,
+				Body:            base64.StdEncoding.EncodeToString(file.Bytes()),
+				IsBase64Encoded: true,
+				StatusCode:      200,
+			}, nil
+		}
+		return events.APIGatewayProxyResponse{
+			Headers:    headers,
+			Body:       file.String(),
+			StatusCode: 200,
+		}, nil
+	}
+
+	var documentosResponse []domain.DocumentoResponse
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Error("Failed to scan DynamoDB", "error", err)
+			return errorResponse(log, fmt.Sprintf("Failed to scan DynamoDB: %s", err)), nil
+		}
+
+		var documentos []domain.Documento
+		err = attributevalue.UnmarshalListOfMaps(output.Items, &documentos)
+		if err != nil {
+			return errorResponse(log, fmt.Sprintf("Failed parsing DynamoDB response: %s", err)), nil
+		}
+
+		for _, documento := range documentos {
+			documentosResponse = append(documentosResponse, documento.ToDocumentoResponse())
+		}
+	}
+
+	// Con totales=true la respuesta incluye la suma de los montos filtrados
+	var body []byte
+	if request.QueryStringParameters["totales"] == "true" {
+		body, err = json.Marshal(struct {
+			Documentos []domain.DocumentoResponse `json:"documentos"`
+			Totales    domain.Totales             `json:"totales"`
+		}{documentosResponse, domain.Totalizar(documentosResponse)})
+	} else {
+		body, err = json.Marshal(documentosResponse)
+	}
+	if err != nil {
+		log.Error("Failed to marshal response", "error", err)
+		return errorResponse(log, fmt.Sprintf("Failed to marshal response: %s", err)), nil
+	}
+
+	log.Info("Handler completed successfully", "count", len(documentosResponse))
+
 	return events.APIGatewayProxyResponse{
 		Headers:    headers,
 		Body:       string(body),
@@ -108,8 +257,8 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}, nil
 }
 
-func errorResponse(err string) events.APIGatewayProxyResponse {
-	log.Printf("Returning error response: %s", err)
+func errorResponse(log *slog.Logger, err string) events.APIGatewayProxyResponse {
+	log.Error("Returning error response", "error", err)
 	return events.APIGatewayProxyResponse{
 		Body:       err,
 		StatusCode: 500,
@@ -117,5 +266,6 @@ func errorResponse(err string) events.APIGatewayProxyResponse {
 }
 
 func main() {
+	logger.Init()
 	lambda.Start(handler)
 }
@@ -6,10 +6,9 @@ import (
 	"fmt"
 	"os"
 
-	"log"
-
 	"main/src/application"
 	"main/src/infrastructure"
+	"main/src/logger"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -20,9 +19,11 @@ var (
 )
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
 	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
 	if err != nil {
-		log.Fatalln("Failed to get dynamodb client")
+		log.Error("Failed to get dynamodb client", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
 			StatusCode: 500}, nil
 	}
@@ -31,13 +32,13 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 	response, err := dynamoService.GetAllDocuments()
 	if err != nil {
-		log.Printf("error creating documento in database :%s\n", err)
+		log.Error("error listing documento in database", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
 	}
 
 	responseBody, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("error marshaling response to JSON: %s\n", err)
+		log.Error("error marshaling response to JSON", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
 	}
 
@@ -56,5 +57,6 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 }
 
 func main() {
+	logger.Init()
 	lambda.Start(handler)
 }
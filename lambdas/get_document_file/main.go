@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"main/src/application"
+	"main/src/auth"
+	"main/src/domain"
+	"main/src/download"
+	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/preview"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	TABLE_NAME = os.Getenv("TABLE_NAME")
+
+	// Tamaño máximo de la imagen original que se acepta transformar
+	TRANSFORM_MAX_SOURCE_BYTES = os.Getenv("TRANSFORM_MAX_SOURCE_BYTES")
+)
+
+const defaultMaxSourceBytes = 20 << 20
+
+var (
+	errNotFound = errors.New("object not found")
+	errNotImage = errors.New("only JPEG, PNG and WebP images can be transformed")
+	errTooLarge = errors.New("image too large to transform")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+
+	id_documento := request.PathParameters["id_documento"]
+	id_adjunto := request.QueryStringParameters["id_adjunto"]
+	archivo := request.QueryStringParameters["archivo"]
+
+	documento, err := dynamoService.GetDocument(id_documento)
+	if err != nil {
+		log.Error("error getting documento from database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrDocumentoNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	// Un residente solo puede ver los archivos de su departamento
+	if !auth.FromRequest(request).CanView(documento) {
+		log.Warn("Caller not allowed to view document file", "id_documento", id_documento)
+		return events.APIGatewayProxyResponse{Body: "Forbidden", StatusCode: http.StatusForbidden}, nil
+	}
+
+	// Los documentos con un archivo infectado no se sirven hasta que un administrador los revise
+	if documento.EstadoArchivo == domain.EstadoArchivoMalware {
+		log.Warn("Document file rejected by malware scan", "id_documento", id_documento)
+		return events.APIGatewayProxyResponse{Body: domain.ErrArchivoRechazado.Error(), StatusCode: http.StatusForbidden}, nil
+	}
+
+	// La key sale del documento, nunca de la petición
+	var file domain.Adjunto
+	if archivo != "" {
+		file, err = documento.Derivado(archivo)
+	} else {
+		file, err = documento.File(id_adjunto)
+	}
+	if err != nil {
+		log.Warn("Document file not found", "id_documento", id_documento, "id_adjunto", id_adjunto, "archivo", archivo)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: http.StatusNotFound}, nil
+	}
+
+	s3client, err := infrastructure.GetS3Client(ctx)
+	if err != nil {
+		log.Error("Failed to get s3 client", "error", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Con width/height/fit/format/quality se entrega una variante, generada una sola vez
+	objectKey := file.Key
+	filename := file.NombreOriginal
+	transform, transformed, err := preview.ParseTransform(request.QueryStringParameters)
+	if err != nil {
+		log.Warn("Invalid transform parameters", "error", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: err.Error()}, nil
+	}
+	if transformed {
+		derivedKey, err := derive(ctx, s3client, objectKey, transform)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, errNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errNotImage):
+				status = http.StatusUnsupportedMediaType
+			case errors.Is(err, errTooLarge), errors.Is(err, preview.ErrTooLarge):
+				status = http.StatusRequestEntityTooLarge
+			}
+			log.Error("Error transforming image", "key", objectKey, "error", err)
+			return events.APIGatewayProxyResponse{StatusCode: status, Body: err.Error()}, nil
+		}
+		objectKey = derivedKey
+		filename = strings.TrimSuffix(filename, path.Ext(filename)) + path.Ext(derivedKey)
+	}
+
+	// PDF e imágenes se muestran en el navegador salvo que se pida descargarlos
+	dispositionType := "attachment"
+	if request.QueryStringParameters["download"] != "true" && isViewable(objectKey) {
+		dispositionType = "inline"
+	}
+
+	opts := download.DefaultOptions()
+	opts.Private = true
+	opts.ContentDisposition = download.ContentDisposition(dispositionType, filename)
+	return download.Serve(ctx, s3client, request, objectKey, opts)
+}
+
+// derive devuelve la key de la variante transformada, generándola y guardándola en el
+// bucket la primera vez que se pide.
+func derive(ctx context.Context, s3client *s3.Client, key string, transform preview.Transform) (string, error) {
+	log := logger.FromContext(ctx)
+
+	head, err := s3client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(domain.BUCKET_NAME),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return "", errNotFound
+		}
+		return "", err
+	}
+
+	contentType := aws.ToString(head.ContentType)
+	if !preview.IsTransformable(contentType) {
+		return "", errNotImage
+	}
+	if head.ContentLength > maxSourceBytes() {
+		return "", errTooLarge
+	}
+
+	derivedKey := domain.DerivedObjectKey(key, aws.ToString(head.ETag), transform.Variant(contentType))
+	exists, err := infrastructure.ObjectExists(ctx, s3client, domain.BUCKET_NAME, derivedKey)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return derivedKey, nil
+	}
+
+	object, err := s3client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(domain.BUCKET_NAME),
+		Key:     aws.String(key),
+		IfMatch: head.ETag,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := transform.Apply(data, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s3client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(domain.BUCKET_NAME),
+		Key:          aws.String(derivedKey),
+		Body:         bytes.NewReader(result),
+		ContentType:  aws.String(transform.ContentType(contentType)),
+		CacheControl: aws.String("private, max-age=31536000, immutable"),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Info("Derived image stored", "key", derivedKey, "source_size", len(data), "size", len(result))
+	return derivedKey, nil
+}
+
+func maxSourceBytes() int64 {
+	n, err := strconv.ParseInt(TRANSFORM_MAX_SOURCE_BYTES, 10, 64)
+	if err != nil || n <= 0 {
+		return defaultMaxSourceBytes
+	}
+	return n
+}
+
+// Extensiones que el navegador puede mostrar directamente.
+var viewable = map[string]bool{".pdf": true, ".jpg": true, ".jpeg": true, ".png": true}
+
+func isViewable(key string) bool {
+	return viewable[strings.ToLower(path.Ext(key))]
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
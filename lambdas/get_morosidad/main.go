@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/export"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME = os.Getenv("TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	fechaCorte := request.QueryStringParameters["fecha_corte"]
+	format := export.Format(request)
+
+	dynamoService := application.NewReporteServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	response, err := dynamoService.Morosidad(fechaCorte)
+	if err != nil {
+		log.Error("error building morosidad report", "error", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrFechaCorte) {
+			status = http.StatusBadRequest
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	log.Info("Morosidad report built", "fecha_corte", response.FechaCorte, "departamentos", len(response.Departamentos),
+		"format", format)
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	if format == export.FormatCSV {
+		var body bytes.Buffer
+		if err := response.WriteCSV(&body); err != nil {
+			log.Error("error writing morosidad CSV", "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+		}
+		headers["Content-Type"] = export.ContentTypeCSV
+		headers["Content-Disposition"] = export.Disposition(fmt.Sprintf("morosidad-%s.csv", response.FechaCorte))
+
+		return events.APIGatewayProxyResponse{
+			Headers:    headers,
+			Body:       body.String(),
+			StatusCode: 200,
+		}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
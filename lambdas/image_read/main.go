@@ -2,95 +2,86 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
-	"io"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
-	"strings"
+
+	"main/src/application"
+	"main/src/auth"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 var (
-	BUCKET_NAME = os.Getenv("BUCKET_NAME")
+	TABLE_NAME = os.Getenv("TABLE_NAME")
 )
 
+// Parámetros de image_read que /document/{id}/file sigue aceptando.
+var forwarded = []string{"width", "height", "fit", "format", "quality", "download"}
+
+// handler mantiene los enlaces antiguos /image_read?key=<key>: ubica el documento dueño
+// del objeto, aplica el mismo control de acceso y redirige a /document/{id}/file.
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	log.Println("Handler invoked")  // <-- Log at the start
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+	log.Warn("Deprecated endpoint invoked")
 
 	objectKey := request.QueryStringParameters["key"]
 	if objectKey == "" {
-		log.Println("Object key not provided")  // <-- Log for missing key
+		log.Warn("Object key not provided")
 		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Object key is required."}, nil
 	}
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
 	if err != nil {
-		log.Fatalf("Unable to load SDK config, %v", err)
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
 	}
 
-	s3client := s3.NewFromConfig(cfg)
-
-	input := &s3.GetObjectInput{
-		Bucket: &BUCKET_NAME,
-		Key:    &objectKey,
-	}
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
 
-	object, err := s3client.GetObject(ctx, input)
+	documento, err := dynamoService.FindByObjectKey(objectKey)
 	if err != nil {
-		log.Println("Error retrieving object:", err)  // <-- Log for error while retrieving object
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrDocumentoNotFound) {
+			status = http.StatusNotFound
+		}
+		log.Warn("Document for object key not found", "key", objectKey, "error", err)
+		return events.APIGatewayProxyResponse{StatusCode: status, Body: err.Error()}, nil
 	}
-	defer object.Body.Close()
 
-	// Read the object's content into a byte slice
-	data, err := io.ReadAll(object.Body)
-	if err != nil {
-		log.Println("Error reading object data:", err)  // <-- Log for error while reading object data
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	// Sin permiso para el documento no se revela su ID en la redirección
+	if !auth.FromRequest(request).CanView(documento) {
+		log.Warn("Caller not allowed to view document file", "id_documento", documento.Documento_ID)
+		return events.APIGatewayProxyResponse{Body: "Forbidden", StatusCode: http.StatusForbidden}, nil
 	}
 
-	// Convert the byte slice to a base64 encoded string
-	encodedString := base64.StdEncoding.EncodeToString(data)
-
-	contentType := "application/octet-stream"
-	if object.ContentType != nil {
-		log.Println("ContentType != nil")
-		contentType = *object.ContentType
+	params, _ := documento.FileParams(objectKey)
+	for _, name := range forwarded {
+		if value := request.QueryStringParameters[name]; value != "" {
+			params.Set(name, value)
+		}
 	}
-
-	contentDisposition := "attachment"
-	if strings.HasSuffix(objectKey, ".jpg") || strings.HasSuffix(objectKey, ".jpeg") {
-		log.Println("String has suffix .jpg or .jpeg")
-		contentDisposition = "inline"
-	} else if strings.HasSuffix(objectKey, ".pdf") {
-		log.Println("String has suffix .pdf")
-		contentDisposition = "inline; filename=" + objectKey
-	}
-
-	headers := map[string]string{
-		"Content-Type":                 contentType,
-		"Content-Disposition":          contentDisposition,
-		"Access-Control-Allow-Origin":  "*",
-		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
-		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
-	}
-
-	log.Println("Returning response")  // <-- Log before returning the response
+	location := domain.FileURL(documento.Documento_ID, params)
 
 	return events.APIGatewayProxyResponse{
-		StatusCode:      200,
-		Headers:         headers,
-		IsBase64Encoded: true,
-		Body:            encodedString, // set the base64 encoded string as the body
+		StatusCode: http.StatusPermanentRedirect,
+		Headers: map[string]string{
+			"Location":                    location,
+			"Deprecation":                 "true",
+			"Link":                        fmt.Sprintf("<%s>; rel=\"successor-version\"", location),
+			"Cache-Control":               "private, no-store",
+			"Access-Control-Allow-Origin": "*",
+		},
 	}, nil
 }
 
 func main() {
-	log.Println("Lambda starting")  // <-- Log at the start of Lambda execution
+	logger.Init()
 	lambda.Start(handler)
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/bank"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/receipt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME = os.Getenv("TABLE_NAME")
+)
+
+// handler recibe el extracto en el cuerpo, tal como lo descarga el banco. ?formato=csv|ofx
+// fuerza el formato y ?mapping= reemplaza columnas de BANK_CSV_MAPPING para ese archivo.
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			log.Warn("Error decoding base64 request body", "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Error decoding base64: %s", err), StatusCode: 400}, nil
+		}
+	}
+
+	mapping, err := bank.LoadMapping(request.QueryStringParameters["mapping"])
+	if err != nil {
+		log.Warn("Invalid CSV mapping", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+	}
+
+	movimientos, err := bank.Parse(body, request.QueryStringParameters["formato"], mapping)
+	if err != nil {
+		log.Warn("Error parsing bank statement", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+	}
+
+	dynamoService := application.NewConciliacionServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	response, err := dynamoService.ImportStatement(movimientos)
+
+	// Los documentos aprobados por la conciliación emiten su constancia, aunque la
+	// importación haya fallado después; si falla puede pedirse luego a demanda
+	documentoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	for _, mov := range response.Detalle {
+		if mov.Estado != domain.EstadoMovimientoConciliado {
+			continue
+		}
+		if _, err := receipt.Generate(ctx, documentoService, mov.Documento_ID, false); err != nil {
+			log.Warn("error generating receipt", "id_documento", mov.Documento_ID, "error", err)
+		}
+	}
+
+	if err != nil {
+		log.Error("error importing bank statement", "error", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrExtractoVacio) {
+			status = http.StatusBadRequest
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	log.Info("Bank statement imported", "movimientos", response.Movimientos, "nuevos", response.Nuevos,
+		"duplicados", response.Duplicados, "conciliados", response.Conciliados, "propuestos", response.Propuestos)
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/auth"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/receipt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME = os.Getenv("TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			log.Warn("Error decoding base64 request body", "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Error decoding base64: %s", err), StatusCode: 400}, nil
+		}
+	}
+
+	var resolverRequest domain.ResolverMovimientoRequest
+	if err := json.Unmarshal(body, &resolverRequest); err != nil {
+		log.Warn("Error parsing request body as JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+	}
+
+	id_movimiento := request.PathParameters["id_movimiento"]
+
+	log.Info("Resolving movimiento", "id_movimiento", id_movimiento, "accion", resolverRequest.Accion,
+		"id_documento", resolverRequest.Documento_ID)
+
+	dynamoService := application.NewConciliacionServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	response, err := dynamoService.ResolveMovimiento(id_movimiento, resolverRequest, auth.FromRequest(request).Name())
+	if err != nil {
+		log.Error("error resolving movimiento", "error", err)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, domain.ErrMovimientoNotFound), errors.Is(err, domain.ErrDocumentoNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, domain.ErrMovimientoResuelto), errors.Is(err, domain.ErrArchivoRechazado):
+			status = http.StatusConflict
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	// La aprobación emite la constancia de pago; si falla puede pedirse luego a demanda
+	if response.Estado == domain.EstadoMovimientoConciliado {
+		documentoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+		if _, err := receipt.Generate(ctx, documentoService, response.Documento_ID, false); err != nil {
+			log.Warn("error generating receipt", "id_documento", response.Documento_ID, "error", err)
+		}
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
@@ -5,97 +5,464 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/extraction"
+	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/metrics"
+	"main/src/preview"
+	"main/src/scanner"
+	"main/src/tracing"
+	"main/src/upload"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-// FileData structure to match the JSON structure
-type FileData struct {
-	FileContents string `json:"file_contents"` // Contenido del archivo codificado en base64
-	FileName     string `json:"file_name"`     // Nombre real del archivo
-	RealFileName     string `json:"real_file_name"`     // Nombre real del archivo
-}
-// FileData structure to match the JSON structure
 var (
+	TABLE_NAME  = os.Getenv("TABLE_NAME")
 	BUCKET_NAME = os.Getenv("BUCKET_NAME")
-	BUCKET_KEY  = os.Getenv("BUCKET_KEY")
 )
 
+// fileScanner analiza cada archivo antes de guardarlo; se configura con SCANNER.
+var fileScanner scanner.Scanner = scanner.NoOp{}
+
+// Un productor solo puede entregar archivos que dejó en incoming/.
+var errSourceKey = errors.New("source key is outside incoming/")
+
+// pdfExtractor lee el texto de los comprobantes PDF; se configura con EXTRACT_*.
+var pdfExtractor *extraction.Extractor
+
 func handler(ctx context.Context, sqsEvent events.SQSEvent) error {
-	log.Println("SQS Lambda start")
+	slog.Info("SQS Lambda start", "records", len(sqsEvent.Records))
 
-	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(ctx)
+	// Reuse the S3 client across warm invocations
+	s3client, err := infrastructure.GetS3Client(ctx)
 	if err != nil {
-		log.Fatalf("Unable to load SDK config, %v", err)
+		slog.Error("Unable to get s3 client", "error", err)
+		return err
 	}
 
-	// Create an S3 service client
-	s3client := s3.NewFromConfig(cfg)
-
 	for _, message := range sqsEvent.Records {
-		log.Printf("Processing message %s for event source %s\n", message.MessageId, message.EventSource)
+		// Si el antivirus no responde el mensaje vuelve a la cola: con BatchSize 1 se
+		// reintenta solo ese archivo tras el VisibilityTimeout
+		if err := processMessage(ctx, s3client, message); err != nil {
+			return err
+		}
+	}
 
-		// Parse the JSON body into the FileData structure
-		var fileData FileData
-		err := json.Unmarshal([]byte(message.Body), &fileData)
-		if err != nil {
-			log.Printf("Error parsing JSON: %v\n", err)
-			continue
+	slog.Info("SQS Lambda end")
+	return nil
+}
+
+// processMessage devuelve error solo cuando el mensaje debe reintentarse.
+func processMessage(ctx context.Context, s3client *s3.Client, message events.SQSMessage) error {
+	ctx, end := tracing.StartMessage(ctx, message)
+	var err error
+	defer func() { end(err) }()
+
+	ctx, log := logger.WithSQSMessage(ctx, message)
+	tracing.Annotate(ctx, "correlation_id", logger.CorrelationID(ctx))
+	log.Info("Processing message", "event_source", message.EventSource, "trace_header", tracing.TraceHeader(ctx))
+
+	// Parse the JSON body into the FileMessage structure
+	var fileData infrastructure.FileMessage
+	err = json.Unmarshal([]byte(message.Body), &fileData)
+	if err != nil {
+		log.Error("Error parsing JSON", "error", err)
+		recordFailure("parse")
+		return nil
+	}
+	tracing.Annotate(ctx, "id_documento", fileData.RealFileName)
+
+	// Al deshacer una subida incompleta el productor elimina el documento o los adjuntos,
+	// pero los mensajes que ya entraron en la cola siguen llegando: se descartan
+	var pending bool
+	pending, err = isPending(ctx, fileData)
+	if err != nil {
+		log.Error("Error reading documento", "error", err)
+		recordFailure("dynamodb")
+		return err
+	}
+	if !pending {
+		log.Warn("File no longer belongs to its documento, discarding message", "key", fileData.Key)
+		recordFailure("not_found")
+		return nil
+	}
+
+	fileContent, err := readFile(ctx, s3client, fileData)
+	if err != nil {
+		log.Error("Error reading file", "source_key", fileData.SourceKey, "error", err)
+		// Si el archivo ya no está en incoming/ no tiene sentido reintentar
+		var noSuchKey *s3types.NoSuchKey
+		if fileData.SourceKey == "" || errors.Is(err, errSourceKey) || errors.As(err, &noSuchKey) {
+			recordFailure("decode")
+			return nil
 		}
+		recordFailure("s3")
+		return err
+	}
 
-		// Decode the base64 string to []byte
-		fileContent, err := base64.StdEncoding.DecodeString(fileData.FileContents)
-		if err != nil {
-			log.Printf("Error decoding base64: %v\n", err)
-			continue
-		}
-
-		// Log the file name
-		log.Println("File name:", fileData.FileName)
-		// Log the file name
-		log.Println("Real File name:", fileData.RealFileName)
-
-		// If the file content is not empty, store it in the S3 bucket
-		if len(fileContent) > 0 && fileData.FileName != "" {
-			log.Println("Storing file to S3 bucket")
-			fileExt := filepath.Ext(fileData.FileName)
-			key := BUCKET_KEY + fileData.RealFileName + fileExt
-			log.Println("Using S3 key:", key)
-
-			// Create a reader from the file content
-			reader := bytes.NewReader(fileContent)
-
-			// Prepare the PutObject input
-			input := &s3.PutObjectInput{
-				Bucket: aws.String(BUCKET_NAME),
-				Key:    aws.String(key),
-				Body:   reader,
+	log.Info("File received", "id_documento", fileData.RealFileName, "file_size", len(fileContent))
+
+	// If the file content is not empty, store it in the S3 bucket
+	if len(fileContent) > 0 && fileData.FileName != "" {
+		// La key asignada por el productor tiene prioridad; si no, se calcula con la
+		// extensión del contenido validado, no la del nombre enviado por el cliente
+		key := fileData.Key
+		if key == "" {
+			fileExt := domain.FileExtension(fileData.ContentType)
+			if fileExt == "" {
+				fileExt = filepath.Ext(fileData.FileName)
 			}
+			key = domain.DocumentoObjectKey(fileData.RealFileName, fileExt)
+		}
+		log.Info("Storing file to S3 bucket", "key", key)
+
+		// En las keys direccionadas por contenido el nombre debe coincidir con el hash real:
+		// otros documentos comparten ese objeto y servirían un archivo distinto
+		checksum := domain.ChecksumSHA256(fileContent)
+		if expected := domain.ChecksumFromKey(key); expected != "" && expected != checksum {
+			log.Error("Checksum mismatch between key and content, discarding file", "key", key, "checksum", checksum)
+			recordFailure("checksum")
+			return nil
+		}
+
+		contentType := fileData.ContentType
+		if contentType == "" {
+			contentType = upload.DetectContentType(fileContent)
+		}
+
+		// Ningún archivo llega a BUCKET_KEY sin pasar por el antivirus
+		var result scanner.Result
+		result, err = fileScanner.Scan(ctx, bytes.NewReader(fileContent))
+		switch scanner.Decide(result, err) {
+		case scanner.Retry:
+			log.Error("Error scanning file", "scanner", fileScanner.Name(), "error", err)
+			recordFailure("scan")
+			return err
+		case scanner.Quarantine:
+			err = quarantine(ctx, s3client, fileData, fileContent, contentType, checksum, result)
+			return err
+		}
+
+		if err = storeObject(ctx, s3client, key, contentType, fileContent); err != nil {
+			log.Error("Error while putting object to S3", "key", key, "error", err)
+			recordFailure("s3")
+			return err
+		}
 
-			// Upload the file to S3
-			output, err := s3client.PutObject(ctx, input)
-			if err != nil {
-				log.Printf("Error while putting object to S3: %v\n", err)
-				continue
+		// Las fotos del archivo principal se convierten a PDF, que pasa a ser el archivo
+		// canónico; la foto queda como adjunto secundario. Los mensajes sin key son del
+		// formato anterior y no tienen adjuntos que conservar.
+		converted := false
+		if fileData.IsPrincipal() && fileData.Key != "" && preview.ConvertEnabled() && preview.IsConvertible(contentType) {
+			converted = storeCanonicalPDF(ctx, s3client, fileData, fileContent, contentType)
+		}
+
+		// La key y la URL definitivas del archivo principal quedan persistidas en el documento,
+		// junto con el checksum que referencia el blob
+		if err = recordFile(ctx, fileData, key, checksum, converted); err != nil {
+			// Si el documento ya no existe no hay nada que reintentar
+			if errors.Is(err, domain.ErrDocumentoNotFound) {
+				log.Warn("Document deleted before its file was recorded", "key", key)
+				recordFailure("not_found")
+				return nil
 			}
-			log.Printf("Successfully stored to S3: %v\n", output)
-		} else {
-			log.Println("File content is empty or file name is missing")
+			log.Error("Error persisting file location", "key", key, "error", err)
+			recordFailure("dynamodb")
+			return err
 		}
+
+		// La miniatura es opcional: si falla, el documento sigue disponible sin vista previa
+		if fileData.IsPrincipal() {
+			storeThumbnail(ctx, s3client, fileData, fileContent, contentType, checksum)
+		}
+
+		// Igual que la miniatura, la extracción solo prellena la revisión y no es obligatoria
+		if fileData.IsPrincipal() && contentType == upload.ContentTypePDF && pdfExtractor != nil {
+			storeExtraction(ctx, fileData, fileContent)
+		}
+	} else {
+		log.Warn("File content is empty or file name is missing")
+		recordFailure("empty")
 	}
 
-	log.Println("SQS Lambda end")
+	recordLatency(message)
 	return nil
 }
 
+// readFile obtiene el contenido del archivo: de incoming/, donde lo dejó el productor, o
+// del propio mensaje en el formato anterior.
+func readFile(ctx context.Context, s3client *s3.Client, fileData infrastructure.FileMessage) ([]byte, error) {
+	if fileData.SourceKey == "" {
+		return base64.StdEncoding.DecodeString(fileData.FileContents)
+	}
+	if !domain.IsIncomingKey(fileData.SourceKey) {
+		return nil, fmt.Errorf("%w: %q", errSourceKey, fileData.SourceKey)
+	}
+
+	object, err := s3client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(fileData.SourceKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Body.Close()
+	return io.ReadAll(object.Body)
+}
+
+// isPending indica si el documento del mensaje existe y, en los mensajes con key
+// asignada, si todavía tiene un adjunto con esa key.
+func isPending(ctx context.Context, fileData infrastructure.FileMessage) (bool, error) {
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	documento, err := dynamoService.GetDocument(fileData.RealFileName)
+	if errors.Is(err, domain.ErrDocumentoNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if fileData.Key == "" {
+		return true, nil
+	}
+	for _, adjunto := range documento.Adjuntos {
+		if adjunto.Key == fileData.Key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func recordFile(ctx context.Context, fileData infrastructure.FileMessage, key, checksum string, converted bool) error {
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	if fileData.IsPrincipal() && !converted {
+		if _, err := dynamoService.UpdateFileLocation(fileData.RealFileName, key); err != nil {
+			return err
+		}
+	}
+	if err := dynamoService.RecordChecksum(fileData.RealFileName, checksum, fileData.IsPrincipal()); err != nil {
+		return err
+	}
+	return dynamoService.MarkFileClean(fileData.RealFileName)
+}
+
+// quarantine aísla un archivo infectado fuera de BUCKET_KEY y marca el documento como
+// rechazado. Devuelve error si no pudo completarse, para que el mensaje se reintente.
+func quarantine(ctx context.Context, s3client *s3.Client, fileData infrastructure.FileMessage, data []byte, contentType, checksum string, result scanner.Result) error {
+	log := logger.FromContext(ctx)
+	log.Warn("Malware detected in upload", "scanner", fileScanner.Name(), "signature", result.Signature, "checksum", checksum)
+	metrics.Increment(metrics.MalwareDetected, metrics.Dimensions{"scanner": fileScanner.Name()})
+
+	key := domain.QuarantineObjectKey(fileData.RealFileName, checksum, domain.FileExtension(contentType))
+	if err := storeObject(ctx, s3client, key, contentType, data); err != nil {
+		log.Error("Error storing quarantined file", "key", key, "error", err)
+		recordFailure("quarantine")
+		return err
+	}
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return err
+	}
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	if err := dynamoService.RejectFile(fileData.RealFileName, key, result.Signature); err != nil {
+		log.Error("Error rejecting document file", "key", key, "error", err)
+		recordFailure("quarantine")
+		if errors.Is(err, domain.ErrDocumentoNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	log.Info("File quarantined", "key", key)
+	return nil
+}
+
+// storeObject sube el archivo al bucket. Un objeto direccionado por contenido que ya
+// existe no se vuelve a subir: el mismo contenido comparte el objeto.
+func storeObject(ctx context.Context, s3client *s3.Client, key, contentType string, data []byte) error {
+	log := logger.FromContext(ctx)
+
+	if domain.ChecksumFromKey(key) != "" {
+		exists, err := infrastructure.ObjectExists(ctx, s3client, BUCKET_NAME, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			log.Info("Object already stored, skipping upload", "key", key)
+			metrics.Increment(metrics.DuplicateUploads, metrics.Dimensions{})
+			return nil
+		}
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	output, err := s3client.PutObject(ctx, input)
+	if err != nil {
+		return err
+	}
+	log.Info("Successfully stored to S3", "key", key, "etag", aws.ToString(output.ETag))
+	return nil
+}
+
+// storeCanonicalPDF convierte la foto en un PDF de una página, lo sube al bucket y lo
+// registra como archivo principal del documento. Si algo falla, la foto sigue siendo
+// el archivo principal.
+func storeCanonicalPDF(ctx context.Context, s3client *s3.Client, fileData infrastructure.FileMessage, data []byte, contentType string) bool {
+	log := logger.FromContext(ctx)
+
+	converted, err := preview.ToPDF(data, contentType, preview.ConvertOptions())
+	if err != nil {
+		log.Warn("Unable to convert image to PDF", "content_type", contentType, "error", err)
+		recordFailure("convert")
+		return false
+	}
+
+	nombre := strings.TrimSuffix(fileData.FileName, filepath.Ext(fileData.FileName)) + ".pdf"
+	adjunto := domain.NewAdjunto(nombre, upload.ContentTypePDF, converted)
+	adjunto.AssignKey(true)
+
+	if err := storeObject(ctx, s3client, adjunto.Key, adjunto.ContentType, converted); err != nil {
+		log.Warn("Error storing converted PDF", "key", adjunto.Key, "error", err)
+		recordFailure("convert")
+		return false
+	}
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Warn("Failed to get dynamodb client", "error", err)
+		return false
+	}
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	if _, err := dynamoService.SetCanonicalFile(fileData.RealFileName, adjunto); err != nil {
+		log.Warn("Error linking converted PDF", "key", adjunto.Key, "error", err)
+		recordFailure("convert")
+		return false
+	}
+
+	log.Info("Image converted to PDF", "key", adjunto.Key, "file_size", adjunto.Size)
+	metrics.Increment(metrics.ConvertedToPDF, metrics.Dimensions{"content_type": contentType})
+	return true
+}
+
+// storeThumbnail genera la miniatura del archivo principal bajo thumbnails/ y la enlaza al documento.
+func storeThumbnail(ctx context.Context, s3client *s3.Client, fileData infrastructure.FileMessage, data []byte, contentType, checksum string) {
+	log := logger.FromContext(ctx)
+
+	thumbnail, err := preview.Thumbnail(data, contentType, preview.DefaultOptions())
+	if err != nil {
+		log.Warn("Unable to generate thumbnail", "content_type", contentType, "error", err)
+		recordFailure("thumbnail")
+		return
+	}
+
+	key := domain.ThumbnailObjectKey(checksum)
+	if err := storeObject(ctx, s3client, key, preview.ContentType, thumbnail); err != nil {
+		log.Warn("Error storing thumbnail", "key", key, "error", err)
+		recordFailure("thumbnail")
+		return
+	}
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Warn("Failed to get dynamodb client", "error", err)
+		return
+	}
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	if err := dynamoService.UpdateThumbnail(fileData.RealFileName, key); err != nil {
+		log.Warn("Error linking thumbnail", "key", key, "error", err)
+		recordFailure("thumbnail")
+	}
+}
+
+// storeExtraction extrae el texto y los valores sugeridos del PDF principal y los guarda en el documento.
+func storeExtraction(ctx context.Context, fileData infrastructure.FileMessage, data []byte) {
+	log := logger.FromContext(ctx)
+
+	extraccion, err := pdfExtractor.Extract(data)
+	if err != nil {
+		log.Warn("Unable to extract PDF text", "error", err)
+		recordFailure("extract")
+		return
+	}
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Warn("Failed to get dynamodb client", "error", err)
+		return
+	}
+	dynamoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+	if err := dynamoService.UpdateExtraction(fileData.RealFileName, extraccion); err != nil {
+		log.Warn("Error storing PDF extraction", "error", err)
+		recordFailure("extract")
+		return
+	}
+
+	log.Info("PDF text extracted", "paginas", extraccion.Paginas, "caracteres", len(extraccion.Texto),
+		"numero_operacion", extraccion.NumeroOperacion != "", "monto", extraccion.Monto != "", "fecha", extraccion.Fecha != "")
+}
+
+// recordLatency publica el tiempo transcurrido desde que el mensaje se envió a la cola.
+func recordLatency(message events.SQSMessage) {
+	sent, err := strconv.ParseInt(message.Attributes["SentTimestamp"], 10, 64)
+	if err != nil {
+		return
+	}
+	latency := time.Since(time.UnixMilli(sent))
+	metrics.Emit(metrics.SQSProcessingLatency, float64(latency.Milliseconds()), metrics.Milliseconds, metrics.Dimensions{})
+}
+
+func recordFailure(reason string) {
+	metrics.Increment(metrics.SQSProcessingFailures, metrics.Dimensions{"motivo": reason})
+}
+
 func main() {
+	logger.Init()
+
+	var err error
+	if fileScanner, err = scanner.New(); err != nil {
+		slog.Error("Invalid scanner configuration", "error", err)
+		os.Exit(1)
+	}
+	if extraction.Enabled() {
+		if pdfExtractor, err = extraction.New(); err != nil {
+			slog.Error("Invalid extraction configuration", "error", err)
+			os.Exit(1)
+		}
+	}
 	lambda.Start(handler)
 }
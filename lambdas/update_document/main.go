@@ -4,14 +4,17 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 
-	"log"
-
 	"main/src/application"
+	"main/src/auth"
 	"main/src/domain"
 	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/receipt"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -22,9 +25,11 @@ var (
 )
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
 	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
 	if err != nil {
-		log.Fatalln("Failed to get dynamodb client")
+		log.Error("Failed to get dynamodb client", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
 			StatusCode: 504}, nil
 	}
@@ -33,12 +38,12 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 	decodedBody, err := base64.StdEncoding.DecodeString(request.Body)
 	if err != nil {
-		log.Println("Error decoding base64 request body.")
+		log.Warn("Error decoding base64 request body", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Error decoding base64: %s", err), StatusCode: 400}, nil
 	}
 
 	if err := json.Unmarshal(decodedBody, &documentoRequest); err != nil {
-		log.Println("Error parsing request body as JSON.")
+		log.Warn("Error parsing request body as JSON", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 502}, nil
 	}
 
@@ -46,15 +51,41 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 	id_documento := request.PathParameters["id_documento"]
 
+	// El aprobador autenticado tiene prioridad sobre el enviado en el cuerpo
+	if claimant := auth.FromRequest(request).Name(); claimant != "" {
+		documentoRequest.AprobadoPor = claimant
+	}
+
 	response, err := dynamoService.UpdateDocument(documentoRequest,id_documento)
 	if err != nil {
-		log.Printf("error creating documento in database :%s\n", err)
-		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+		log.Error("error updating documento in database", "error", err)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, domain.ErrDocumentoNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, domain.ErrDocumentoAplicado):
+			status = http.StatusConflict
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	// La aprobación emite la constancia de pago; si falla puede pedirse luego a demanda
+	if domain.IsAprobado(response.StateDocument) {
+		generated, err := receipt.Generate(ctx, dynamoService, id_documento, false)
+		if err != nil {
+			log.Warn("error generating receipt", "id_documento", id_documento, "error", err)
+		} else {
+			response.NumeroRecibo = generated.NumeroRecibo
+			response.ReciboKey = generated.ReciboKey
+			response.UrlRecibo = generated.UrlRecibo
+			response.AprobadoPor = generated.AprobadoPor
+			response.FechaAprobacion = generated.FechaAprobacion
+		}
 	}
 
 	responseBody, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("error marshaling response to JSON: %s\n", err)
+		log.Error("error marshaling response to JSON", "error", err)
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
 	}
 
@@ -73,5 +104,6 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 }
 
 func main() {
+	logger.Init()
 	lambda.Start(handler)
 }
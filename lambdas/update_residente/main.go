@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var (
+	TABLE_NAME           = os.Getenv("TABLE_NAME")
+	RESIDENTE_TABLE_NAME = os.Getenv("RESIDENTE_TABLE_NAME")
+)
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, log := logger.WithAPIGatewayRequest(ctx, request)
+
+	dynamoClient, err := infrastructure.GetDynamoClient(ctx)
+	if err != nil {
+		log.Error("Failed to get dynamodb client", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Failed to get dynamodb client %s", err),
+			StatusCode: 504}, nil
+	}
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			log.Warn("Error decoding base64 request body", "error", err)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("Error decoding base64: %s", err), StatusCode: 400}, nil
+		}
+	}
+
+	var residenteRequest domain.ResidenteRequest
+	if err := json.Unmarshal(body, &residenteRequest); err != nil {
+		log.Warn("Error parsing request body as JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 400}, nil
+	}
+
+	dynamoService := application.NewResidenteServiceDynamo(dynamoClient, RESIDENTE_TABLE_NAME, ctx)
+
+	id_residente := request.PathParameters["id_residente"]
+
+	previous, err := dynamoService.GetResidente(id_residente)
+	if err != nil {
+		log.Error("error getting residente from database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrResidenteNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	response, err := dynamoService.UpdateResidente(residenteRequest, id_residente)
+	if err != nil {
+		log.Error("error updating residente in database", "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrResidenteNotFound) {
+			status = http.StatusNotFound
+		}
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: status}, nil
+	}
+
+	// Los documentos guardan una copia del nombre para mostrarlo; se actualiza si cambió
+	if response.Nombre != previous.Nombre {
+		documentoService := application.NewDocumentoServiceDynamo(dynamoClient, TABLE_NAME, ctx)
+		updated, err := documentoService.RenameResidente(id_residente, response.Nombre)
+		if err != nil {
+			log.Error("error renaming residente in documents", "error", err, "updated", updated)
+			return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+		}
+		log.Info("Residente renamed in documents", "id_residente", id_residente, "updated", updated)
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Error("error marshaling response to JSON", "error", err)
+		return events.APIGatewayProxyResponse{Body: fmt.Sprintf("%s", err), StatusCode: 500}, nil
+	}
+
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "DELETE,GET,HEAD,POST,PUT",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Content-Type":                 "application/json",
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    headers,
+		Body:       string(responseBody),
+		StatusCode: 200,
+	}, nil
+}
+
+func main() {
+	logger.Init()
+	lambda.Start(handler)
+}
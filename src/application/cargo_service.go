@@ -0,0 +1,11 @@
+package application
+
+import "main/src/domain"
+
+type CargoService interface {
+	GenerateCargos(domain.FacturacionRequest) (domain.Facturacion, error)
+	GetAllCargos(string, string) ([]domain.CargoResponse, error)
+	GetCargo(string) (domain.CargoResponse, error)
+	GetSaldo(string) (domain.SaldoDepartamento, error)
+	HasDepartamentoCargos(string) (bool, error)
+}
@@ -0,0 +1,308 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"main/src/domain"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// Índices globales de la tabla de cargos; ambos ordenan por periodo cuando aplica.
+	cargoDepartamentoIndex = "departamento-periodo-index"
+	cargoPeriodoIndex      = "periodo-index"
+)
+
+type CargoServiceDynamo struct {
+	client *dynamodb.Client
+	table  string
+	ctx    context.Context
+}
+
+// GenerateCargos crea los cargos del periodo para cada departamento registrado. Los
+// cargos que ya existen no se tocan, así que puede ejecutarse varias veces.
+func (dynamo CargoServiceDynamo) GenerateCargos(req domain.FacturacionRequest) (response domain.Facturacion, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "CargoService.GenerateCargos")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "periodo", req.Periodo)
+
+	periodo, err := domain.ParsePeriodo(req.Periodo)
+	if err != nil {
+		return domain.Facturacion{Message: err.Error()}, err
+	}
+	if DEPARTAMENTO_TABLE_NAME == "" || SERVICIO_TABLE_NAME == "" {
+		err = domain.ErrFacturacionNoConfigurada
+		return domain.Facturacion{Message: err.Error()}, err
+	}
+
+	servicios, err := dynamo.serviciosDelPeriodo(ctx, req, periodo)
+	if err != nil {
+		return domain.Facturacion{Message: err.Error()}, err
+	}
+	departamentos, err := NewDepartamentoServiceDynamo(dynamo.client, DEPARTAMENTO_TABLE_NAME, ctx).scanDepartamentos(ctx)
+	if err != nil {
+		return domain.Facturacion{Message: err.Error()}, err
+	}
+
+	// El ID del cargo es determinista: si ya existe no se sobrescribe lo pagado
+	condition := expression.AttributeNotExists(expression.Name("id_cargo"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.Facturacion{Message: err.Error()}, err
+	}
+
+	response = domain.Facturacion{Periodo: periodo.Format("2006-01"), Cargos: []domain.CargoResponse{}}
+	for _, departamento := range departamentos {
+		for _, servicio := range servicios {
+			cargo, err := domain.NuevoCargo(departamento, servicio, periodo)
+			if err != nil {
+				return domain.Facturacion{Message: err.Error()}, err
+			}
+
+			item, err := attributevalue.MarshalMap(cargo)
+			if err != nil {
+				return domain.Facturacion{Message: err.Error()}, err
+			}
+
+			_, err = dynamo.client.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName:                aws.String(dynamo.table),
+				Item:                     item,
+				ExpressionAttributeNames: expr.Names(),
+				ConditionExpression:      expr.Condition(),
+			})
+			if err != nil {
+				var conditionErr *types.ConditionalCheckFailedException
+				if errors.As(err, &conditionErr) {
+					response.Existentes++
+					continue
+				}
+				return domain.Facturacion{Message: err.Error()}, err
+			}
+
+			response.Creados++
+			response.Cargos = append(response.Cargos, cargo.ToCargoResponse())
+		}
+	}
+
+	return response, nil
+}
+
+// serviciosDelPeriodo elige los servicios a cobrar: los listados en la solicitud o, sin
+// lista, los activos a los que les toca el mes.
+func (dynamo CargoServiceDynamo) serviciosDelPeriodo(ctx context.Context, req domain.FacturacionRequest, periodo time.Time) ([]domain.Servicio, error) {
+	catalogo := NewServicioServiceDynamo(dynamo.client, SERVICIO_TABLE_NAME, ctx)
+
+	if len(req.Servicios) == 0 {
+		todos, err := catalogo.scanServicios(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var servicios []domain.Servicio
+		for _, servicio := range todos {
+			if servicio.Activo && servicio.AplicaEnPeriodo(periodo) {
+				servicios = append(servicios, servicio)
+			}
+		}
+		return servicios, nil
+	}
+
+	servicios := make([]domain.Servicio, 0, len(req.Servicios))
+	for _, codigo := range req.Servicios {
+		servicio, err := catalogo.getServicio(ctx, domain.NormalizeServicioCodigo(codigo))
+		if errors.Is(err, domain.ErrServicioNotFound) {
+			return nil, domain.ErrServicioInexistente
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !servicio.Activo {
+			return nil, domain.ErrServicioInactivo
+		}
+		servicios = append(servicios, servicio)
+	}
+	return servicios, nil
+}
+
+// GetAllCargos lista los cargos, opcionalmente de un departamento y/o de un periodo.
+func (dynamo CargoServiceDynamo) GetAllCargos(departamento string, periodo string) (cargosResponse []domain.CargoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "CargoService.GetAllCargos")
+	defer func() { end(err) }()
+
+	if periodo != "" {
+		if _, err = domain.ParsePeriodo(periodo); err != nil {
+			return nil, err
+		}
+	}
+
+	cargos, err := dynamo.queryCargos(ctx, domain.NormalizeDepartamentoID(departamento), periodo)
+	if err != nil {
+		return nil, err
+	}
+
+	domain.OrdenarCargos(cargos)
+	for _, cargo := range cargos {
+		cargosResponse = append(cargosResponse, cargo.ToCargoResponse())
+	}
+	return cargosResponse, nil
+}
+
+func (dynamo CargoServiceDynamo) GetCargo(id string) (response domain.CargoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "CargoService.GetCargo")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_cargo", id)
+
+	cargo, err := dynamo.getCargo(ctx, id)
+	if err != nil {
+		return domain.CargoResponse{}, err
+	}
+
+	return cargo.ToCargoResponse(), nil
+}
+
+// GetSaldo arma el estado de cuenta del departamento con todos sus cargos.
+func (dynamo CargoServiceDynamo) GetSaldo(departamento string) (response domain.SaldoDepartamento, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "CargoService.GetSaldo")
+	defer func() { end(err) }()
+
+	departamento = domain.NormalizeDepartamentoID(departamento)
+	tracing.Annotate(ctx, "id_departamento", departamento)
+
+	if err = checkDepartamentoExists(ctx, dynamo.client, departamento); err != nil {
+		return domain.SaldoDepartamento{Message: err.Error()}, err
+	}
+
+	cargos, err := dynamo.queryCargos(ctx, departamento, "")
+	if err != nil {
+		return domain.SaldoDepartamento{Message: err.Error()}, err
+	}
+
+	return domain.CalcularSaldo(departamento, cargos), nil
+}
+
+// HasDepartamentoCargos indica si el departamento tiene cargos registrados.
+func (dynamo CargoServiceDynamo) HasDepartamentoCargos(departamento string) (found bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "CargoService.HasDepartamentoCargos")
+	defer func() { end(err) }()
+
+	keyCondition := expression.Key("departamento").Equal(expression.Value(departamento))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return false, err
+	}
+
+	output, err := dynamo.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamo.table),
+		IndexName:                 aws.String(cargoDepartamentoIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(output.Items) > 0, nil
+}
+
+// queryCargos usa el índice que corresponde a los filtros; sin filtros recorre la tabla.
+func (dynamo CargoServiceDynamo) queryCargos(ctx context.Context, departamento string, periodo string) ([]domain.Cargo, error) {
+	var cargos []domain.Cargo
+	add := func(items []map[string]types.AttributeValue) error {
+		var page []domain.Cargo
+		if err := attributevalue.UnmarshalListOfMaps(items, &page); err != nil {
+			return err
+		}
+		cargos = append(cargos, page...)
+		return nil
+	}
+
+	if departamento == "" && periodo == "" {
+		paginator := dynamodb.NewScanPaginator(dynamo.client, &dynamodb.ScanInput{TableName: aws.String(dynamo.table)})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(output.Items); err != nil {
+				return nil, err
+			}
+		}
+		return cargos, nil
+	}
+
+	index := cargoPeriodoIndex
+	keyCondition := expression.Key("periodo").Equal(expression.Value(periodo))
+	if departamento != "" {
+		index = cargoDepartamentoIndex
+		keyCondition = expression.Key("departamento").Equal(expression.Value(departamento))
+		if periodo != "" {
+			keyCondition = keyCondition.And(expression.Key("periodo").Equal(expression.Value(periodo)))
+		}
+	}
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(dynamo.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamo.table),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(output.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	return cargos, nil
+}
+
+func (dynamo CargoServiceDynamo) getCargo(ctx context.Context, id string) (domain.Cargo, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dynamo.table),
+		Key:       cargoKey(id),
+	}
+
+	output, err := dynamo.client.GetItem(ctx, input)
+	if err != nil {
+		return domain.Cargo{}, err
+	}
+	if output.Item == nil {
+		return domain.Cargo{}, domain.ErrCargoNotFound
+	}
+
+	var cargo domain.Cargo
+	if err := attributevalue.UnmarshalMap(output.Item, &cargo); err != nil {
+		return domain.Cargo{}, err
+	}
+
+	return cargo, nil
+}
+
+func cargoKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id_cargo": &types.AttributeValueMemberS{Value: id}}
+}
+
+func NewCargoServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *CargoServiceDynamo {
+	return &CargoServiceDynamo{
+		client: client,
+		table:  table,
+		ctx:    ctx,
+	}
+}
@@ -0,0 +1,9 @@
+package application
+
+import "main/src/domain"
+
+type ConciliacionService interface {
+	ImportStatement([]domain.MovimientoBancario) (domain.Importacion, error)
+	GetAllMovimientos(string) ([]domain.MovimientoBancario, error)
+	ResolveMovimiento(string, domain.ResolverMovimientoRequest, string) (domain.MovimientoBancario, error)
+}
@@ -0,0 +1,430 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"main/src/domain"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Índice global de la tabla de movimientos por estado.
+const movimientoEstadoIndex = "estado-index"
+
+// ConciliacionServiceDynamo concilia los movimientos bancarios (MOVIMIENTO_TABLE_NAME)
+// con los documentos de la tabla de documentos.
+type ConciliacionServiceDynamo struct {
+	client *dynamodb.Client
+	table  string
+	ctx    context.Context
+}
+
+// ImportStatement guarda los movimientos del extracto y busca el documento de cada uno.
+// Los movimientos ya importados se cuentan como duplicados; los que siguen sin
+// coincidencia se vuelven a emparejar. Las coincidencias exactas aprueban el documento
+// y las demás quedan como propuestas. Si falla a mitad del extracto devuelve también el
+// detalle procesado, para que el llamador emita las constancias de lo ya aprobado.
+func (dynamo ConciliacionServiceDynamo) ImportStatement(movimientos []domain.MovimientoBancario) (response domain.Importacion, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ConciliacionService.ImportStatement")
+	defer func() { end(err) }()
+
+	if MOVIMIENTO_TABLE_NAME == "" {
+		err = domain.ErrConciliacionNoConfigurada
+		return domain.Importacion{Message: err.Error()}, err
+	}
+	if len(movimientos) == 0 {
+		err = domain.ErrExtractoVacio
+		return domain.Importacion{Message: err.Error()}, err
+	}
+
+	pendientes, err := dynamo.documentosPendientes(ctx)
+	if err != nil {
+		return domain.Importacion{Message: err.Error()}, err
+	}
+	usados, err := dynamo.documentosPropuestos(ctx)
+	if err != nil {
+		return domain.Importacion{Message: err.Error()}, err
+	}
+
+	condition := expression.AttributeNotExists(expression.Name("id_movimiento"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.Importacion{Message: err.Error()}, err
+	}
+
+	documentos := NewDocumentoServiceDynamo(dynamo.client, dynamo.table, ctx)
+	ventana, autoAprobar := domain.VentanaConciliacion(), domain.AutoAprobar()
+	fecha := time.Now().UTC().Format(time.RFC3339)
+
+	response = domain.Importacion{Movimientos: len(movimientos), Detalle: []domain.MovimientoBancario{}}
+	for _, mov := range movimientos {
+		mov.Estado = domain.EstadoMovimientoSinCoincidencia
+		mov.FechaImportacion = fecha
+
+		// Se guarda antes de emparejar para que un extracto repetido no apruebe dos veces
+		item, err := attributevalue.MarshalMap(mov)
+		if err != nil {
+			return domain.Importacion{Message: err.Error()}, err
+		}
+		_, err = dynamo.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(MOVIMIENTO_TABLE_NAME),
+			Item:                     item,
+			ExpressionAttributeNames: expr.Names(),
+			ConditionExpression:      expr.Condition(),
+		})
+		if err != nil {
+			var conditionErr *types.ConditionalCheckFailedException
+			if !errors.As(err, &conditionErr) {
+				response.Message = err.Error()
+				return response, err
+			}
+			response.Duplicados++
+
+			// Un movimiento que quedó sin coincidencia, por ejemplo porque la importación
+			// anterior falló antes de emparejarlo, se vuelve a emparejar
+			existente, err := dynamo.getMovimiento(ctx, mov.Movimiento_ID)
+			if err != nil {
+				response.Message = err.Error()
+				return response, err
+			}
+			if existente.Estado != domain.EstadoMovimientoSinCoincidencia {
+				continue
+			}
+			mov = existente
+		} else {
+			response.Nuevos++
+		}
+
+		coincidencia, ok := domain.Emparejar(mov, pendientes, ventana, usados)
+		if ok {
+			usados[coincidencia.Documento_ID] = true
+			mov.Documento_ID = coincidencia.Documento_ID
+			mov.Motivo = coincidencia.Motivo
+			mov.Estado = domain.EstadoMovimientoPropuesto
+
+			if coincidencia.Exacta && autoAprobar {
+				_, err := documentos.ApproveMatchedDocument(coincidencia.Documento, domain.AprobadorConciliacion)
+				switch {
+				case err == nil:
+					mov.Estado = domain.EstadoMovimientoConciliado
+				case errors.Is(err, domain.ErrDocumentoAprobado), errors.Is(err, domain.ErrDocumentoNotFound),
+					errors.Is(err, domain.ErrArchivoRechazado), errors.Is(err, domain.ErrAprobacionAutomatica):
+					// Otro usuario lo cambió, aprobó o eliminó mientras tanto, o su archivo no
+					// está limpio: queda para revisión
+				default:
+					response.Message = err.Error()
+					return response, err
+				}
+			}
+
+			err := dynamo.updateMovimiento(ctx, mov, domain.EstadoMovimientoSinCoincidencia)
+			if errors.Is(err, domain.ErrMovimientoResuelto) {
+				// Otra importación del mismo extracto lo emparejó primero; si aun así este
+				// documento se aprobó aquí, se devuelve para emitir su constancia
+				if mov.Estado == domain.EstadoMovimientoConciliado {
+					response.Detalle = append(response.Detalle, mov)
+				}
+				continue
+			}
+			if err != nil {
+				// El documento pudo quedar aprobado: se devuelve para emitir su constancia
+				response.Detalle = append(response.Detalle, mov)
+				response.Message = err.Error()
+				return response, err
+			}
+		}
+
+		switch mov.Estado {
+		case domain.EstadoMovimientoConciliado:
+			response.Conciliados++
+		case domain.EstadoMovimientoPropuesto:
+			response.Propuestos++
+		default:
+			response.SinCoincidencia++
+		}
+		response.Detalle = append(response.Detalle, mov)
+	}
+
+	return response, nil
+}
+
+// GetAllMovimientos lista los movimientos, opcionalmente solo los de un estado.
+func (dynamo ConciliacionServiceDynamo) GetAllMovimientos(estado string) (movimientos []domain.MovimientoBancario, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ConciliacionService.GetAllMovimientos")
+	defer func() { end(err) }()
+
+	if MOVIMIENTO_TABLE_NAME == "" {
+		return nil, domain.ErrConciliacionNoConfigurada
+	}
+
+	movimientos, err = dynamo.queryMovimientos(ctx, strings.ToLower(strings.TrimSpace(estado)))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(movimientos, func(i, j int) bool {
+		if movimientos[i].Fecha != movimientos[j].Fecha {
+			return movimientos[i].Fecha < movimientos[j].Fecha
+		}
+		return movimientos[i].Movimiento_ID < movimientos[j].Movimiento_ID
+	})
+	return movimientos, nil
+}
+
+// ResolveMovimiento confirma o descarta la propuesta de un movimiento. Confirmar aprueba
+// el documento, que puede ser el propuesto u otro indicado por el usuario.
+func (dynamo ConciliacionServiceDynamo) ResolveMovimiento(id string, req domain.ResolverMovimientoRequest, usuario string) (mov domain.MovimientoBancario, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ConciliacionService.ResolveMovimiento")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_movimiento", id)
+
+	if MOVIMIENTO_TABLE_NAME == "" {
+		return domain.MovimientoBancario{}, domain.ErrConciliacionNoConfigurada
+	}
+
+	mov, err = dynamo.getMovimiento(ctx, id)
+	if err != nil {
+		return domain.MovimientoBancario{}, err
+	}
+	if mov.Resuelto() {
+		return domain.MovimientoBancario{}, domain.ErrMovimientoResuelto
+	}
+	anterior := mov.Estado
+
+	switch strings.ToLower(strings.TrimSpace(req.Accion)) {
+	case domain.AccionDescartar:
+		mov.Estado = domain.EstadoMovimientoDescartado
+
+	case domain.AccionConfirmar:
+		if req.Documento_ID != "" && req.Documento_ID != mov.Documento_ID {
+			mov.Documento_ID = req.Documento_ID
+			mov.Motivo = "elegido manualmente"
+		}
+		if mov.Documento_ID == "" {
+			return domain.MovimientoBancario{}, domain.ErrMovimientoSinDocumento
+		}
+
+		documentos := NewDocumentoServiceDynamo(dynamo.client, dynamo.table, ctx)
+		documento, err := documentos.getDocumento(ctx, mov.Documento_ID)
+		if err != nil {
+			return domain.MovimientoBancario{}, err
+		}
+		if documento.Monto != mov.Monto || documento.Moneda != mov.Moneda {
+			return domain.MovimientoBancario{}, domain.ErrMovimientoDocumento
+		}
+
+		aprobador := usuario
+		if aprobador == "" {
+			aprobador = domain.AprobadorConciliacion
+		}
+		// Un documento ya aprobado a mano igual puede conciliarse con su movimiento
+		if _, err := documentos.ApproveDocument(mov.Documento_ID, aprobador); err != nil && !errors.Is(err, domain.ErrDocumentoAprobado) {
+			return domain.MovimientoBancario{}, err
+		}
+		mov.Estado = domain.EstadoMovimientoConciliado
+
+	default:
+		return domain.MovimientoBancario{}, domain.ErrAccionInvalida
+	}
+
+	if err = dynamo.updateMovimiento(ctx, mov, anterior); err != nil {
+		return domain.MovimientoBancario{}, err
+	}
+	return mov, nil
+}
+
+// updateMovimiento guarda el resultado de la conciliación si el movimiento sigue en el
+// estado leído; si otro proceso lo resolvió antes devuelve ErrMovimientoResuelto.
+func (dynamo ConciliacionServiceDynamo) updateMovimiento(ctx context.Context, mov domain.MovimientoBancario, anterior string) error {
+	update := expression.
+		Set(expression.Name("estado"), expression.Value(mov.Estado))
+	if mov.Documento_ID != "" {
+		update = update.
+			Set(expression.Name("id_documento"), expression.Value(mov.Documento_ID)).
+			Set(expression.Name("motivo"), expression.Value(mov.Motivo))
+	}
+	condition := expression.Name("estado").Equal(expression.Value(anterior))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(MOVIMIENTO_TABLE_NAME),
+		Key:                       movimientoKey(mov.Movimiento_ID),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return domain.ErrMovimientoResuelto
+		}
+		return err
+	}
+	return nil
+}
+
+// documentosPendientes lee los campos que usa el emparejador de los documentos con monto
+// que esperan revisión; los rechazados y los de archivo con malware no se emparejan.
+func (dynamo ConciliacionServiceDynamo) documentosPendientes(ctx context.Context) ([]domain.Documento, error) {
+	filter := expression.AttributeExists(expression.Name("monto"))
+	projection := expression.NamesList(
+		expression.Name("id_documento"),
+		expression.Name("monto"),
+		expression.Name("moneda"),
+		expression.Name("fecha_de_pago"),
+		expression.Name("estado_documento"),
+		expression.Name("estado_archivo"),
+		expression.Name("extraccion.numero_operacion"),
+	)
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(dynamo.table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	var documentos []domain.Documento
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []domain.Documento
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		// Los documentos cuyo archivo aún no se analizó no se emparejan
+		for _, documento := range page {
+			if documento.EnRevision() && documento.EstadoArchivo != domain.EstadoArchivoPendiente {
+				documentos = append(documentos, documento)
+			}
+		}
+	}
+
+	return documentos, nil
+}
+
+// documentosPropuestos marca como usados los documentos que ya esperan confirmación en
+// otro movimiento, para no proponer el mismo pago dos veces.
+func (dynamo ConciliacionServiceDynamo) documentosPropuestos(ctx context.Context) (map[string]bool, error) {
+	propuestos, err := dynamo.queryMovimientos(ctx, domain.EstadoMovimientoPropuesto)
+	if err != nil {
+		return nil, err
+	}
+
+	usados := map[string]bool{}
+	for _, mov := range propuestos {
+		if mov.Documento_ID != "" {
+			usados[mov.Documento_ID] = true
+		}
+	}
+	return usados, nil
+}
+
+// queryMovimientos usa el índice por estado; sin estado recorre la tabla.
+func (dynamo ConciliacionServiceDynamo) queryMovimientos(ctx context.Context, estado string) ([]domain.MovimientoBancario, error) {
+	var movimientos []domain.MovimientoBancario
+	add := func(items []map[string]types.AttributeValue) error {
+		var page []domain.MovimientoBancario
+		if err := attributevalue.UnmarshalListOfMaps(items, &page); err != nil {
+			return err
+		}
+		movimientos = append(movimientos, page...)
+		return nil
+	}
+
+	if estado == "" {
+		paginator := dynamodb.NewScanPaginator(dynamo.client, &dynamodb.ScanInput{TableName: aws.String(MOVIMIENTO_TABLE_NAME)})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(output.Items); err != nil {
+				return nil, err
+			}
+		}
+		return movimientos, nil
+	}
+
+	keyCondition := expression.Key("estado").Equal(expression.Value(estado))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(dynamo.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(MOVIMIENTO_TABLE_NAME),
+		IndexName:                 aws.String(movimientoEstadoIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(output.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	return movimientos, nil
+}
+
+func (dynamo ConciliacionServiceDynamo) getMovimiento(ctx context.Context, id string) (domain.MovimientoBancario, error) {
+	output, err := dynamo.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(MOVIMIENTO_TABLE_NAME),
+		Key:       movimientoKey(id),
+	})
+	if err != nil {
+		return domain.MovimientoBancario{}, err
+	}
+	if output.Item == nil {
+		return domain.MovimientoBancario{}, domain.ErrMovimientoNotFound
+	}
+
+	var mov domain.MovimientoBancario
+	if err := attributevalue.UnmarshalMap(output.Item, &mov); err != nil {
+		return domain.MovimientoBancario{}, err
+	}
+
+	return mov, nil
+}
+
+func movimientoKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id_movimiento": &types.AttributeValueMemberS{Value: id}}
+}
+
+func NewConciliacionServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *ConciliacionServiceDynamo {
+	return &ConciliacionServiceDynamo{
+		client: client,
+		table:  table,
+		ctx:    ctx,
+	}
+}
@@ -0,0 +1,12 @@
+package application
+
+import "main/src/domain"
+
+type DepartamentoService interface {
+	CreateDepartamento(domain.DepartamentoRequest) (domain.DepartamentoResponse, error)
+	GetAllDepartamentos() ([]domain.DepartamentoResponse, error)
+	GetDepartamento(string) (domain.DepartamentoResponse, error)
+	UpdateDepartamento(domain.DepartamentoRequest, string) (domain.DepartamentoResponse, error)
+	DeleteDepartamento(string) (domain.DepartamentoResponse, error)
+	Exists(string) (bool, error)
+}
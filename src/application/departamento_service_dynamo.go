@@ -0,0 +1,259 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"main/src/domain"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DepartamentoServiceDynamo struct {
+	client *dynamodb.Client
+	table  string
+	ctx    context.Context
+}
+
+func (dynamo DepartamentoServiceDynamo) CreateDepartamento(req domain.DepartamentoRequest) (response domain.DepartamentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DepartamentoService.CreateDepartamento")
+	defer func() { end(err) }()
+
+	if err = req.Validate(); err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	departamento := req.ToDepartamento()
+	tracing.Annotate(ctx, "id_departamento", departamento.Departamento_ID)
+
+	item, err := attributevalue.MarshalMap(departamento)
+	if err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	// El ID sale de torre y número: no se sobrescribe un departamento ya registrado
+	condition := expression.AttributeNotExists(expression.Name("id_departamento"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(dynamo.table),
+		Item:                     item,
+		ExpressionAttributeNames: expr.Names(),
+		ConditionExpression:      expr.Condition(),
+	}
+
+	_, err = dynamo.client.PutItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDepartamentoExists
+		}
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	return departamento.ToDepartamentoResponse(), nil
+}
+
+func (dynamo DepartamentoServiceDynamo) GetAllDepartamentos() (departamentosResponse []domain.DepartamentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DepartamentoService.GetAllDepartamentos")
+	defer func() { end(err) }()
+
+	departamentos, err := dynamo.scanDepartamentos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, departamento := range departamentos {
+		departamentosResponse = append(departamentosResponse, departamento.ToDepartamentoResponse())
+	}
+
+	return departamentosResponse, nil
+}
+
+func (dynamo DepartamentoServiceDynamo) scanDepartamentos(ctx context.Context) ([]domain.Departamento, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(dynamo.table),
+	}
+
+	var departamentos []domain.Departamento
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []domain.Departamento
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		departamentos = append(departamentos, page...)
+	}
+
+	return departamentos, nil
+}
+
+func (dynamo DepartamentoServiceDynamo) GetDepartamento(id string) (response domain.DepartamentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DepartamentoService.GetDepartamento")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_departamento", id)
+
+	departamento, err := dynamo.getDepartamento(ctx, id)
+	if err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	return departamento.ToDepartamentoResponse(), nil
+}
+
+// UpdateDepartamento reemplaza los datos del departamento; la torre y el número forman
+// su ID y no pueden cambiarse.
+func (dynamo DepartamentoServiceDynamo) UpdateDepartamento(req domain.DepartamentoRequest, id string) (response domain.DepartamentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DepartamentoService.UpdateDepartamento")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_departamento", id)
+
+	if err = req.Validate(); err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	update := expression.
+		Set(expression.Name("piso"), expression.Value(req.Piso)).
+		Set(expression.Name("area"), expression.Value(req.Area))
+	if req.Propietario != "" {
+		update = update.Set(expression.Name("propietario"), expression.Value(req.Propietario))
+	} else {
+		update = update.Remove(expression.Name("propietario"))
+	}
+	if len(req.Ocupantes) > 0 {
+		update = update.Set(expression.Name("ocupantes"), expression.Value(req.Ocupantes))
+	} else {
+		update = update.Remove(expression.Name("ocupantes"))
+	}
+	condition := expression.AttributeExists(expression.Name("id_departamento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       departamentoKey(domain.NormalizeDepartamentoID(id)),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDepartamentoNotFound
+		}
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	var departamento domain.Departamento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &departamento); err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	return departamento.ToDepartamentoResponse(), nil
+}
+
+func (dynamo DepartamentoServiceDynamo) DeleteDepartamento(id string) (response domain.DepartamentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DepartamentoService.DeleteDepartamento")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_departamento", id)
+
+	condition := expression.AttributeExists(expression.Name("id_departamento"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName:                aws.String(dynamo.table),
+		Key:                      departamentoKey(domain.NormalizeDepartamentoID(id)),
+		ExpressionAttributeNames: expr.Names(),
+		ConditionExpression:      expr.Condition(),
+		ReturnValues:             types.ReturnValueAllOld,
+	}
+
+	output, err := dynamo.client.DeleteItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDepartamentoNotFound
+		}
+		return domain.DepartamentoResponse{Message: err.Error()}, err
+	}
+
+	var deleted domain.Departamento
+	attributevalue.UnmarshalMap(output.Attributes, &deleted)
+
+	response = deleted.ToDepartamentoResponse()
+	response.Message = fmt.Sprintf("Departamento: %s eliminado", deleted.Departamento_ID)
+
+	return response, nil
+}
+
+// Exists indica si el departamento está registrado; lo usan los documentos para
+// validar la referencia.
+func (dynamo DepartamentoServiceDynamo) Exists(id string) (exists bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DepartamentoService.Exists")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_departamento", id)
+
+	_, err = dynamo.getDepartamento(ctx, id)
+	if errors.Is(err, domain.ErrDepartamentoNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (dynamo DepartamentoServiceDynamo) getDepartamento(ctx context.Context, id string) (domain.Departamento, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dynamo.table),
+		Key:       departamentoKey(domain.NormalizeDepartamentoID(id)),
+	}
+
+	output, err := dynamo.client.GetItem(ctx, input)
+	if err != nil {
+		return domain.Departamento{}, err
+	}
+	if output.Item == nil {
+		return domain.Departamento{}, domain.ErrDepartamentoNotFound
+	}
+
+	var departamento domain.Departamento
+	if err := attributevalue.UnmarshalMap(output.Item, &departamento); err != nil {
+		return domain.Departamento{}, err
+	}
+
+	return departamento, nil
+}
+
+func departamentoKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id_departamento": &types.AttributeValueMemberS{Value: id}}
+}
+
+func NewDepartamentoServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *DepartamentoServiceDynamo {
+	return &DepartamentoServiceDynamo{
+		client: client,
+		table:  table,
+		ctx:    ctx,
+	}
+}
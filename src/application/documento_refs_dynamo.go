@@ -0,0 +1,145 @@
+package application
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// Tabla con un ítem por checksum que lista los documentos que referencian ese blob;
+	// sin ella, saber si un blob sigue en uso obliga a recorrer la tabla de documentos
+	BLOB_REF_TABLE_NAME = os.Getenv("BLOB_REF_TABLE_NAME")
+)
+
+// refsBackfill es el ítem que cmd/backfill_blob_refs escribe al terminar de registrar
+// los documentos anteriores a la tabla de referencias. Mientras no exista, un checksum
+// sin referencias se confirma recorriendo la tabla de documentos.
+const refsBackfill = "#backfill"
+
+// refsComplete recuerda en el contenedor que el ítem refsBackfill ya existe.
+var refsComplete atomic.Bool
+
+type blobRef struct {
+	Checksum   string   `dynamodbav:"checksum"`
+	Documentos []string `dynamodbav:"documentos,stringset,omitempty"`
+}
+
+// AddChecksumRefs registra que el documento referencia los blobs con esos checksums. Se
+// llama antes de escribir el documento: si la escritura falla queda una referencia de
+// más, que solo retrasa el borrado del blob y nunca borra uno en uso.
+func (dynamo DocumentoServiceDynamo) AddChecksumRefs(id string, checksums []string) (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.AddChecksumRefs")
+	defer func() { end(err) }()
+
+	return dynamo.updateRefs(ctx, id, checksums, true)
+}
+
+// removeChecksumRefs quita las referencias del documento; se llama después de escribirlo
+// por la misma razón que AddChecksumRefs.
+func (dynamo DocumentoServiceDynamo) removeChecksumRefs(ctx context.Context, id string, checksums []string) error {
+	return dynamo.updateRefs(ctx, id, checksums, false)
+}
+
+func (dynamo DocumentoServiceDynamo) updateRefs(ctx context.Context, id string, checksums []string, add bool) error {
+	if BLOB_REF_TABLE_NAME == "" {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for _, checksum := range checksums {
+		if checksum == "" || seen[checksum] {
+			continue
+		}
+		seen[checksum] = true
+
+		documentos := expression.Value(&types.AttributeValueMemberSS{Value: []string{id}})
+		update := expression.Delete(expression.Name("documentos"), documentos)
+		if add {
+			update = expression.Add(expression.Name("documentos"), documentos)
+		}
+		expr, err := expression.NewBuilder().WithUpdate(update).Build()
+		if err != nil {
+			return err
+		}
+
+		_, err = dynamo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(BLOB_REF_TABLE_NAME),
+			Key:                       blobRefKey(checksum),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompleteChecksumRefs marca la tabla de referencias como completa: desde entonces un
+// checksum sin referencias se da por no usado sin recorrer la tabla de documentos.
+func (dynamo DocumentoServiceDynamo) CompleteChecksumRefs() (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.CompleteChecksumRefs")
+	defer func() { end(err) }()
+
+	_, err = dynamo.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(BLOB_REF_TABLE_NAME),
+		Item:      blobRefKey(refsBackfill),
+	})
+	return err
+}
+
+// checksumRefs devuelve los documentos que referencian el checksum. La lectura es
+// consistente porque decide si un blob se borra.
+func (dynamo DocumentoServiceDynamo) checksumRefs(ctx context.Context, checksum string) ([]string, error) {
+	output, err := dynamo.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(BLOB_REF_TABLE_NAME),
+		Key:            blobRefKey(checksum),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	var ref blobRef
+	if err := attributevalue.UnmarshalMap(output.Item, &ref); err != nil {
+		return nil, err
+	}
+	return ref.Documentos, nil
+}
+
+// checksumRefsComplete indica si cmd/backfill_blob_refs ya registró los documentos
+// anteriores a la tabla de referencias.
+func (dynamo DocumentoServiceDynamo) checksumRefsComplete(ctx context.Context) (bool, error) {
+	if refsComplete.Load() {
+		return true, nil
+	}
+	output, err := dynamo.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(BLOB_REF_TABLE_NAME),
+		Key:       blobRefKey(refsBackfill),
+	})
+	if err != nil {
+		return false, err
+	}
+	if output.Item == nil {
+		return false, nil
+	}
+	refsComplete.Store(true)
+	return true, nil
+}
+
+func blobRefKey(checksum string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"checksum": &types.AttributeValueMemberS{Value: checksum}}
+}
@@ -7,4 +7,28 @@ type DocumentoService interface {
 	GetAllDocuments() ([]domain.DocumentoResponse, error)
 	UpdateDocument(domain.DocumentoRequest,string) (domain.DocumentoResponse, error)
 	DeleteDocument(string) (domain.DocumentoResponse, error)
+	GetDocument(string) (domain.DocumentoResponse, error)
+	FindByObjectKey(string) (domain.DocumentoResponse, error)
+	AddAttachments(string, []domain.Adjunto) (domain.DocumentoResponse, error)
+	RemoveAttachment(string, string) (domain.Adjunto, error)
+	UpdateFileLocation(string, string) (domain.DocumentoResponse, error)
+	SetCanonicalFile(string, domain.Adjunto) (domain.DocumentoResponse, error)
+	UpdateThumbnail(string, string) error
+	UpdateExtraction(string, domain.ExtraccionPDF) error
+	MarkFileClean(string) error
+	RejectFile(string, string, string) error
+	AssignReceiptNumber(string) (string, error)
+	UpdateReceipt(string, string) (domain.DocumentoResponse, error)
+	RecordChecksum(string, string, bool) error
+	IsChecksumReferenced(string) (bool, error)
+	UnreferencedKeys([]string) ([]string, error)
+	HasDocuments(string) (bool, error)
+	HasResidenteDocuments(string) (bool, error)
+	HasServicioDocuments(string) (bool, error)
+	ApplyPayment(string, domain.AplicarPagoRequest) (domain.AplicacionPago, error)
+	RenameResidente(string, string) (int, error)
+	ApproveDocument(string, string) (domain.DocumentoResponse, error)
+	ApproveMatchedDocument(domain.Documento, string) (domain.DocumentoResponse, error)
+	PrepareDocument(domain.DocumentoRequest) (domain.Documento, error)
+	BatchCreateDocuments([]domain.Documento) ([]string, error)
 }
\ No newline at end of file
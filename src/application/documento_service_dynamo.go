@@ -2,8 +2,15 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 	"main/src/domain"
+	"main/src/logger"
+	"main/src/metrics"
+	"main/src/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -12,60 +19,139 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+var (
+	// DUPLICATE_POLICY decide qué hacer con un comprobante ya registrado: "flag" (por
+	// defecto) lo marca con el documento original y "reject" rechaza la creación.
+	DUPLICATE_POLICY = os.Getenv("DUPLICATE_POLICY")
+
+	// Tabla de contadores atómicos (p. ej. el correlativo de constancias)
+	COUNTER_TABLE_NAME = os.Getenv("COUNTER_TABLE_NAME")
+
+	// Tabla de departamentos; si está configurada, los documentos deben referenciar uno registrado
+	DEPARTAMENTO_TABLE_NAME = os.Getenv("DEPARTAMENTO_TABLE_NAME")
+
+	// Tabla de residentes; con ella los documentos pueden referenciar al residente por ID
+	RESIDENTE_TABLE_NAME = os.Getenv("RESIDENTE_TABLE_NAME")
+
+	// Catálogo de servicios; con él tipo_de_servicio debe ser un código del catálogo
+	SERVICIO_TABLE_NAME = os.Getenv("SERVICIO_TABLE_NAME")
+
+	// Tabla de cargos a la que se aplican los pagos de los documentos
+	CARGO_TABLE_NAME = os.Getenv("CARGO_TABLE_NAME")
+
+	// Movimientos importados de los extractos bancarios para conciliar pagos
+	MOVIMIENTO_TABLE_NAME = os.Getenv("MOVIMIENTO_TABLE_NAME")
+)
+
+const (
+	DuplicatePolicyFlag   = "flag"
+	DuplicatePolicyReject = "reject"
+
+	// Índice global por checksum del archivo principal.
+	checksumIndex = "file_checksum-index"
+
+	reciboCounter = "recibos"
+
+	// BatchWriteItem acepta hasta 25 escrituras por llamada.
+	batchWriteSize     = 25
+	batchWriteAttempts = 5
+)
+
 type DocumentoServiceDynamo struct {
 	client *dynamodb.Client
 	table  string
 	ctx    context.Context
 }
 
-func (dynamo DocumentoServiceDynamo) CreateDocument(req domain.DocumentoRequest) (domain.DocumentoResponse, error) {
-	reqToDoc := req.ToDocumento()
+func (dynamo DocumentoServiceDynamo) CreateDocument(req domain.DocumentoRequest) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.CreateDocument")
+	defer func() { end(err) }()
+
+	reqToDoc, err := dynamo.prepareDocument(ctx, req)
+	if err != nil {
+		var duplicateErr *domain.DuplicateError
+		if errors.As(err, &duplicateErr) {
+			return domain.DocumentoResponse{Documento_ID: duplicateErr.DocumentoID, Message: err.Error()}, err
+		}
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+	tracing.Annotate(ctx, "id_documento", reqToDoc.Documento_ID)
+
 	item, err := attributevalue.MarshalMap(reqToDoc)
 	if err != nil {
 		return domain.DocumentoResponse{Message: err.Error()}, err
 	}
 
+	if err = dynamo.updateRefs(ctx, reqToDoc.Documento_ID, documentoChecksums(reqToDoc), true); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
 	input := &dynamodb.PutItemInput{
 		TableName: aws.String(dynamo.table),
 		Item:      item,
 	}
 
-	_, err = dynamo.client.PutItem(dynamo.ctx, input)
+	_, err = dynamo.client.PutItem(ctx, input)
 	if err != nil {
 		return domain.DocumentoResponse{Message: err.Error()}, err
 	}
 
-	response := reqToDoc.ToDocumentoResponse()
+	metrics.Increment(metrics.DocumentsCreated, metrics.DocumentDimensions(reqToDoc.TipoDeServicio))
+
+	response = reqToDoc.ToDocumentoResponse()
 
 	return response, nil
 }
 
-func (dynamo DocumentoServiceDynamo) GetAllDocuments() ([]domain.DocumentoResponse, error) {
+func (dynamo DocumentoServiceDynamo) GetAllDocuments() (documentosResponse []domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.GetAllDocuments")
+	defer func() { end(err) }()
+
 	input := &dynamodb.ExecuteStatementInput{
 		Statement: aws.String(fmt.Sprintf("SELECT * FROM \"%v\"", dynamo.table)),
 	}
 
-	response, err := dynamo.client.ExecuteStatement(dynamo.ctx, input)
-	if err != nil {
-		return nil, err
-	}
+	// PartiQL devuelve como máximo 1 MB por página; se recorren todas
+	for {
+		response, err := dynamo.client.ExecuteStatement(ctx, input)
+		if err != nil {
+			return nil, err
+		}
 
-	var documentos []domain.Documento
-	err = attributevalue.UnmarshalListOfMaps(response.Items, &documentos)
-	if err != nil {
-		return nil, err
-	}
+		var documentos []domain.Documento
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &documentos)
+		if err != nil {
+			return nil, err
+		}
 
-	var documentosResponse []domain.DocumentoResponse
+		for _, documento := range documentos {
+			documentosResponse = append(documentosResponse, documento.ToDocumentoResponse())
+		}
 
-	for _, documento := range documentos {
-		documentosResponse = append(documentosResponse, documento.ToDocumentoResponse())
+		if response.NextToken == nil {
+			break
+		}
+		input.NextToken = response.NextToken
 	}
 
 	return documentosResponse, nil
 }
 
-func (dynamo DocumentoServiceDynamo) UpdateDocument(req domain.DocumentoRequest, id string) (domain.DocumentoResponse, error) {
+func (dynamo DocumentoServiceDynamo) UpdateDocument(req domain.DocumentoRequest, id string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.UpdateDocument")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	if err = req.Validate(); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+	if err = dynamo.checkResidente(ctx, &req); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+	if err = dynamo.checkChangedReferences(ctx, &req, id); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
 	reqToDoc := req.ToDocumento()
 
 	update := expression.
@@ -73,9 +159,57 @@ func (dynamo DocumentoServiceDynamo) UpdateDocument(req domain.DocumentoRequest,
 		Set(expression.Name("residente"), expression.Value(reqToDoc.Residente)).
 		Set(expression.Name("fecha_de_pago"), expression.Value(reqToDoc.FechaDePago)).
 		Set(expression.Name("tipo_de_servicio"), expression.Value(reqToDoc.TipoDeServicio)).
-		Set(expression.Name("estado_documento"), expression.Value(reqToDoc.TipoDeServicio))
+		Set(expression.Name("estado_documento"), expression.Value(reqToDoc.StateDocument))
 
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	// La primera aprobación queda registrada; si el documento deja de estar aprobado se borra
+	if domain.IsAprobado(reqToDoc.StateDocument) {
+		update = update.Set(expression.Name("fecha_aprobacion"),
+			expression.IfNotExists(expression.Name("fecha_aprobacion"), expression.Value(time.Now().UTC().Format(time.RFC3339))))
+		if reqToDoc.AprobadoPor != "" {
+			update = update.Set(expression.Name("aprobado_por"),
+				expression.IfNotExists(expression.Name("aprobado_por"), expression.Value(reqToDoc.AprobadoPor)))
+		}
+	} else {
+		update = update.Remove(expression.Name("fecha_aprobacion")).Remove(expression.Name("aprobado_por"))
+	}
+
+	if reqToDoc.NombreServicio != "" {
+		update = update.Set(expression.Name("nombre_servicio"), expression.Value(reqToDoc.NombreServicio))
+	} else {
+		update = update.Remove(expression.Name("nombre_servicio"))
+	}
+
+	if reqToDoc.ResidenteID != "" {
+		update = update.Set(expression.Name("id_residente"), expression.Value(reqToDoc.ResidenteID))
+	} else {
+		update = update.Remove(expression.Name("id_residente"))
+	}
+
+	// El monto se reemplaza completo, igual que el resto de campos del documento
+	if reqToDoc.Monto > 0 {
+		update = update.
+			Set(expression.Name("monto"), expression.Value(reqToDoc.Monto)).
+			Set(expression.Name("moneda"), expression.Value(reqToDoc.Moneda))
+	} else {
+		update = update.Remove(expression.Name("monto")).Remove(expression.Name("moneda"))
+	}
+	if reqToDoc.TipoCambio != "" {
+		update = update.Set(expression.Name("tipo_de_cambio"), expression.Value(reqToDoc.TipoCambio))
+	} else {
+		update = update.Remove(expression.Name("tipo_de_cambio"))
+	}
+
+	// Un documento ya aplicado a cargos debe seguir aprobado y no puede bajar de lo
+	// aplicado ni cambiar de departamento o moneda
+	condition := expression.AttributeNotExists(expression.Name("monto_aplicado"))
+	if domain.IsAprobado(reqToDoc.StateDocument) {
+		condition = condition.Or(
+			expression.Name("monto_aplicado").LessThanEqual(expression.Value(reqToDoc.Monto)).
+				And(expression.Name("departamento").Equal(expression.Value(reqToDoc.Departamento))).
+				And(expression.Name("moneda").Equal(expression.Value(reqToDoc.Moneda))))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
 	if err != nil {
 		return domain.DocumentoResponse{Message: err.Error()}, err
 	}
@@ -94,20 +228,36 @@ func (dynamo DocumentoServiceDynamo) UpdateDocument(req domain.DocumentoRequest,
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
-		ReturnValues:              types.ReturnValueUpdatedNew,
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllOld,
 	}
 
-	_, err = dynamo.client.UpdateItem(dynamo.ctx, input)
+	output, err := dynamo.client.UpdateItem(ctx, input)
 	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoAplicado
+		}
 		return domain.DocumentoResponse{Message: err.Error()}, err
 	}
 
-	response := reqToDoc.ToDocumentoResponse()
+	metrics.Increment(metrics.DocumentsUpdated, metrics.DocumentDimensions(reqToDoc.TipoDeServicio))
+
+	var previous domain.Documento
+	if err := attributevalue.UnmarshalMap(output.Attributes, &previous); err == nil && previous.StateDocument != reqToDoc.StateDocument {
+		metrics.StateTransition(previous.StateDocument, reqToDoc.StateDocument)
+	}
+
+	response = reqToDoc.ToDocumentoResponse()
 
 	return response, nil
 }
 
-func (dynamo DocumentoServiceDynamo) DeleteDocument(id string) (domain.DocumentoResponse, error) {
+func (dynamo DocumentoServiceDynamo) DeleteDocument(id string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.DeleteDocument")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
 	id_dynamo, err := attributevalue.Marshal(id)
 	if err != nil {
 		panic(err)
@@ -115,18 +265,1377 @@ func (dynamo DocumentoServiceDynamo) DeleteDocument(id string) (domain.Documento
 
 	key := map[string]types.AttributeValue{"id_documento": id_dynamo}
 
+	// Un documento aplicado a cargos no se elimina: los cargos quedarían pagados sin respaldo
+	condition := expression.AttributeNotExists(expression.Name("monto_aplicado"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
 
 	input := &dynamodb.DeleteItemInput{
 		TableName:                 aws.String(dynamo.table),
 		Key:                       key,
+		ExpressionAttributeNames:  expr.Names(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllOld,
+	}
+
+	output, err := dynamo.client.DeleteItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoAplicado
+		}
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	var deleted domain.Documento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &deleted); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+	metrics.Increment(metrics.DocumentsDeleted, metrics.DocumentDimensions(deleted.TipoDeServicio))
+
+	// El documento ya no existe: si sus referencias no se pueden quitar, sus blobs solo
+	// se conservan de más
+	if refErr := dynamo.removeChecksumRefs(ctx, id, documentoChecksums(deleted)); refErr != nil {
+		logger.FromContext(ctx).Warn("error removing checksum references", "id_documento", id, "error", refErr)
+	}
+
+	// Se devuelve el documento eliminado para que el llamador limpie sus archivos
+	response = deleted.ToDocumentoResponse()
+	response.Message = fmt.Sprintf("Documento: %s eliminado", id)
+
+	return response, nil
+}
+
+func (dynamo DocumentoServiceDynamo) UpdateFileLocation(id string, key string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.UpdateFileLocation")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	update := expression.
+		Set(expression.Name("file_key"), expression.Value(key)).
+		Set(expression.Name("url_pdf"), expression.Value(domain.ObjectURL(key)))
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	var documento domain.Documento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &documento); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	return documento.ToDocumentoResponse(), nil
+}
+
+func (dynamo DocumentoServiceDynamo) GetDocument(id string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.GetDocument")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	documento, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	return documento.ToDocumentoResponse(), nil
+}
+
+func (dynamo DocumentoServiceDynamo) AddAttachments(id string, adjuntos []domain.Adjunto) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.AddAttachments")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	for i := range adjuntos {
+		adjuntos[i].AssignKey(false)
+	}
+	if err = dynamo.updateRefs(ctx, id, domain.Checksums(adjuntos), true); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	update := expression.Set(
+		expression.Name("adjuntos"),
+		expression.ListAppend(
+			expression.IfNotExists(expression.Name("adjuntos"), expression.Value([]domain.Adjunto{})),
+			expression.Value(adjuntos),
+		),
+	).Add(expression.Name("checksums"), expression.Value(&types.AttributeValueMemberSS{Value: domain.Checksums(adjuntos)}))
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	var documento domain.Documento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &documento); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	return documento.ToDocumentoResponse(), nil
+}
+
+func (dynamo DocumentoServiceDynamo) RemoveAttachment(id string, adjuntoID string) (removed domain.Adjunto, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.RemoveAttachment")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	documento, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return domain.Adjunto{}, err
+	}
+
+	index := -1
+	for i, adjunto := range documento.Adjuntos {
+		if adjunto.Adjunto_ID == adjuntoID {
+			index = i
+			removed = adjunto
+			break
+		}
+	}
+	if index < 0 {
+		return domain.Adjunto{}, domain.ErrAdjuntoNotFound
+	}
+	if removed.Principal {
+		return domain.Adjunto{}, domain.ErrAdjuntoPrincipal
+	}
+
+	// La condición evita borrar otro adjunto si la lista cambió desde la lectura
+	path := fmt.Sprintf("adjuntos[%d]", index)
+	update := expression.Remove(expression.Name(path))
+	unreferenced := !sharesChecksum(documento.Adjuntos, index)
+	if unreferenced {
+		update = update.Delete(expression.Name("checksums"), expression.Value(&types.AttributeValueMemberSS{Value: []string{removed.Checksum}}))
+	}
+	condition := expression.Name(path + ".id_adjunto").Equal(expression.Value(adjuntoID))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.Adjunto{}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrAdjuntoNotFound
+		}
+		return domain.Adjunto{}, err
+	}
+
+	if unreferenced {
+		if refErr := dynamo.removeChecksumRefs(ctx, id, []string{removed.Checksum}); refErr != nil {
+			logger.FromContext(ctx).Warn("error removing checksum reference", "id_documento", id, "error", refErr)
+		}
+	}
+
+	return removed, nil
+}
+
+// SetCanonicalFile convierte el adjunto en el archivo principal del documento (p. ej. el
+// PDF generado a partir de una foto); los demás adjuntos se conservan como secundarios.
+func (dynamo DocumentoServiceDynamo) SetCanonicalFile(id string, canonical domain.Adjunto) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.SetCanonicalFile")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	documento, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	// Un mensaje reprocesado no vuelve a agregar el mismo archivo
+	for _, adjunto := range documento.Adjuntos {
+		if adjunto.Checksum == canonical.Checksum {
+			return documento.ToDocumentoResponse(), nil
+		}
+	}
+
+	if err = dynamo.updateRefs(ctx, id, []string{canonical.Checksum}, true); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	canonical.Principal = true
+	adjuntos := []domain.Adjunto{canonical}
+	for _, adjunto := range documento.Adjuntos {
+		adjunto.Principal = false
+		adjuntos = append(adjuntos, adjunto)
+	}
+
+	update := expression.
+		Set(expression.Name("adjuntos"), expression.Value(adjuntos)).
+		Set(expression.Name("file_key"), expression.Value(canonical.Key)).
+		Set(expression.Name("url_pdf"), expression.Value(domain.ObjectURL(canonical.Key))).
+		Add(expression.Name("checksums"), expression.Value(&types.AttributeValueMemberSS{Value: []string{canonical.Checksum}}))
+
+	// La condición evita pisar adjuntos agregados desde la lectura
+	condition := expression.AttributeNotExists(expression.Name("adjuntos"))
+	if len(documento.Adjuntos) > 0 {
+		condition = expression.Name("adjuntos").Size().Equal(expression.Value(len(documento.Adjuntos)))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	var updated domain.Documento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &updated); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	return updated.ToDocumentoResponse(), nil
+}
+
+// AssignReceiptNumber devuelve el número de constancia del documento, asignando el
+// siguiente correlativo la primera vez. Si dos llamadas compiten, gana la primera y el
+// número de la otra queda sin usar.
+func (dynamo DocumentoServiceDynamo) AssignReceiptNumber(id string) (numero string, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.AssignReceiptNumber")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	documento, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if documento.NumeroRecibo != "" {
+		return documento.NumeroRecibo, nil
+	}
+
+	siguiente, err := dynamo.nextCounter(ctx, reciboCounter)
+	if err != nil {
+		return "", err
+	}
+	numero = domain.FormatNumeroRecibo(siguiente)
+
+	update := expression.Set(expression.Name("numero_recibo"), expression.Value(numero))
+	condition := expression.AttributeExists(expression.Name("id_documento")).
+		And(expression.AttributeNotExists(expression.Name("numero_recibo")))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return "", err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return "", err
+		}
+		// Otra invocación asignó el número primero (o el documento fue eliminado)
+		documento, err = dynamo.getDocumento(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		return documento.NumeroRecibo, nil
+	}
+
+	return numero, nil
+}
+
+func (dynamo DocumentoServiceDynamo) UpdateReceipt(id string, key string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.UpdateReceipt")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	update := expression.
+		Set(expression.Name("recibo_key"), expression.Value(key)).
+		Set(expression.Name("url_recibo"), expression.Value(domain.ObjectURL(key)))
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
 	}
 
-	_, err = dynamo.client.DeleteItem(dynamo.ctx, input)
+	output, err := dynamo.client.UpdateItem(ctx, input)
 	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	var documento domain.Documento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &documento); err != nil {
 		return domain.DocumentoResponse{Message: err.Error()}, err
 	}
 
-	return domain.DocumentoResponse{Message: fmt.Sprintf("Usuario: %s eliminado",id_dynamo)}, nil
+	return documento.ToDocumentoResponse(), nil
+}
+
+// nextCounter incrementa atómicamente el contador indicado y devuelve su nuevo valor.
+func (dynamo DocumentoServiceDynamo) nextCounter(ctx context.Context, name string) (int64, error) {
+	update := expression.Add(expression.Name("valor"), expression.Value(1))
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(COUNTER_TABLE_NAME),
+		Key:                       map[string]types.AttributeValue{"contador": &types.AttributeValueMemberS{Value: name}},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	valor, ok := output.Attributes["valor"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("contador %s sin valor numérico", name)
+	}
+	return strconv.ParseInt(valor.Value, 10, 64)
+}
+
+func (dynamo DocumentoServiceDynamo) UpdateThumbnail(id string, key string) (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.UpdateThumbnail")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	update := expression.
+		Set(expression.Name("thumbnail_key"), expression.Value(key)).
+		Set(expression.Name("thumbnail_url"), expression.Value(domain.ObjectURL(key)))
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdateExtraction guarda los metadatos, el texto y los valores sugeridos del PDF principal.
+func (dynamo DocumentoServiceDynamo) UpdateExtraction(id string, extraccion domain.ExtraccionPDF) (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.UpdateExtraction")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	update := expression.Set(expression.Name("extraccion"), expression.Value(extraccion))
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// MarkFileClean marca el archivo del documento como analizado sin amenazas. Un documento
+// ya rechazado por malware conserva ese estado aunque otro de sus archivos esté limpio.
+func (dynamo DocumentoServiceDynamo) MarkFileClean(id string) (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.MarkFileClean")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	update := expression.Set(expression.Name("estado_archivo"), expression.Value(domain.EstadoArchivoLimpio))
+	condition := expression.AttributeExists(expression.Name("id_documento")).And(
+		expression.Or(
+			expression.AttributeNotExists(expression.Name("estado_archivo")),
+			expression.Name("estado_archivo").NotEqual(expression.Value(domain.EstadoArchivoMalware)),
+		),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			// Documento eliminado o ya rechazado: no hay nada que marcar
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RejectFile marca el documento como rechazado por malware y registra la key de
+// cuarentena del archivo infectado y la firma detectada.
+func (dynamo DocumentoServiceDynamo) RejectFile(id string, quarantineKey string, signature string) (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.RejectFile")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	update := expression.
+		Set(expression.Name("estado_archivo"), expression.Value(domain.EstadoArchivoMalware)).
+		Set(expression.Name("malware_firma"), expression.Value(signature)).
+		Add(expression.Name("archivos_cuarentena"), expression.Value(&types.AttributeValueMemberSS{Value: []string{quarantineKey}}))
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (dynamo DocumentoServiceDynamo) RecordChecksum(id string, checksum string, principal bool) (err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.RecordChecksum")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	if err = dynamo.updateRefs(ctx, id, []string{checksum}, true); err != nil {
+		return err
+	}
+
+	update := expression.Add(expression.Name("checksums"), expression.Value(&types.AttributeValueMemberSS{Value: []string{checksum}}))
+	if principal {
+		update = update.Set(expression.Name("file_checksum"), expression.Value(checksum))
+	}
+	condition := expression.AttributeExists(expression.Name("id_documento"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrDocumentoNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// IsChecksumReferenced indica si algún documento referencia el blob. Con
+// BLOB_REF_TABLE_NAME basta leer su ítem de referencias; la tabla de documentos solo se
+// recorre si no está configurada o si cmd/backfill_blob_refs aún no la completó.
+func (dynamo DocumentoServiceDynamo) IsChecksumReferenced(checksum string) (referenced bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.IsChecksumReferenced")
+	defer func() { end(err) }()
+
+	if BLOB_REF_TABLE_NAME != "" {
+		documentos, err := dynamo.checksumRefs(ctx, checksum)
+		if err != nil {
+			return false, err
+		}
+		if len(documentos) > 0 {
+			return true, nil
+		}
+		complete, err := dynamo.checksumRefsComplete(ctx)
+		if err != nil {
+			return false, err
+		}
+		if complete {
+			return false, nil
+		}
+	}
+
+	filter := expression.Contains(expression.Name("checksums"), checksum).
+		Or(expression.Name("file_checksum").Equal(expression.Value(checksum)))
+
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(expression.NamesList(expression.Name("id_documento"))).Build()
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(dynamo.table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return false, err
+		}
+		if len(page.Items) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UnreferencedKeys filtra las keys que pueden borrarse del bucket: un blob direccionado
+// por contenido se conserva mientras otro documento lo siga referenciando.
+func (dynamo DocumentoServiceDynamo) UnreferencedKeys(keys []string) ([]string, error) {
+	var unreferenced []string
+	for _, key := range keys {
+		if checksum := domain.ChecksumFromKey(key); checksum != "" {
+			referenced, err := dynamo.IsChecksumReferenced(checksum)
+			if err != nil {
+				return nil, err
+			}
+			if referenced {
+				continue
+			}
+		}
+		unreferenced = append(unreferenced, key)
+	}
+	return unreferenced, nil
+}
+
+// FindByObjectKey devuelve el documento al que pertenece un objeto del bucket, para
+// atender los enlaces antiguos que apuntaban directamente a la key.
+func (dynamo DocumentoServiceDynamo) FindByObjectKey(key string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.FindByObjectKey")
+	defer func() { end(err) }()
+
+	id := domain.DocumentoIDFromKey(key)
+	if checksum := domain.ChecksumFromKey(key); checksum != "" {
+		id, err = dynamo.referencingDocument(ctx, checksum)
+		if err != nil {
+			return domain.DocumentoResponse{}, err
+		}
+	}
+	if id == "" {
+		return domain.DocumentoResponse{}, domain.ErrDocumentoNotFound
+	}
+
+	documento, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return domain.DocumentoResponse{}, err
+	}
+
+	response = documento.ToDocumentoResponse()
+	if _, ok := response.FileParams(key); !ok {
+		return domain.DocumentoResponse{}, domain.ErrDocumentoNotFound
+	}
+	return response, nil
+}
+
+// referencingDocument devuelve un documento que referencia el blob, sea como archivo
+// principal o como adjunto.
+func (dynamo DocumentoServiceDynamo) referencingDocument(ctx context.Context, checksum string) (string, error) {
+	if BLOB_REF_TABLE_NAME != "" {
+		documentos, err := dynamo.checksumRefs(ctx, checksum)
+		if err != nil {
+			return "", err
+		}
+		if len(documentos) > 0 {
+			return documentos[0], nil
+		}
+	}
+	return dynamo.findByChecksum(ctx, checksum)
+}
+
+// documentoChecksums devuelve los checksums de todos los blobs que usa el documento.
+func documentoChecksums(documento domain.Documento) []string {
+	return append(append([]string{}, documento.Checksums...), documento.FileChecksum)
+}
+
+// findByChecksum devuelve el ID de otro documento cuyo archivo principal tiene el checksum.
+func (dynamo DocumentoServiceDynamo) findByChecksum(ctx context.Context, checksum string) (string, error) {
+	keyCond := expression.Key("file_checksum").Equal(expression.Value(checksum))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return "", err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamo.table),
+		IndexName:                 aws.String(checksumIndex),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		Limit:                     aws.Int32(1),
+	}
+
+	output, err := dynamo.client.Query(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if len(output.Items) == 0 {
+		return "", nil
+	}
+
+	var documento domain.Documento
+	if err := attributevalue.UnmarshalMap(output.Items[0], &documento); err != nil {
+		return "", err
+	}
+
+	return documento.Documento_ID, nil
+}
+
+// sharesChecksum indica si otro adjunto del documento tiene el mismo contenido que el del índice.
+func sharesChecksum(adjuntos []domain.Adjunto, index int) bool {
+	for i, adjunto := range adjuntos {
+		if i != index && adjunto.Checksum == adjuntos[index].Checksum {
+			return true
+		}
+	}
+	return false
+}
+
+func (dynamo DocumentoServiceDynamo) getDocumento(ctx context.Context, id string) (domain.Documento, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dynamo.table),
+		Key:       documentoKey(id),
+	}
+
+	output, err := dynamo.client.GetItem(ctx, input)
+	if err != nil {
+		return domain.Documento{}, err
+	}
+	if output.Item == nil {
+		return domain.Documento{}, domain.ErrDocumentoNotFound
+	}
+
+	var documento domain.Documento
+	if err := attributevalue.UnmarshalMap(output.Item, &documento); err != nil {
+		return domain.Documento{}, err
+	}
+
+	return documento, nil
+}
+
+// checkChangedReferences valida departamento y servicio solo si la actualización los
+// cambia: un documento con un departamento o servicio dado de baja se puede seguir
+// revisando y aprobando mientras conserve esos valores.
+func (dynamo DocumentoServiceDynamo) checkChangedReferences(ctx context.Context, req *domain.DocumentoRequest, id string) error {
+	if DEPARTAMENTO_TABLE_NAME == "" && SERVICIO_TABLE_NAME == "" {
+		return nil
+	}
+
+	current, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if domain.NormalizeDepartamentoID(req.Departamento) == domain.NormalizeDepartamentoID(current.Departamento) && current.Departamento != "" {
+		req.Departamento = current.Departamento
+	} else if err := dynamo.checkDepartamento(ctx, req); err != nil {
+		return err
+	}
+
+	if domain.NormalizeServicioCodigo(req.TipoDeServicio) == domain.NormalizeServicioCodigo(current.TipoDeServicio) && current.TipoDeServicio != "" {
+		req.TipoDeServicio = current.TipoDeServicio
+		req.NombreServicio = current.NombreServicio
+	} else if err := dynamo.checkServicio(ctx, req); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkDepartamento exige que el departamento del documento esté registrado y lo
+// reemplaza por su ID normalizado. Sin DEPARTAMENTO_TABLE_NAME no se valida.
+func (dynamo DocumentoServiceDynamo) checkDepartamento(ctx context.Context, req *domain.DocumentoRequest) error {
+	if DEPARTAMENTO_TABLE_NAME == "" {
+		return nil
+	}
+
+	id := domain.NormalizeDepartamentoID(req.Departamento)
+	if id == "" {
+		return domain.ErrDepartamentoInexistente
+	}
+	if err := checkDepartamentoExists(ctx, dynamo.client, id); err != nil {
+		return err
+	}
+
+	req.Departamento = id
+	return nil
+}
+
+// checkServicio exige que tipo_de_servicio sea un servicio activo del catálogo, guarda
+// su código normalizado y copia el nombre para mostrarlo.
+func (dynamo DocumentoServiceDynamo) checkServicio(ctx context.Context, req *domain.DocumentoRequest) error {
+	if SERVICIO_TABLE_NAME == "" {
+		return nil
+	}
+
+	codigo := domain.NormalizeServicioCodigo(req.TipoDeServicio)
+	if codigo == "" {
+		return domain.ErrServicioInexistente
+	}
+
+	servicio, err := NewServicioServiceDynamo(dynamo.client, SERVICIO_TABLE_NAME, ctx).getServicio(ctx, codigo)
+	if errors.Is(err, domain.ErrServicioNotFound) {
+		return domain.ErrServicioInexistente
+	}
+	if err != nil {
+		return err
+	}
+	if !servicio.Activo {
+		return domain.ErrServicioInactivo
+	}
+
+	req.TipoDeServicio = servicio.Codigo
+	req.NombreServicio = servicio.Nombre
+	return nil
+}
+
+// checkResidente resuelve el residente referenciado por ID: copia su nombre al documento
+// para mostrarlo sin otra lectura y exige que pertenezca al departamento del documento.
+func (dynamo DocumentoServiceDynamo) checkResidente(ctx context.Context, req *domain.DocumentoRequest) error {
+	if req.ResidenteID == "" || RESIDENTE_TABLE_NAME == "" {
+		return nil
+	}
+
+	residente, err := NewResidenteServiceDynamo(dynamo.client, RESIDENTE_TABLE_NAME, ctx).getResidente(ctx, req.ResidenteID)
+	if errors.Is(err, domain.ErrResidenteNotFound) {
+		return domain.ErrResidenteInexistente
+	}
+	if err != nil {
+		return err
+	}
+
+	if req.Departamento == "" {
+		req.Departamento = residente.Departamento
+	} else if domain.NormalizeDepartamentoID(req.Departamento) != residente.Departamento {
+		return domain.ErrResidenteDepartamento
+	}
+	req.Residente = residente.Nombre
+	return nil
+}
+
+// HasDocuments indica si algún documento referencia al departamento.
+func (dynamo DocumentoServiceDynamo) HasDocuments(departamento string) (found bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.HasDocuments")
+	defer func() { end(err) }()
+
+	return dynamo.anyDocument(ctx, expression.Name("departamento").Equal(expression.Value(departamento)))
+}
+
+// HasServicioDocuments indica si algún documento usa el servicio del catálogo.
+func (dynamo DocumentoServiceDynamo) HasServicioDocuments(codigo string) (found bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.HasServicioDocuments")
+	defer func() { end(err) }()
+
+	return dynamo.anyDocument(ctx, expression.Name("tipo_de_servicio").Equal(expression.Value(codigo)))
+}
+
+// HasResidenteDocuments indica si algún documento referencia al residente.
+func (dynamo DocumentoServiceDynamo) HasResidenteDocuments(id string) (found bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.HasResidenteDocuments")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_residente", id)
+
+	return dynamo.anyDocument(ctx, expression.Name("id_residente").Equal(expression.Value(id)))
+}
+
+// RenameResidente actualiza el nombre copiado en los documentos del residente y
+// devuelve cuántos cambiaron.
+func (dynamo DocumentoServiceDynamo) RenameResidente(id string, nombre string) (updated int, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.RenameResidente")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_residente", id)
+
+	filter := expression.Name("id_residente").Equal(expression.Value(id)).
+		And(expression.Name("residente").NotEqual(expression.Value(nombre)))
+	projection := expression.NamesList(expression.Name("id_documento"))
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(dynamo.table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	update := expression.Set(expression.Name("residente"), expression.Value(nombre))
+	// Si el documento cambió de residente mientras tanto no se toca
+	condition := expression.Name("id_residente").Equal(expression.Value(id))
+	updateExpr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, item := range output.Items {
+			_, err := dynamo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 aws.String(dynamo.table),
+				Key:                       map[string]types.AttributeValue{"id_documento": item["id_documento"]},
+				ExpressionAttributeNames:  updateExpr.Names(),
+				ExpressionAttributeValues: updateExpr.Values(),
+				UpdateExpression:          updateExpr.Update(),
+				ConditionExpression:       updateExpr.Condition(),
+			})
+			if err != nil {
+				var conditionErr *types.ConditionalCheckFailedException
+				if errors.As(err, &conditionErr) {
+					continue
+				}
+				return updated, err
+			}
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// ApplyPayment aplica el monto de un documento aprobado a un cargo o, sin id_cargo, a
+// los cargos pendientes de su departamento y servicio, del más antiguo al más reciente.
+func (dynamo DocumentoServiceDynamo) ApplyPayment(id string, req domain.AplicarPagoRequest) (response domain.AplicacionPago, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.ApplyPayment")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	documento, err := dynamo.getDocumento(ctx, id)
+	if err != nil {
+		return domain.AplicacionPago{Message: err.Error()}, err
+	}
+
+	cargos := NewCargoServiceDynamo(dynamo.client, CARGO_TABLE_NAME, ctx)
+
+	var pendientes []domain.Cargo
+	if req.Cargo_ID != "" {
+		cargo, err := cargos.getCargo(ctx, req.Cargo_ID)
+		if err != nil {
+			return domain.AplicacionPago{Message: err.Error()}, err
+		}
+		pendientes = []domain.Cargo{cargo}
+	} else {
+		todos, err := cargos.queryCargos(ctx, domain.NormalizeDepartamentoID(documento.Departamento), "")
+		if err != nil {
+			return domain.AplicacionPago{Message: err.Error()}, err
+		}
+		domain.OrdenarCargos(todos)
+		for _, cargo := range todos {
+			if cargo.Servicio == domain.NormalizeServicioCodigo(documento.TipoDeServicio) && cargo.Saldo() > 0 {
+				pendientes = append(pendientes, cargo)
+			}
+		}
+		if len(pendientes) == 0 {
+			err = domain.ErrPagoSinCargos
+			return domain.AplicacionPago{Message: err.Error()}, err
+		}
+	}
+
+	response = domain.AplicacionPago{Documento_ID: id, Cargos: []domain.CargoResponse{}}
+	for _, cargo := range pendientes {
+		if err = domain.ValidarPago(documento, cargo); err != nil {
+			return domain.AplicacionPago{Message: err.Error()}, err
+		}
+		// Al repartir entre varios cargos el monto pedido es el tope del total
+		pedido := req.Monto
+		if req.Cargo_ID == "" {
+			if documento.Disponible() <= 0 || (req.Monto > 0 && response.Aplicado >= req.Monto) {
+				break
+			}
+			if req.Monto > 0 {
+				pedido = min(req.Monto-response.Aplicado, documento.Disponible(), cargo.Saldo())
+			}
+		}
+
+		monto, err := domain.MontoAAplicar(documento, cargo, pedido)
+		if err != nil {
+			return domain.AplicacionPago{Message: err.Error()}, err
+		}
+
+		cargo, err = dynamo.aplicarPago(ctx, documento, cargo, monto)
+		if err != nil {
+			return domain.AplicacionPago{Message: err.Error()}, err
+		}
+
+		documento.MontoAplicado += monto
+		response.Aplicado += monto
+		response.Cargos = append(response.Cargos, cargo.ToCargoResponse())
+	}
+	response.Disponible = documento.Disponible()
+
+	return response, nil
+}
+
+// aplicarPago registra el pago en el cargo y en el documento en una sola transacción.
+// Ambas escrituras se condicionan a los valores leídos para no aplicar dos veces el
+// mismo saldo si dos tesoreros concilian a la vez.
+func (dynamo DocumentoServiceDynamo) aplicarPago(ctx context.Context, documento domain.Documento, cargo domain.Cargo, monto int64) (domain.Cargo, error) {
+	pago := domain.PagoAplicado{
+		Documento_ID: documento.Documento_ID,
+		Monto:        monto,
+		Fecha:        time.Now().UTC().Format(time.RFC3339),
+	}
+
+	previo := cargo.Pagado
+	cargo.Pagado += monto
+	cargo.Pagos = append(cargo.Pagos, pago)
+	cargo.Estado = cargo.EstadoActual()
+
+	cargoUpdate := expression.
+		Set(expression.Name("pagado"), expression.Value(cargo.Pagado)).
+		Set(expression.Name("estado"), expression.Value(cargo.Estado)).
+		Set(expression.Name("pagos"), expression.ListAppend(
+			expression.IfNotExists(expression.Name("pagos"), expression.Value([]domain.PagoAplicado{})),
+			expression.Value([]domain.PagoAplicado{pago}),
+		))
+	cargoCondition := expression.Name("pagado").Equal(expression.Value(previo))
+	cargoExpr, err := expression.NewBuilder().WithUpdate(cargoUpdate).WithCondition(cargoCondition).Build()
+	if err != nil {
+		return domain.Cargo{}, err
+	}
+
+	documentoUpdate := expression.
+		Set(expression.Name("monto_aplicado"), expression.Value(documento.MontoAplicado+monto)).
+		Add(expression.Name("cargos"), expression.Value(&types.AttributeValueMemberSS{Value: []string{cargo.Cargo_ID}}))
+	documentoCondition := expression.Name("monto").Equal(expression.Value(documento.Monto)).
+		And(expression.Name("estado_documento").Equal(expression.Value(documento.StateDocument)))
+	if documento.MontoAplicado == 0 {
+		documentoCondition = documentoCondition.And(expression.AttributeNotExists(expression.Name("monto_aplicado")))
+	} else {
+		documentoCondition = documentoCondition.And(expression.Name("monto_aplicado").Equal(expression.Value(documento.MontoAplicado)))
+	}
+	documentoExpr, err := expression.NewBuilder().WithUpdate(documentoUpdate).WithCondition(documentoCondition).Build()
+	if err != nil {
+		return domain.Cargo{}, err
+	}
+
+	_, err = dynamo.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:                 aws.String(CARGO_TABLE_NAME),
+				Key:                       cargoKey(cargo.Cargo_ID),
+				ExpressionAttributeNames:  cargoExpr.Names(),
+				ExpressionAttributeValues: cargoExpr.Values(),
+				UpdateExpression:          cargoExpr.Update(),
+				ConditionExpression:       cargoExpr.Condition(),
+			}},
+			{Update: &types.Update{
+				TableName:                 aws.String(dynamo.table),
+				Key:                       documentoKey(documento.Documento_ID),
+				ExpressionAttributeNames:  documentoExpr.Names(),
+				ExpressionAttributeValues: documentoExpr.Values(),
+				UpdateExpression:          documentoExpr.Update(),
+				ConditionExpression:       documentoExpr.Condition(),
+			}},
+		},
+	})
+	if err != nil {
+		var canceledErr *types.TransactionCanceledException
+		if errors.As(err, &canceledErr) {
+			err = domain.ErrPagoConcurrente
+		}
+		return domain.Cargo{}, err
+	}
+
+	return cargo, nil
+}
+
+// PrepareDocument aplica a la solicitud las mismas validaciones que CreateDocument y
+// devuelve el documento listo para guardar, sin escribirlo. Lo usa la importación masiva,
+// que después guarda los documentos en lotes con BatchCreateDocuments.
+func (dynamo DocumentoServiceDynamo) PrepareDocument(req domain.DocumentoRequest) (documento domain.Documento, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.PrepareDocument")
+	defer func() { end(err) }()
+
+	return dynamo.prepareDocument(ctx, req)
+}
+
+// prepareDocument valida la solicitud, completa los datos de residente, departamento y
+// servicio, y aplica DUPLICATE_POLICY al archivo principal.
+func (dynamo DocumentoServiceDynamo) prepareDocument(ctx context.Context, req domain.DocumentoRequest) (domain.Documento, error) {
+	if err := req.Validate(); err != nil {
+		return domain.Documento{}, err
+	}
+	if err := dynamo.checkResidente(ctx, &req); err != nil {
+		return domain.Documento{}, err
+	}
+	if err := dynamo.checkDepartamento(ctx, &req); err != nil {
+		return domain.Documento{}, err
+	}
+	if err := dynamo.checkServicio(ctx, &req); err != nil {
+		return domain.Documento{}, err
+	}
+
+	documento := req.ToDocumento()
+
+	if documento.FileChecksum != "" {
+		duplicado, err := dynamo.findByChecksum(ctx, documento.FileChecksum)
+		if err != nil {
+			return domain.Documento{}, err
+		}
+		if duplicado != "" {
+			if DUPLICATE_POLICY == DuplicatePolicyReject {
+				return domain.Documento{}, &domain.DuplicateError{DocumentoID: duplicado}
+			}
+			documento.DuplicadoDe = duplicado
+		}
+	}
+
+	return documento, nil
+}
+
+// BatchCreateDocuments guarda documentos ya preparados en lotes de BatchWriteItem. Los
+// elementos que DynamoDB no procesa se reintentan con espera creciente; devuelve los IDs
+// que siguieron sin guardarse después de los reintentos. Si devuelve error, los lotes
+// anteriores ya quedaron guardados: el llamador debe usar ids deterministas para poder
+// repetir la escritura sin duplicar documentos.
+func (dynamo DocumentoServiceDynamo) BatchCreateDocuments(documentos []domain.Documento) (unprocessed []string, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.BatchCreateDocuments")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "documentos", len(documentos))
+
+	for start := 0; start < len(documentos); start += batchWriteSize {
+		batch := documentos[start:min(start+batchWriteSize, len(documentos))]
+
+		requests := make([]types.WriteRequest, 0, len(batch))
+		for _, documento := range batch {
+			item, err := attributevalue.MarshalMap(documento)
+			if err != nil {
+				return nil, err
+			}
+			if err := dynamo.updateRefs(ctx, documento.Documento_ID, documentoChecksums(documento), true); err != nil {
+				return nil, err
+			}
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		pending := map[string][]types.WriteRequest{dynamo.table: requests}
+		for attempt := 0; len(pending[dynamo.table]) > 0 && attempt < batchWriteAttempts; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(1<<attempt) * 100 * time.Millisecond)
+			}
+			output, err := dynamo.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, err
+			}
+			pending = output.UnprocessedItems
+		}
+
+		failed := map[string]bool{}
+		for _, request := range pending[dynamo.table] {
+			if id, ok := request.PutRequest.Item["id_documento"].(*types.AttributeValueMemberS); ok {
+				failed[id.Value] = true
+				unprocessed = append(unprocessed, id.Value)
+			}
+		}
+		for _, documento := range batch {
+			if !failed[documento.Documento_ID] {
+				metrics.Increment(metrics.DocumentsCreated, metrics.DocumentDimensions(documento.TipoDeServicio))
+			}
+		}
+	}
+
+	return unprocessed, nil
+}
+
+// ApproveDocument aprueba un documento pendiente sin tocar el resto de sus datos. Lo usa
+// la conciliación bancaria cuando un usuario confirma un movimiento; el llamador emite
+// la constancia con receipt.Generate. Un documento con malware no se aprueba.
+func (dynamo DocumentoServiceDynamo) ApproveDocument(id string, aprobadoPor string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.ApproveDocument")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", id)
+
+	condition := expression.AttributeExists(expression.Name("id_documento")).And(
+		expression.Or(
+			expression.AttributeNotExists(expression.Name("estado_documento")),
+			expression.Name("estado_documento").NotEqual(expression.Value(domain.EstadoAprobado)),
+		),
+		expression.Or(
+			expression.AttributeNotExists(expression.Name("estado_archivo")),
+			expression.Name("estado_archivo").NotEqual(expression.Value(domain.EstadoArchivoMalware)),
+		),
+	)
+
+	return dynamo.approve(ctx, id, aprobadoPor, condition)
+}
+
+// ApproveMatchedDocument aprueba sin intervención de un usuario un documento emparejado
+// con un movimiento del extracto. Solo lo aprueba si sigue como se leyó al emparejarlo,
+// en el mismo estado pendiente y por el mismo monto, y si su archivo ya fue analizado y
+// está limpio; si no, devuelve ErrAprobacionAutomatica y queda para revisión.
+func (dynamo DocumentoServiceDynamo) ApproveMatchedDocument(leido domain.Documento, aprobadoPor string) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "DocumentoService.ApproveMatchedDocument")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_documento", leido.Documento_ID)
+
+	estado := expression.Name("estado_documento").Equal(expression.Value(leido.StateDocument))
+	if leido.StateDocument == "" {
+		estado = expression.Or(estado, expression.AttributeNotExists(expression.Name("estado_documento")))
+	}
+	condition := expression.AttributeExists(expression.Name("id_documento")).And(
+		estado,
+		expression.Name("monto").Equal(expression.Value(leido.Monto)),
+		expression.Name("estado_archivo").Equal(expression.Value(domain.EstadoArchivoLimpio)),
+	)
+
+	return dynamo.approve(ctx, leido.Documento_ID, aprobadoPor, condition)
+}
+
+// approve marca el documento como aprobado si se cumple la condición y, si no, explica
+// por qué no pudo aprobarse.
+func (dynamo DocumentoServiceDynamo) approve(ctx context.Context, id string, aprobadoPor string, condition expression.ConditionBuilder) (domain.DocumentoResponse, error) {
+	fechaAprobacion := time.Now().UTC().Format(time.RFC3339)
+	update := expression.
+		Set(expression.Name("estado_documento"), expression.Value(domain.EstadoAprobado)).
+		Set(expression.Name("fecha_aprobacion"),
+			expression.IfNotExists(expression.Name("fecha_aprobacion"), expression.Value(fechaAprobacion))).
+		Set(expression.Name("aprobado_por"),
+			expression.IfNotExists(expression.Name("aprobado_por"), expression.Value(aprobadoPor)))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       documentoKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllOld,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			// Distingue el documento inexistente, el de archivo con malware, el ya aprobado
+			// y el que cambió desde que se leyó
+			current, getErr := dynamo.getDocumento(ctx, id)
+			switch {
+			case errors.Is(getErr, domain.ErrDocumentoNotFound):
+				err = domain.ErrDocumentoNotFound
+			case getErr != nil:
+				err = getErr
+			case current.EstadoArchivo == domain.EstadoArchivoMalware:
+				err = domain.ErrArchivoRechazado
+			case domain.IsAprobado(current.StateDocument):
+				err = domain.ErrDocumentoAprobado
+			default:
+				err = domain.ErrAprobacionAutomatica
+			}
+		}
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	var documento domain.Documento
+	if err = attributevalue.UnmarshalMap(output.Attributes, &documento); err != nil {
+		return domain.DocumentoResponse{Message: err.Error()}, err
+	}
+
+	metrics.Increment(metrics.DocumentsUpdated, metrics.DocumentDimensions(documento.TipoDeServicio))
+	metrics.StateTransition(documento.StateDocument, domain.EstadoAprobado)
+
+	// Se devuelve el documento como quedó después de aprobarlo
+	documento.StateDocument = domain.EstadoAprobado
+	if documento.FechaAprobacion == "" {
+		documento.FechaAprobacion = fechaAprobacion
+	}
+	if documento.AprobadoPor == "" {
+		documento.AprobadoPor = aprobadoPor
+	}
+
+	return documento.ToDocumentoResponse(), nil
+}
+
+// anyDocument recorre la tabla hasta encontrar un documento que cumpla el filtro.
+func (dynamo DocumentoServiceDynamo) anyDocument(ctx context.Context, filter expression.ConditionBuilder) (bool, error) {
+	projection := expression.NamesList(expression.Name("id_documento"))
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(dynamo.table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	// El filtro se aplica después de leer cada página: hay que seguir hasta encontrar uno
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return false, err
+		}
+		if len(output.Items) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func documentoKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id_documento": &types.AttributeValueMemberS{Value: id}}
 }
 
 func NewDocumentoServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *DocumentoServiceDynamo {
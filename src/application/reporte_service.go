@@ -0,0 +1,7 @@
+package application
+
+import "main/src/domain"
+
+type ReporteService interface {
+	Morosidad(string) (domain.Morosidad, error)
+}
@@ -0,0 +1,132 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"main/src/domain"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// ReporteServiceDynamo arma los reportes a partir de la tabla de documentos y de la de
+// cargos (CARGO_TABLE_NAME).
+type ReporteServiceDynamo struct {
+	client *dynamodb.Client
+	table  string
+	ctx    context.Context
+}
+
+// Morosidad calcula la deuda vencida por departamento a la fecha de corte (AAAA-MM-DD);
+// sin fecha usa la de hoy.
+func (dynamo ReporteServiceDynamo) Morosidad(fechaCorte string) (response domain.Morosidad, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ReporteService.Morosidad")
+	defer func() { end(err) }()
+
+	corte := time.Now().UTC().Truncate(24 * time.Hour)
+	if fechaCorte != "" {
+		if corte, err = time.Parse("2006-01-02", fechaCorte); err != nil {
+			err = domain.ErrFechaCorte
+			return domain.Morosidad{Message: err.Error()}, err
+		}
+	}
+	tracing.Annotate(ctx, "fecha_corte", corte.Format("2006-01-02"))
+
+	cargos, err := dynamo.cargosConSaldo(ctx)
+	if err != nil {
+		return domain.Morosidad{Message: err.Error()}, err
+	}
+	documentos, err := dynamo.documentosConMonto(ctx)
+	if err != nil {
+		return domain.Morosidad{Message: err.Error()}, err
+	}
+
+	return domain.CalcularMorosidad(corte, cargos, documentos), nil
+}
+
+func (dynamo ReporteServiceDynamo) cargosConSaldo(ctx context.Context) ([]domain.Cargo, error) {
+	filter := expression.Name("estado").NotEqual(expression.Value(domain.EstadoCargoPagado))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(CARGO_TABLE_NAME),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}
+
+	var cargos []domain.Cargo
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []domain.Cargo
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		cargos = append(cargos, page...)
+	}
+
+	return cargos, nil
+}
+
+// documentosConMonto lee solo los campos que el reporte necesita de los documentos con monto.
+func (dynamo ReporteServiceDynamo) documentosConMonto(ctx context.Context) ([]domain.Documento, error) {
+	filter := expression.AttributeExists(expression.Name("monto"))
+	projection := expression.NamesList(
+		expression.Name("id_documento"),
+		expression.Name("departamento"),
+		expression.Name("monto"),
+		expression.Name("moneda"),
+		expression.Name("monto_aplicado"),
+		expression.Name("estado_documento"),
+		expression.Name("estado_archivo"),
+	)
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(dynamo.table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	var documentos []domain.Documento
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []domain.Documento
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		documentos = append(documentos, page...)
+	}
+
+	return documentos, nil
+}
+
+func NewReporteServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *ReporteServiceDynamo {
+	return &ReporteServiceDynamo{
+		client: client,
+		table:  table,
+		ctx:    ctx,
+	}
+}
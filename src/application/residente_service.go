@@ -0,0 +1,13 @@
+package application
+
+import "main/src/domain"
+
+type ResidenteService interface {
+	CreateResidente(domain.ResidenteRequest) (domain.ResidenteResponse, error)
+	GetAllResidentes(string) ([]domain.ResidenteResponse, error)
+	GetResidente(string) (domain.ResidenteResponse, error)
+	FindByCognitoSub(string) (domain.ResidenteResponse, error)
+	UpdateResidente(domain.ResidenteRequest, string) (domain.ResidenteResponse, error)
+	DeleteResidente(string) (domain.ResidenteResponse, error)
+	HasDepartamentoResidentes(string) (bool, error)
+}
@@ -0,0 +1,349 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"main/src/domain"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// Índices globales de la tabla de residentes.
+	residenteDepartamentoIndex = "departamento-index"
+	residenteCognitoIndex      = "cognito_sub-index"
+)
+
+type ResidenteServiceDynamo struct {
+	client *dynamodb.Client
+	table  string
+	ctx    context.Context
+}
+
+func (dynamo ResidenteServiceDynamo) CreateResidente(req domain.ResidenteRequest) (response domain.ResidenteResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.CreateResidente")
+	defer func() { end(err) }()
+
+	if err = req.Validate(); err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+	if err = checkDepartamentoExists(ctx, dynamo.client, req.Departamento); err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	residente := req.ToResidente()
+	tracing.Annotate(ctx, "id_residente", residente.Residente_ID)
+
+	item, err := attributevalue.MarshalMap(residente)
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(dynamo.table),
+		Item:      item,
+	}
+
+	_, err = dynamo.client.PutItem(ctx, input)
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	return residente.ToResidenteResponse(), nil
+}
+
+// GetAllResidentes lista los residentes; con departamento solo los de ese departamento.
+func (dynamo ResidenteServiceDynamo) GetAllResidentes(departamento string) (residentesResponse []domain.ResidenteResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.GetAllResidentes")
+	defer func() { end(err) }()
+
+	add := func(items []map[string]types.AttributeValue) error {
+		var residentes []domain.Residente
+		if err := attributevalue.UnmarshalListOfMaps(items, &residentes); err != nil {
+			return err
+		}
+		for _, residente := range residentes {
+			residentesResponse = append(residentesResponse, residente.ToResidenteResponse())
+		}
+		return nil
+	}
+
+	if departamento == "" {
+		paginator := dynamodb.NewScanPaginator(dynamo.client, &dynamodb.ScanInput{TableName: aws.String(dynamo.table)})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(output.Items); err != nil {
+				return nil, err
+			}
+		}
+		return residentesResponse, nil
+	}
+
+	keyCondition := expression.Key("departamento").Equal(expression.Value(domain.NormalizeDepartamentoID(departamento)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(dynamo.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamo.table),
+		IndexName:                 aws.String(residenteDepartamentoIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(output.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	return residentesResponse, nil
+}
+
+// HasDepartamentoResidentes indica si algún residente está asignado al departamento.
+func (dynamo ResidenteServiceDynamo) HasDepartamentoResidentes(departamento string) (found bool, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.HasDepartamentoResidentes")
+	defer func() { end(err) }()
+
+	keyCondition := expression.Key("departamento").Equal(expression.Value(domain.NormalizeDepartamentoID(departamento)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return false, err
+	}
+
+	output, err := dynamo.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamo.table),
+		IndexName:                 aws.String(residenteDepartamentoIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(output.Items) > 0, nil
+}
+
+func (dynamo ResidenteServiceDynamo) GetResidente(id string) (response domain.ResidenteResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.GetResidente")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_residente", id)
+
+	residente, err := dynamo.getResidente(ctx, id)
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	return residente.ToResidenteResponse(), nil
+}
+
+// FindByCognitoSub devuelve el residente enlazado al usuario de Cognito.
+func (dynamo ResidenteServiceDynamo) FindByCognitoSub(sub string) (response domain.ResidenteResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.FindByCognitoSub")
+	defer func() { end(err) }()
+
+	keyCondition := expression.Key("cognito_sub").Equal(expression.Value(sub))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCondition).Build()
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	output, err := dynamo.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamo.table),
+		IndexName:                 aws.String(residenteCognitoIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	var residentes []domain.Residente
+	if err = attributevalue.UnmarshalListOfMaps(output.Items, &residentes); err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+	if len(residentes) == 0 {
+		err = domain.ErrResidenteNotFound
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	// Un usuario que se mudó de departamento conserva sus registros anteriores; se
+	// prefiere el que sigue activo
+	for _, residente := range residentes {
+		if response = residente.ToResidenteResponse(); response.Activo {
+			return response, nil
+		}
+	}
+	return residentes[0].ToResidenteResponse(), nil
+}
+
+// UpdateResidente reemplaza los datos del residente, incluidas las fechas de ingreso y salida.
+func (dynamo ResidenteServiceDynamo) UpdateResidente(req domain.ResidenteRequest, id string) (response domain.ResidenteResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.UpdateResidente")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_residente", id)
+
+	if err = req.Validate(); err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+	if err = checkDepartamentoExists(ctx, dynamo.client, req.Departamento); err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	update := expression.
+		Set(expression.Name("nombre"), expression.Value(req.Nombre)).
+		Set(expression.Name("rol"), expression.Value(req.Rol)).
+		Set(expression.Name("departamento"), expression.Value(req.Departamento))
+
+	// Los campos opcionales vacíos se eliminan: el índice por cognito_sub no admite ""
+	optional := map[string]string{
+		"documento_identidad": req.DocumentoIdentidad,
+		"email":               req.Email,
+		"telefono":            req.Telefono,
+		"cognito_sub":         req.CognitoSub,
+		"fecha_ingreso":       req.FechaIngreso,
+		"fecha_salida":        req.FechaSalida,
+	}
+	for name, value := range optional {
+		if value != "" {
+			update = update.Set(expression.Name(name), expression.Value(value))
+		} else {
+			update = update.Remove(expression.Name(name))
+		}
+	}
+	condition := expression.AttributeExists(expression.Name("id_residente"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       residenteKey(id),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrResidenteNotFound
+		}
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	var residente domain.Residente
+	if err = attributevalue.UnmarshalMap(output.Attributes, &residente); err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	return residente.ToResidenteResponse(), nil
+}
+
+func (dynamo ResidenteServiceDynamo) DeleteResidente(id string) (response domain.ResidenteResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ResidenteService.DeleteResidente")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "id_residente", id)
+
+	condition := expression.AttributeExists(expression.Name("id_residente"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName:                aws.String(dynamo.table),
+		Key:                      residenteKey(id),
+		ExpressionAttributeNames: expr.Names(),
+		ConditionExpression:      expr.Condition(),
+		ReturnValues:             types.ReturnValueAllOld,
+	}
+
+	output, err := dynamo.client.DeleteItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrResidenteNotFound
+		}
+		return domain.ResidenteResponse{Message: err.Error()}, err
+	}
+
+	var deleted domain.Residente
+	attributevalue.UnmarshalMap(output.Attributes, &deleted)
+
+	response = deleted.ToResidenteResponse()
+	response.Message = fmt.Sprintf("Residente: %s eliminado", id)
+
+	return response, nil
+}
+
+func (dynamo ResidenteServiceDynamo) getResidente(ctx context.Context, id string) (domain.Residente, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dynamo.table),
+		Key:       residenteKey(id),
+	}
+
+	output, err := dynamo.client.GetItem(ctx, input)
+	if err != nil {
+		return domain.Residente{}, err
+	}
+	if output.Item == nil {
+		return domain.Residente{}, domain.ErrResidenteNotFound
+	}
+
+	var residente domain.Residente
+	if err := attributevalue.UnmarshalMap(output.Item, &residente); err != nil {
+		return domain.Residente{}, err
+	}
+
+	return residente, nil
+}
+
+// checkDepartamentoExists valida la referencia al departamento si la tabla está configurada.
+func checkDepartamentoExists(ctx context.Context, client *dynamodb.Client, id string) error {
+	if DEPARTAMENTO_TABLE_NAME == "" {
+		return nil
+	}
+	exists, err := NewDepartamentoServiceDynamo(client, DEPARTAMENTO_TABLE_NAME, ctx).Exists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrDepartamentoInexistente
+	}
+	return nil
+}
+
+func residenteKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id_residente": &types.AttributeValueMemberS{Value: id}}
+}
+
+func NewResidenteServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *ResidenteServiceDynamo {
+	return &ResidenteServiceDynamo{
+		client: client,
+		table:  table,
+		ctx:    ctx,
+	}
+}
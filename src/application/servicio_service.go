@@ -0,0 +1,11 @@
+package application
+
+import "main/src/domain"
+
+type ServicioService interface {
+	CreateServicio(domain.ServicioRequest) (domain.ServicioResponse, error)
+	GetAllServicios() ([]domain.ServicioResponse, error)
+	GetServicio(string) (domain.ServicioResponse, error)
+	UpdateServicio(domain.ServicioRequest, string) (domain.ServicioResponse, error)
+	DeleteServicio(string) (domain.ServicioResponse, error)
+}
@@ -0,0 +1,242 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"main/src/domain"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type ServicioServiceDynamo struct {
+	client *dynamodb.Client
+	table  string
+	ctx    context.Context
+}
+
+func (dynamo ServicioServiceDynamo) CreateServicio(req domain.ServicioRequest) (response domain.ServicioResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ServicioService.CreateServicio")
+	defer func() { end(err) }()
+
+	if err = req.Validate(); err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	servicio := req.ToServicio()
+	tracing.Annotate(ctx, "codigo", servicio.Codigo)
+
+	item, err := attributevalue.MarshalMap(servicio)
+	if err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	// El código identifica al servicio: no se sobrescribe uno ya registrado
+	condition := expression.AttributeNotExists(expression.Name("codigo"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(dynamo.table),
+		Item:                     item,
+		ExpressionAttributeNames: expr.Names(),
+		ConditionExpression:      expr.Condition(),
+	}
+
+	_, err = dynamo.client.PutItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrServicioExists
+		}
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	return servicio.ToServicioResponse(), nil
+}
+
+func (dynamo ServicioServiceDynamo) GetAllServicios() (serviciosResponse []domain.ServicioResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ServicioService.GetAllServicios")
+	defer func() { end(err) }()
+
+	servicios, err := dynamo.scanServicios(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, servicio := range servicios {
+		serviciosResponse = append(serviciosResponse, servicio.ToServicioResponse())
+	}
+
+	return serviciosResponse, nil
+}
+
+func (dynamo ServicioServiceDynamo) scanServicios(ctx context.Context) ([]domain.Servicio, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(dynamo.table),
+	}
+
+	var servicios []domain.Servicio
+	paginator := dynamodb.NewScanPaginator(dynamo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []domain.Servicio
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, err
+		}
+		servicios = append(servicios, page...)
+	}
+
+	return servicios, nil
+}
+
+func (dynamo ServicioServiceDynamo) GetServicio(id string) (response domain.ServicioResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ServicioService.GetServicio")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "codigo", id)
+
+	servicio, err := dynamo.getServicio(ctx, id)
+	if err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	return servicio.ToServicioResponse(), nil
+}
+
+// UpdateServicio reemplaza los datos del servicio; el código no puede cambiarse porque
+// lo guardan los documentos. Sin activo se conserva el estado actual.
+func (dynamo ServicioServiceDynamo) UpdateServicio(req domain.ServicioRequest, id string) (response domain.ServicioResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ServicioService.UpdateServicio")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "codigo", id)
+
+	req.Codigo = id
+	if err = req.Validate(); err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	update := expression.
+		Set(expression.Name("nombre"), expression.Value(req.Nombre)).
+		Set(expression.Name("periodicidad"), expression.Value(req.Periodicidad)).
+		Set(expression.Name("monto_defecto"), expression.Value(req.MontoDefecto)).
+		Set(expression.Name("moneda"), expression.Value(req.Moneda)).
+		Set(expression.Name("base_calculo"), expression.Value(req.BaseCalculo))
+	if req.Activo != nil {
+		update = update.Set(expression.Name("activo"), expression.Value(*req.Activo))
+	}
+	condition := expression.AttributeExists(expression.Name("codigo"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(dynamo.table),
+		Key:                       servicioKey(domain.NormalizeServicioCodigo(id)),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	output, err := dynamo.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrServicioNotFound
+		}
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	var servicio domain.Servicio
+	if err = attributevalue.UnmarshalMap(output.Attributes, &servicio); err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	return servicio.ToServicioResponse(), nil
+}
+
+func (dynamo ServicioServiceDynamo) DeleteServicio(id string) (response domain.ServicioResponse, err error) {
+	ctx, end := tracing.Start(dynamo.ctx, "ServicioService.DeleteServicio")
+	defer func() { end(err) }()
+	tracing.Annotate(ctx, "codigo", id)
+
+	condition := expression.AttributeExists(expression.Name("codigo"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName:                aws.String(dynamo.table),
+		Key:                      servicioKey(domain.NormalizeServicioCodigo(id)),
+		ExpressionAttributeNames: expr.Names(),
+		ConditionExpression:      expr.Condition(),
+		ReturnValues:             types.ReturnValueAllOld,
+	}
+
+	output, err := dynamo.client.DeleteItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			err = domain.ErrServicioNotFound
+		}
+		return domain.ServicioResponse{Message: err.Error()}, err
+	}
+
+	var deleted domain.Servicio
+	attributevalue.UnmarshalMap(output.Attributes, &deleted)
+
+	response = deleted.ToServicioResponse()
+	response.Message = fmt.Sprintf("Servicio: %s eliminado", deleted.Codigo)
+
+	return response, nil
+}
+
+func (dynamo ServicioServiceDynamo) getServicio(ctx context.Context, id string) (domain.Servicio, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(dynamo.table),
+		Key:       servicioKey(domain.NormalizeServicioCodigo(id)),
+	}
+
+	output, err := dynamo.client.GetItem(ctx, input)
+	if err != nil {
+		return domain.Servicio{}, err
+	}
+	if output.Item == nil {
+		return domain.Servicio{}, domain.ErrServicioNotFound
+	}
+
+	var servicio domain.Servicio
+	if err := attributevalue.UnmarshalMap(output.Item, &servicio); err != nil {
+		return domain.Servicio{}, err
+	}
+
+	return servicio, nil
+}
+
+func servicioKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"codigo": &types.AttributeValueMemberS{Value: id}}
+}
+
+func NewServicioServiceDynamo(client *dynamodb.Client, table string, ctx context.Context) *ServicioServiceDynamo {
+	return &ServicioServiceDynamo{
+		client: client,
+		table:  table,
+		ctx:    ctx,
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"os"
+	"strings"
+
+	"main/src/domain"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	// AUTH_REQUIRED rechaza las peticiones sin usuario autenticado. Salvo que valga
+	// "false", solo se sirven archivos a usuarios identificados por el autorizador.
+	AUTH_REQUIRED    = os.Getenv("AUTH_REQUIRED")
+	AUTH_ADMIN_GROUP = os.Getenv("AUTH_ADMIN_GROUP")
+)
+
+const (
+	defaultAdminGroup = "admin"
+
+	// Atributo personalizado de Cognito con el departamento del residente.
+	departamentoClaim = "custom:departamento"
+)
+
+// Caller es el usuario que hace la petición según los claims del autorizador de Cognito.
+type Caller struct {
+	Subject      string
+	Username     string
+	Email        string
+	Groups       []string
+	Departamento string
+}
+
+// FromRequest lee los claims del autorizador; sin autorizador devuelve un Caller vacío.
+func FromRequest(request events.APIGatewayProxyRequest) Caller {
+	claims, ok := request.RequestContext.Authorizer["claims"].(map[string]interface{})
+	if !ok {
+		return Caller{}
+	}
+
+	claim := func(name string) string {
+		value, _ := claims[name].(string)
+		return value
+	}
+
+	return Caller{
+		Subject:      claim("sub"),
+		Username:     claim("cognito:username"),
+		Email:        claim("email"),
+		Groups:       groups(claims["cognito:groups"]),
+		Departamento: claim(departamentoClaim),
+	}
+}
+
+// Authenticated indica si la petición trae un usuario identificado.
+func (c Caller) Authenticated() bool {
+	return c.Subject != ""
+}
+
+// Name identifica al usuario en los registros de auditoría (p. ej. quién aprobó un pago).
+func (c Caller) Name() string {
+	for _, name := range []string{c.Email, c.Username, c.Subject} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// IsAdmin indica si el usuario pertenece al grupo de administradores.
+func (c Caller) IsAdmin() bool {
+	admin := AUTH_ADMIN_GROUP
+	if admin == "" {
+		admin = defaultAdminGroup
+	}
+	for _, group := range c.Groups {
+		if strings.EqualFold(group, admin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanView indica si el usuario puede ver los archivos del documento: los administradores
+// ven todos y los residentes solo los de su departamento.
+func (c Caller) CanView(documento domain.DocumentoResponse) bool {
+	if !c.Authenticated() {
+		return AUTH_REQUIRED == "false"
+	}
+	if c.IsAdmin() {
+		return true
+	}
+	return c.Departamento != "" && strings.EqualFold(strings.TrimSpace(c.Departamento), strings.TrimSpace(documento.Departamento))
+}
+
+// groups interpreta cognito:groups, que API Gateway entrega como lista o como texto "[a b]".
+func groups(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	case string:
+		return strings.FieldsFunc(strings.Trim(v, "[]"), func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package bank lee los extractos bancarios (CSV u OFX) y devuelve sus abonos como
+// movimientos listos para conciliar con los documentos.
+package bank
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"main/src/domain"
+	"main/src/extraction"
+)
+
+var (
+	// BANK_CSV_MAPPING indica las columnas del CSV del banco, p. ej.
+	// {"fecha": "Fecha Operación", "monto": "Importe", "numero_operacion": "Nro. Op."}.
+	BANK_CSV_MAPPING = os.Getenv("BANK_CSV_MAPPING")
+)
+
+// Formatos de extracto soportados.
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+)
+
+var ErrFormato = errors.New("formato de extracto no soportado, use csv u ofx")
+
+// Mapping relaciona los campos del movimiento con las columnas del CSV. Las columnas se
+// comparan sin distinguir mayúsculas ni espacios alrededor.
+type Mapping struct {
+	Fecha           string `json:"fecha"`
+	Monto           string `json:"monto"`
+	Descripcion     string `json:"descripcion"`
+	NumeroOperacion string `json:"numero_operacion"`
+	Referencia      string `json:"referencia"`
+	Moneda          string `json:"moneda"`
+	// FormatoFecha es un layout de Go ("02/01/2006"); vacío acepta día/mes/año e ISO.
+	FormatoFecha string `json:"formato_fecha"`
+	// Separador del CSV; vacío usa "," o ";" según la cabecera.
+	Separador string `json:"separador"`
+	// MonedaDefecto se usa cuando el CSV no tiene columna de moneda (PEN por defecto).
+	MonedaDefecto string `json:"moneda_defecto"`
+}
+
+// DefaultMapping corresponde a un CSV con cabeceras fecha, monto, descripcion,
+// numero_operacion y moneda.
+var DefaultMapping = Mapping{
+	Fecha:           "fecha",
+	Monto:           "monto",
+	Descripcion:     "descripcion",
+	NumeroOperacion: "numero_operacion",
+	Referencia:      "referencia",
+	Moneda:          "moneda",
+	MonedaDefecto:   domain.MonedaPEN,
+}
+
+// LoadMapping parte de DefaultMapping, aplica BANK_CSV_MAPPING y después el mapeo de la
+// solicitud, si lo hay. Los campos vacíos conservan el valor anterior.
+func LoadMapping(override string) (Mapping, error) {
+	mapping := DefaultMapping
+	// El orden importa: el mapeo de la solicitud se aplica último para que prevalezca
+	sources := []struct{ name, value string }{
+		{"BANK_CSV_MAPPING", BANK_CSV_MAPPING},
+		{"mapping", override},
+	}
+	for _, source := range sources {
+		if source.value == "" {
+			continue
+		}
+		var custom Mapping
+		if err := json.Unmarshal([]byte(source.value), &custom); err != nil {
+			return Mapping{}, fmt.Errorf("%s: %w", source.name, err)
+		}
+		mapping = mapping.merge(custom)
+	}
+	if mapping.Fecha == "" || mapping.Monto == "" {
+		return Mapping{}, errors.New("el mapeo necesita las columnas fecha y monto")
+	}
+	return mapping, nil
+}
+
+func (m Mapping) merge(custom Mapping) Mapping {
+	pick := func(base *string, value string) {
+		if value != "" {
+			*base = value
+		}
+	}
+	pick(&m.Fecha, custom.Fecha)
+	pick(&m.Monto, custom.Monto)
+	pick(&m.Descripcion, custom.Descripcion)
+	pick(&m.NumeroOperacion, custom.NumeroOperacion)
+	pick(&m.Referencia, custom.Referencia)
+	pick(&m.Moneda, custom.Moneda)
+	pick(&m.FormatoFecha, custom.FormatoFecha)
+	pick(&m.Separador, custom.Separador)
+	pick(&m.MonedaDefecto, custom.MonedaDefecto)
+	return m
+}
+
+// Detect reconoce un OFX por su cabecera; cualquier otro contenido se trata como CSV.
+func Detect(data []byte) string {
+	head := bytes.ToUpper(bytes.TrimSpace(data[:min(len(data), 512)]))
+	if bytes.HasPrefix(head, []byte("OFXHEADER")) || bytes.Contains(head, []byte("<OFX>")) {
+		return FormatOFX
+	}
+	return FormatCSV
+}
+
+// Parse lee el extracto en el formato indicado (o el detectado si está vacío) y
+// devuelve solo los abonos; los cargos del banco no corresponden a pagos de residentes.
+func Parse(data []byte, format string, mapping Mapping) ([]domain.MovimientoBancario, error) {
+	if format == "" {
+		format = Detect(data)
+	}
+
+	var movimientos []domain.MovimientoBancario
+	var err error
+	format = strings.ToLower(format)
+	switch format {
+	case FormatCSV:
+		movimientos, err = ParseCSV(data, mapping)
+	case FormatOFX:
+		movimientos, err = ParseOFX(data)
+	default:
+		return nil, ErrFormato
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Las filas idénticas de un CSV son abonos distintos; en OFX el FITID ya los distingue
+	// y repetirlo indica el mismo movimiento
+	ocurrencias := map[string]int{}
+	for i := range movimientos {
+		movimientos[i].AssignID(0)
+		if format != FormatCSV {
+			continue
+		}
+		id := movimientos[i].Movimiento_ID
+		if n := ocurrencias[id]; n > 0 {
+			movimientos[i].AssignID(n)
+		}
+		ocurrencias[id]++
+	}
+	return movimientos, nil
+}
+
+// parseAmount convierte un importe del extracto a céntimos. Devuelve un valor negativo
+// para los cargos, que se reconocen por el signo o por estar entre paréntesis.
+func parseAmount(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-") || (strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")"))
+	value = strings.Trim(value, "-+() ")
+	value = trimCurrency(value)
+	// El signo también puede ir después del símbolo: "S/ -120.00"
+	negative = negative || strings.HasPrefix(value, "-")
+	value = strings.TrimLeft(value, "-+ ")
+	// NormalizeMonto descarta los separadores iniciales: ".50" son 50 céntimos, no 50 soles
+	if strings.HasPrefix(value, ".") || strings.HasPrefix(value, ",") {
+		value = "0" + value
+	}
+
+	normalized, ok := extraction.NormalizeMonto(value)
+	if !ok {
+		return 0, fmt.Errorf("monto %q no es válido", value)
+	}
+	monto, err := domain.ParseMontoDecimal(normalized)
+	if err != nil {
+		return 0, fmt.Errorf("monto %q no es válido", value)
+	}
+	if negative {
+		monto = -monto
+	}
+	return monto, nil
+}
+
+// currencyPrefixes son los símbolos de moneda con que los bancos anteponen el importe,
+// los más largos primero para que "S/." no deje el punto.
+var currencyPrefixes = []string{"S/.", "S/", "US$", "USD", "PEN", "$"}
+
+func trimCurrency(value string) string {
+	for _, prefix := range currencyPrefixes {
+		if len(value) >= len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+			return strings.TrimSpace(value[len(prefix):])
+		}
+	}
+	return value
+}
@@ -0,0 +1,138 @@
+package bank
+
+import (
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		monto   int64
+		wantErr bool
+	}{
+		{value: "1250.50", monto: 125050},
+		{value: "1,250.50", monto: 125050},
+		{value: "1.250,50", monto: 125050},
+		{value: "S/ 1,250.50", monto: 125050},
+		{value: "S/. 1,250.50", monto: 125050},
+		{value: "US$ 99.90", monto: 9990},
+		{value: "USD 99.90", monto: 9990},
+		{value: "PEN 10", monto: 1000},
+		{value: ".50", monto: 50},
+		{value: "S/ .50", monto: 50},
+		{value: ",5", monto: 50},
+		{value: "-120.00", monto: -12000},
+		{value: "(120.00)", monto: -12000},
+		{value: "S/ -120.00", monto: -12000},
+		{value: "+75.25", monto: 7525},
+		{value: "", wantErr: true},
+		{value: "S/", wantErr: true},
+		{value: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		monto, err := parseAmount(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseAmount(%q) = %d, se esperaba error", tt.value, monto)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", tt.value, err)
+			continue
+		}
+		if monto != tt.monto {
+			t.Errorf("parseAmount(%q) = %d, se esperaba %d", tt.value, monto, tt.monto)
+		}
+	}
+}
+
+func TestLoadMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		override string
+		want     Mapping
+		wantErr  bool
+	}{
+		{name: "por defecto", want: DefaultMapping},
+		{
+			name: "variable de entorno",
+			env:  `{"fecha": "Fecha Operación", "monto": "Importe"}`,
+			want: func() Mapping {
+				m := DefaultMapping
+				m.Fecha, m.Monto = "Fecha Operación", "Importe"
+				return m
+			}(),
+		},
+		{
+			name:     "la solicitud prevalece sobre la variable",
+			env:      `{"fecha": "Fecha Operación", "monto": "Importe", "separador": ";"}`,
+			override: `{"monto": "Abono"}`,
+			want: func() Mapping {
+				m := DefaultMapping
+				m.Fecha, m.Monto, m.Separador = "Fecha Operación", "Abono", ";"
+				return m
+			}(),
+		},
+		{name: "variable inválida", env: `{`, wantErr: true},
+		{name: "solicitud inválida", override: `{"monto": 1}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := BANK_CSV_MAPPING
+			BANK_CSV_MAPPING = tt.env
+			defer func() { BANK_CSV_MAPPING = previous }()
+
+			mapping, err := LoadMapping(tt.override)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadMapping = %+v, se esperaba error", mapping)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMapping: %v", err)
+			}
+			if mapping != tt.want {
+				t.Errorf("LoadMapping = %+v, se esperaba %+v", mapping, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDistinguishesIdenticalRows(t *testing.T) {
+	data := []byte("fecha,monto,descripcion\n" +
+		"2024-03-01,150.00,ABONO\n" +
+		"2024-03-01,150.00,ABONO\n" +
+		"2024-03-02,150.00,ABONO\n")
+
+	movimientos, err := Parse(data, FormatCSV, DefaultMapping)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(movimientos) != 3 {
+		t.Fatalf("Parse devolvió %d movimientos, se esperaban 3", len(movimientos))
+	}
+
+	ids := map[string]bool{}
+	for _, movimiento := range movimientos {
+		if ids[movimiento.Movimiento_ID] {
+			t.Errorf("ID repetido %s", movimiento.Movimiento_ID)
+		}
+		ids[movimiento.Movimiento_ID] = true
+	}
+
+	// Importar otra vez el mismo extracto debe dar los mismos IDs
+	again, err := Parse(data, FormatCSV, DefaultMapping)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	for i := range again {
+		if again[i].Movimiento_ID != movimientos[i].Movimiento_ID {
+			t.Errorf("movimiento %d: ID %s, se esperaba %s", i, again[i].Movimiento_ID, movimientos[i].Movimiento_ID)
+		}
+	}
+}
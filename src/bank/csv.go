@@ -0,0 +1,142 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"main/src/domain"
+	"main/src/extraction"
+)
+
+// ParseCSV lee un extracto CSV con las columnas del mapeo. Las filas de cargos y las
+// vacías se omiten; una fila con fecha o monto inválidos detiene la lectura para no
+// conciliar un extracto a medias.
+func ParseCSV(data []byte, mapping Mapping) ([]domain.MovimientoBancario, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = separator(data, mapping.Separador)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cabecera del CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[normalizeColumn(name)] = i
+	}
+
+	index := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		if i, ok := columns[normalizeColumn(name)]; ok {
+			return i
+		}
+		return -1
+	}
+	fecha, monto := index(mapping.Fecha), index(mapping.Monto)
+	if fecha < 0 || monto < 0 {
+		return nil, fmt.Errorf("el CSV no tiene las columnas %q y %q", mapping.Fecha, mapping.Monto)
+	}
+	descripcion, operacion := index(mapping.Descripcion), index(mapping.NumeroOperacion)
+	referencia, moneda := index(mapping.Referencia), index(mapping.Moneda)
+
+	var movimientos []domain.MovimientoBancario
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", line, err)
+		}
+
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if field(fecha) == "" && field(monto) == "" {
+			continue
+		}
+
+		dia, err := parseDate(field(fecha), mapping.FormatoFecha)
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", line, err)
+		}
+		amount, err := parseAmount(field(monto))
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", line, err)
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		currency := strings.ToUpper(field(moneda))
+		switch currency {
+		case "":
+			currency = mapping.MonedaDefecto
+		case "S/", "S/.", "SOLES":
+			currency = domain.MonedaPEN
+		case "US$", "$", "DOLARES", "DÓLARES":
+			currency = domain.MonedaUSD
+		}
+
+		movimientos = append(movimientos, domain.MovimientoBancario{
+			Fecha:           dia,
+			Monto:           amount,
+			Moneda:          currency,
+			Descripcion:     field(descripcion),
+			NumeroOperacion: field(operacion),
+			Referencia:      field(referencia),
+		})
+	}
+	return movimientos, nil
+}
+
+// separator usa el separador configurado o elige entre "," y ";" según la cabecera:
+// los bancos locales exportan con ";" porque la coma es el separador decimal.
+func separator(data []byte, configured string) rune {
+	if configured != "" {
+		if configured == `\t` {
+			return '\t'
+		}
+		return []rune(configured)[0]
+	}
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func parseDate(value, layout string) (string, error) {
+	if layout != "" {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return "", fmt.Errorf("fecha %q no tiene el formato %s", value, layout)
+		}
+		return t.Format("2006-01-02"), nil
+	}
+	// Algunos bancos agregan la hora a la fecha
+	if fields := strings.Fields(value); len(fields) > 1 {
+		value = fields[0]
+	}
+	fecha, ok := extraction.NormalizeFecha(value)
+	if !ok {
+		return "", fmt.Errorf("fecha %q no es válida", value)
+	}
+	return fecha, nil
+}
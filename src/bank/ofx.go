@@ -0,0 +1,105 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"main/src/domain"
+)
+
+var ofxCurrency = regexp.MustCompile(`(?i)<CURDEF>\s*([A-Za-z]{3})`)
+
+// ParseOFX lee las transacciones STMTTRN de un OFX, tanto en SGML (etiquetas sin
+// cerrar) como en XML. FITID queda como referencia y CHECKNUM o REFNUM como número de
+// operación.
+func ParseOFX(data []byte) ([]domain.MovimientoBancario, error) {
+	content := string(data)
+	blocks := ofxTransactions(content)
+	if len(blocks) == 0 {
+		return nil, errors.New("el OFX no tiene transacciones STMTTRN")
+	}
+
+	currency := domain.MonedaPEN
+	if match := ofxCurrency.FindStringSubmatch(content); match != nil {
+		currency = strings.ToUpper(match[1])
+	}
+
+	var movimientos []domain.MovimientoBancario
+	for i, block := range blocks {
+		amount, err := parseAmount(ofxTag(block, "TRNAMT"))
+		if err != nil {
+			return nil, fmt.Errorf("transacción %d: %w", i+1, err)
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		posted := ofxTag(block, "DTPOSTED")
+		if len(posted) < 8 {
+			return nil, fmt.Errorf("transacción %d: DTPOSTED %q no es válido", i+1, posted)
+		}
+		fecha, err := parseDate(posted[:8], "20060102")
+		if err != nil {
+			return nil, fmt.Errorf("transacción %d: %w", i+1, err)
+		}
+
+		descripcion := ofxTag(block, "NAME")
+		if memo := ofxTag(block, "MEMO"); memo != "" && memo != descripcion {
+			descripcion = strings.TrimSpace(descripcion + " " + memo)
+		}
+		operacion := ofxTag(block, "CHECKNUM")
+		if operacion == "" {
+			operacion = ofxTag(block, "REFNUM")
+		}
+
+		movimientos = append(movimientos, domain.MovimientoBancario{
+			Fecha:           fecha,
+			Monto:           amount,
+			Moneda:          currency,
+			Descripcion:     descripcion,
+			NumeroOperacion: operacion,
+			Referencia:      ofxTag(block, "FITID"),
+		})
+	}
+	return movimientos, nil
+}
+
+// ofxTransactions separa los bloques STMTTRN. En SGML el cierre es opcional, así que
+// cada bloque termina en el siguiente STMTTRN o en el fin de la lista.
+func ofxTransactions(content string) []string {
+	upper := strings.ToUpper(content)
+	var blocks []string
+	for {
+		start := strings.Index(upper, "<STMTTRN>")
+		if start < 0 {
+			return blocks
+		}
+		upper, content = upper[start+len("<STMTTRN>"):], content[start+len("<STMTTRN>"):]
+
+		end := len(upper)
+		for _, closing := range []string{"</STMTTRN>", "<STMTTRN>", "</BANKTRANLIST>"} {
+			if i := strings.Index(upper, closing); i >= 0 && i < end {
+				end = i
+			}
+		}
+		blocks = append(blocks, content[:end])
+		upper, content = upper[end:], content[end:]
+	}
+}
+
+// ofxTag devuelve el valor de una etiqueta: en SGML termina en el siguiente "<" o en el
+// salto de línea.
+func ofxTag(block, tag string) string {
+	upper := strings.ToUpper(block)
+	start := strings.Index(upper, "<"+tag+">")
+	if start < 0 {
+		return ""
+	}
+	value := block[start+len(tag)+2:]
+	if end := strings.IndexAny(value, "<\r\n"); end >= 0 {
+		value = value[:end]
+	}
+	return strings.TrimSpace(value)
+}
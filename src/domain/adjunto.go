@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"errors"
+	"net/url"
+	"path"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDocumentoNotFound = errors.New("documento no encontrado")
+	ErrAdjuntoNotFound   = errors.New("adjunto no encontrado")
+	ErrAdjuntoPrincipal  = errors.New("el adjunto principal no puede eliminarse, elimine el documento")
+	ErrArchivoRechazado  = errors.New("el archivo fue rechazado por contener malware")
+)
+
+// DuplicateError indica que el archivo principal ya fue subido para otro documento.
+type DuplicateError struct {
+	DocumentoID string
+}
+
+func (e *DuplicateError) Error() string {
+	return "el comprobante ya fue registrado en el documento " + e.DocumentoID
+}
+
+// Adjunto es un archivo asociado a un documento (voucher, factura, etc.).
+type Adjunto struct {
+	Adjunto_ID     string `dynamodbav:"id_adjunto" json:"id_adjunto"`
+	Key            string `dynamodbav:"key" json:"key"`
+	NombreOriginal string `dynamodbav:"nombre_original" json:"nombre_original"`
+	ContentType    string `dynamodbav:"content_type" json:"content_type"`
+	Size           int64  `dynamodbav:"size" json:"size"`
+	Checksum       string `dynamodbav:"checksum" json:"checksum"`
+	Principal      bool   `dynamodbav:"principal,omitempty" json:"principal"`
+}
+
+// Archivos derivados del documento que /document/{id}/file sirve con ?archivo=.
+const (
+	ArchivoMiniatura = "miniatura"
+	ArchivoRecibo    = "recibo"
+)
+
+// File devuelve el archivo a descargar: el adjunto indicado o, con adjuntoID vacío, el
+// archivo principal. Los documentos anteriores a los adjuntos solo tienen FileKey.
+func (res DocumentoResponse) File(adjuntoID string) (Adjunto, error) {
+	for _, adjunto := range res.Adjuntos {
+		if adjunto.Adjunto_ID == adjuntoID || (adjuntoID == "" && adjunto.Principal) {
+			return adjunto, nil
+		}
+	}
+	if adjuntoID != "" {
+		return Adjunto{}, ErrAdjuntoNotFound
+	}
+
+	if res.FileKey == "" {
+		return Adjunto{}, ErrAdjuntoNotFound
+	}
+	return Adjunto{Key: res.FileKey, NombreOriginal: res.Documento_ID + path.Ext(res.FileKey), Principal: true}, nil
+}
+
+// Derivado devuelve la miniatura o la constancia de pago del documento.
+func (res DocumentoResponse) Derivado(archivo string) (Adjunto, error) {
+	var key, nombre string
+	switch archivo {
+	case ArchivoMiniatura:
+		key, nombre = res.ThumbnailKey, res.Documento_ID+"-miniatura"+path.Ext(res.ThumbnailKey)
+	case ArchivoRecibo:
+		key, nombre = res.ReciboKey, "constancia-"+res.NumeroRecibo+path.Ext(res.ReciboKey)
+	}
+	if key == "" {
+		return Adjunto{}, ErrAdjuntoNotFound
+	}
+	return Adjunto{Key: key, NombreOriginal: nombre}, nil
+}
+
+// FileParams devuelve los parámetros de /document/{id}/file que sirven el objeto con
+// esa key, o false si la key no pertenece al documento.
+func (res DocumentoResponse) FileParams(key string) (url.Values, bool) {
+	for _, adjunto := range res.Adjuntos {
+		if adjunto.Key != key {
+			continue
+		}
+		if adjunto.Principal {
+			return url.Values{}, true
+		}
+		return url.Values{"id_adjunto": {adjunto.Adjunto_ID}}, true
+	}
+	switch key {
+	case "":
+		return nil, false
+	case res.FileKey:
+		return url.Values{}, true
+	case res.ThumbnailKey:
+		return url.Values{"archivo": {ArchivoMiniatura}}, true
+	case res.ReciboKey:
+		return url.Values{"archivo": {ArchivoRecibo}}, true
+	}
+	return nil, false
+}
+
+// NewAdjunto crea un adjunto calculando su tamaño y checksum SHA-256.
+func NewAdjunto(nombreOriginal, contentType string, data []byte) Adjunto {
+	return Adjunto{
+		Adjunto_ID:     uuid.NewString(),
+		NombreOriginal: nombreOriginal,
+		ContentType:    contentType,
+		Size:           int64(len(data)),
+		Checksum:       ChecksumSHA256(data),
+	}
+}
+
+// AssignKey calcula la key del adjunto en el bucket a partir de su contenido y
+// marca si es el archivo principal del documento.
+func (adj *Adjunto) AssignKey(principal bool) {
+	adj.Key = BlobObjectKey(adj.Checksum, FileExtension(adj.ContentType))
+	adj.Principal = principal
+}
@@ -0,0 +1,275 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Estado de un cargo según lo pagado.
+const (
+	EstadoCargoPendiente = "pendiente"
+	EstadoCargoParcial   = "parcial"
+	EstadoCargoPagado    = "pagado"
+)
+
+// BILLING_DUE_DAY es el día del mes en que vencen los cargos del periodo (15 por defecto).
+var BILLING_DUE_DAY = os.Getenv("BILLING_DUE_DAY")
+
+var (
+	ErrCargoNotFound            = errors.New("cargo no encontrado")
+	ErrPeriodoInvalido          = errors.New("periodo debe tener formato AAAA-MM")
+	ErrPagoInvalido             = errors.New("el monto a aplicar debe ser positivo y no superar el saldo del cargo ni lo disponible del documento")
+	ErrPagoNoAprobado           = errors.New("solo los documentos aprobados pueden aplicarse a cargos")
+	ErrPagoDepartamento         = errors.New("el documento y el cargo son de departamentos distintos")
+	ErrPagoMoneda               = errors.New("el documento y el cargo deben estar en la misma moneda")
+	ErrPagoSinCargos            = errors.New("el departamento no tiene cargos pendientes para el servicio del documento")
+	ErrPagoConcurrente          = errors.New("el cargo o el documento cambiaron mientras se aplicaba el pago, intente nuevamente")
+	ErrFacturacionNoConfigurada = errors.New("DEPARTAMENTO_TABLE_NAME y SERVICIO_TABLE_NAME son necesarios para generar cargos")
+	ErrDocumentoAplicado        = errors.New("el documento ya se aplicó a cargos: no puede eliminarse, dejar de estar aprobado, bajar su monto de lo aplicado ni cambiar su departamento o moneda")
+)
+
+// PagoAplicado registra qué parte de un documento se usó para pagar un cargo.
+type PagoAplicado struct {
+	Documento_ID string `dynamodbav:"id_documento" json:"id_documento"`
+	Monto        int64  `dynamodbav:"monto" json:"monto"`
+	Fecha        string `dynamodbav:"fecha" json:"fecha"`
+}
+
+// Cargo es lo que un departamento debe por un servicio en un periodo. Los montos están
+// en céntimos; Pagado se guarda siempre para poder condicionar las actualizaciones.
+type Cargo struct {
+	Cargo_ID         string         `dynamodbav:"id_cargo" json:"id_cargo"`
+	Departamento     string         `dynamodbav:"departamento" json:"departamento"`
+	Servicio         string         `dynamodbav:"servicio" json:"servicio"`
+	NombreServicio   string         `dynamodbav:"nombre_servicio" json:"nombre_servicio"`
+	Periodo          string         `dynamodbav:"periodo" json:"periodo"`
+	Monto            int64          `dynamodbav:"monto" json:"monto"`
+	Moneda           string         `dynamodbav:"moneda" json:"moneda"`
+	Pagado           int64          `dynamodbav:"pagado" json:"pagado"`
+	Estado           string         `dynamodbav:"estado" json:"estado"`
+	FechaVencimiento string         `dynamodbav:"fecha_vencimiento" json:"fecha_vencimiento"`
+	Pagos            []PagoAplicado `dynamodbav:"pagos,omitempty" json:"pagos"`
+}
+
+type CargoResponse struct {
+	Cargo_ID         string         `json:"id_cargo"`
+	Departamento     string         `json:"departamento"`
+	Servicio         string         `json:"servicio"`
+	NombreServicio   string         `json:"nombre_servicio"`
+	Periodo          string         `json:"periodo"`
+	Monto            int64          `json:"monto"`
+	Moneda           string         `json:"moneda"`
+	Pagado           int64          `json:"pagado"`
+	Saldo            int64          `json:"saldo"`
+	Estado           string         `json:"estado"`
+	FechaVencimiento string         `json:"fecha_vencimiento"`
+	Pagos            []PagoAplicado `json:"pagos,omitempty"`
+}
+
+// FacturacionRequest pide generar los cargos de un periodo. Sin servicios se cobran los
+// activos que tocan en el mes según su periodicidad; con servicios se cobran solo esos,
+// toque o no (así se emite una cuota extraordinaria de pago único).
+type FacturacionRequest struct {
+	Periodo   string   `json:"periodo"`
+	Servicios []string `json:"servicios"`
+}
+
+// Facturacion resume una generación de cargos. Generarla otra vez para el mismo
+// periodo no duplica cargos: los ya existentes se cuentan en Existentes.
+type Facturacion struct {
+	Periodo    string          `json:"periodo"`
+	Creados    int             `json:"creados"`
+	Existentes int             `json:"existentes"`
+	Cargos     []CargoResponse `json:"cargos"`
+	Message    string          `json:"message"`
+}
+
+// AplicarPagoRequest aplica un documento a un cargo. Sin id_cargo el pago se reparte
+// entre los cargos pendientes del servicio del documento, del más antiguo al más
+// reciente, y monto es el tope del total; sin monto se aplica todo lo posible.
+type AplicarPagoRequest struct {
+	Cargo_ID string `json:"id_cargo"`
+	Monto    int64  `json:"monto"`
+}
+
+type AplicacionPago struct {
+	Documento_ID string          `json:"id_documento"`
+	Aplicado     int64           `json:"aplicado"`
+	Disponible   int64           `json:"disponible"`
+	Cargos       []CargoResponse `json:"cargos"`
+	Message      string          `json:"message"`
+}
+
+// SaldoDepartamento es el estado de cuenta de un departamento, por moneda.
+type SaldoDepartamento struct {
+	Departamento string           `json:"departamento"`
+	Cargado      map[string]int64 `json:"cargado"`
+	Pagado       map[string]int64 `json:"pagado"`
+	Saldo        map[string]int64 `json:"saldo"`
+	Cargos       []CargoResponse  `json:"cargos"`
+	Message      string           `json:"message"`
+}
+
+// CargoID identifica el cargo de un servicio para un departamento y periodo; al ser
+// determinista la generación puede repetirse sin duplicar cargos.
+func CargoID(departamento, servicio, periodo string) string {
+	return departamento + "#" + servicio + "#" + periodo
+}
+
+// ParsePeriodo lee un periodo de facturación ("2024-03").
+func ParsePeriodo(value string) (time.Time, error) {
+	periodo, err := time.Parse("2006-01", value)
+	if err != nil {
+		return time.Time{}, ErrPeriodoInvalido
+	}
+	return periodo, nil
+}
+
+// AplicaEnPeriodo indica si el servicio se cobra en el mes del periodo. Los ciclos
+// empiezan en enero: un servicio trimestral se cobra en enero, abril, julio y octubre.
+func (srv Servicio) AplicaEnPeriodo(periodo time.Time) bool {
+	meses := periodicidades[srv.Periodicidad]
+	if meses == 0 {
+		return false
+	}
+	return (int(periodo.Month())-1)%meses == 0
+}
+
+// NuevoCargo calcula el cargo de un servicio para un departamento en el periodo.
+func NuevoCargo(dep Departamento, srv Servicio, periodo time.Time) (Cargo, error) {
+	monto, err := srv.MontoPara(dep)
+	if err != nil {
+		return Cargo{}, err
+	}
+
+	value := periodo.Format("2006-01")
+	cargo := Cargo{
+		Cargo_ID:         CargoID(dep.Departamento_ID, srv.Codigo, value),
+		Departamento:     dep.Departamento_ID,
+		Servicio:         srv.Codigo,
+		NombreServicio:   srv.Nombre,
+		Periodo:          value,
+		Monto:            monto,
+		Moneda:           srv.Moneda,
+		FechaVencimiento: vencimiento(periodo).Format("2006-01-02"),
+	}
+	cargo.Estado = cargo.EstadoActual()
+	return cargo, nil
+}
+
+// vencimiento devuelve el día de vencimiento del periodo, ajustado a fin de mes.
+func vencimiento(periodo time.Time) time.Time {
+	dia, err := strconv.Atoi(BILLING_DUE_DAY)
+	if err != nil || dia < 1 {
+		dia = 15
+	}
+	ultimo := periodo.AddDate(0, 1, -periodo.Day()).Day()
+	if dia > ultimo {
+		dia = ultimo
+	}
+	return time.Date(periodo.Year(), periodo.Month(), dia, 0, 0, 0, 0, time.UTC)
+}
+
+func (cargo Cargo) Saldo() int64 {
+	return cargo.Monto - cargo.Pagado
+}
+
+// EstadoActual deriva el estado de lo pagado; un cargo de monto cero nace pagado.
+func (cargo Cargo) EstadoActual() string {
+	switch {
+	case cargo.Saldo() <= 0:
+		return EstadoCargoPagado
+	case cargo.Pagado > 0:
+		return EstadoCargoParcial
+	default:
+		return EstadoCargoPendiente
+	}
+}
+
+func (cargo Cargo) ToCargoResponse() CargoResponse {
+	return CargoResponse{
+		Cargo_ID:         cargo.Cargo_ID,
+		Departamento:     cargo.Departamento,
+		Servicio:         cargo.Servicio,
+		NombreServicio:   cargo.NombreServicio,
+		Periodo:          cargo.Periodo,
+		Monto:            cargo.Monto,
+		Moneda:           cargo.Moneda,
+		Pagado:           cargo.Pagado,
+		Saldo:            cargo.Saldo(),
+		Estado:           cargo.EstadoActual(),
+		FechaVencimiento: cargo.FechaVencimiento,
+		Pagos:            cargo.Pagos,
+	}
+}
+
+// Disponible es la parte del monto del documento que todavía no se aplicó a cargos.
+func (doc Documento) Disponible() int64 {
+	return doc.Monto - doc.MontoAplicado
+}
+
+// ValidarPago comprueba que el documento pueda pagar el cargo.
+func ValidarPago(doc Documento, cargo Cargo) error {
+	if !IsAprobado(doc.StateDocument) {
+		return ErrPagoNoAprobado
+	}
+	if NormalizeDepartamentoID(doc.Departamento) != cargo.Departamento {
+		return ErrPagoDepartamento
+	}
+	if doc.Moneda != cargo.Moneda {
+		return ErrPagoMoneda
+	}
+	return nil
+}
+
+// MontoAAplicar decide cuánto del documento se aplica al cargo: lo pedido o, sin monto,
+// lo máximo posible.
+func MontoAAplicar(doc Documento, cargo Cargo, pedido int64) (int64, error) {
+	maximo := min(doc.Disponible(), cargo.Saldo())
+	if pedido == 0 {
+		pedido = maximo
+	}
+	if pedido <= 0 || pedido > maximo {
+		return 0, ErrPagoInvalido
+	}
+	return pedido, nil
+}
+
+// OrdenarCargos ordena por periodo y luego por servicio, del más antiguo al más reciente.
+func OrdenarCargos(cargos []Cargo) {
+	sort.Slice(cargos, func(i, j int) bool {
+		if cargos[i].Periodo != cargos[j].Periodo {
+			return cargos[i].Periodo < cargos[j].Periodo
+		}
+		return cargos[i].Servicio < cargos[j].Servicio
+	})
+}
+
+// CalcularSaldo suma lo cargado y lo pagado del departamento, por moneda.
+func CalcularSaldo(departamento string, cargos []Cargo) SaldoDepartamento {
+	OrdenarCargos(cargos)
+
+	saldo := SaldoDepartamento{
+		Departamento: departamento,
+		Cargado:      map[string]int64{},
+		Pagado:       map[string]int64{},
+		Saldo:        map[string]int64{},
+		Cargos:       []CargoResponse{},
+	}
+	for _, cargo := range cargos {
+		saldo.Cargado[cargo.Moneda] += cargo.Monto
+		saldo.Pagado[cargo.Moneda] += cargo.Pagado
+		saldo.Saldo[cargo.Moneda] += cargo.Saldo()
+		saldo.Cargos = append(saldo.Cargos, cargo.ToCargoResponse())
+	}
+	return saldo
+}
+
+// String describe el cargo en los logs y mensajes: "A-101 mantenimiento 2024-03".
+func (cargo Cargo) String() string {
+	return fmt.Sprintf("%s %s %s", cargo.Departamento, cargo.Servicio, cargo.Periodo)
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "strings"
+
+// EscapeFormula antepone un apóstrofo a los textos que una hoja de cálculo interpretaría
+// como fórmula ("=", "+", "-", "@" o un tabulador al inicio). Se aplica a los campos de
+// texto que escribe el usuario antes de exportarlos, nunca a los números.
+func EscapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
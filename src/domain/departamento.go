@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+var (
+	ErrDepartamentoNotFound    = errors.New("departamento no encontrado")
+	ErrDepartamentoExists      = errors.New("el departamento ya está registrado")
+	ErrDepartamentoEnUso       = errors.New("el departamento tiene documentos, residentes o cargos registrados, no puede eliminarse")
+	ErrDepartamentoInvalido    = errors.New("numero es obligatorio y area no puede ser negativa")
+	ErrDepartamentoInexistente = errors.New("el departamento no está registrado")
+)
+
+type DepartamentoRequest struct {
+	Numero      string   `json:"numero"`
+	Torre       string   `json:"torre"`
+	Piso        int      `json:"piso"`
+	Area        float64  `json:"area"`
+	Propietario string   `json:"propietario"`
+	Ocupantes   []string `json:"ocupantes"`
+}
+
+// Departamento es una unidad del edificio. Su ID ("A-101", o "101" sin torre) es el
+// valor que los documentos guardan en departamento.
+type Departamento struct {
+	Departamento_ID string   `dynamodbav:"id_departamento" json:"id_departamento"`
+	Numero          string   `dynamodbav:"numero" json:"numero"`
+	Torre           string   `dynamodbav:"torre,omitempty" json:"torre"`
+	Piso            int      `dynamodbav:"piso" json:"piso"`
+	Area            float64  `dynamodbav:"area" json:"area"`
+	Propietario     string   `dynamodbav:"propietario,omitempty" json:"propietario"`
+	Ocupantes       []string `dynamodbav:"ocupantes,omitempty" json:"ocupantes"`
+}
+
+type DepartamentoResponse struct {
+	Departamento_ID string   `json:"id_departamento"`
+	Numero          string   `json:"numero"`
+	Torre           string   `json:"torre,omitempty"`
+	Piso            int      `json:"piso"`
+	Area            float64  `json:"area"`
+	Propietario     string   `json:"propietario,omitempty"`
+	Ocupantes       []string `json:"ocupantes,omitempty"`
+	Message         string   `json:"message"`
+}
+
+// DepartamentoID arma el identificador de un departamento a partir de su torre y número.
+func DepartamentoID(torre, numero string) string {
+	torre = NormalizeDepartamentoID(torre)
+	numero = NormalizeDepartamentoID(numero)
+	if torre == "" {
+		return numero
+	}
+	return torre + "-" + numero
+}
+
+// NormalizeDepartamentoID quita espacios y pasa a mayúsculas, para que "a-101 " y
+// "A-101" sean el mismo departamento.
+func NormalizeDepartamentoID(value string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(value), ""))
+}
+
+// Validate comprueba los campos obligatorios y limpia los ocupantes vacíos.
+func (req *DepartamentoRequest) Validate() error {
+	req.Numero = strings.TrimSpace(req.Numero)
+	req.Torre = strings.TrimSpace(req.Torre)
+	req.Propietario = strings.TrimSpace(req.Propietario)
+
+	if req.Numero == "" || req.Area < 0 {
+		return ErrDepartamentoInvalido
+	}
+
+	ocupantes := req.Ocupantes[:0]
+	for _, ocupante := range req.Ocupantes {
+		if ocupante = strings.TrimSpace(ocupante); ocupante != "" {
+			ocupantes = append(ocupantes, ocupante)
+		}
+	}
+	req.Ocupantes = ocupantes
+	return nil
+}
+
+// LogValue expone los campos como atributos para que el logger redacte los personales.
+func (req DepartamentoRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("numero", req.Numero),
+		slog.String("torre", req.Torre),
+		slog.Int("piso", req.Piso),
+		slog.Int("ocupantes", len(req.Ocupantes)),
+	)
+}
+
+func (req DepartamentoRequest) ToDepartamento() Departamento {
+	return Departamento{
+		Departamento_ID: DepartamentoID(req.Torre, req.Numero),
+		Numero:          req.Numero,
+		Torre:           req.Torre,
+		Piso:            req.Piso,
+		Area:            req.Area,
+		Propietario:     req.Propietario,
+		Ocupantes:       req.Ocupantes,
+	}
+}
+
+func (dep Departamento) ToDepartamentoResponse() DepartamentoResponse {
+	return DepartamentoResponse{
+		Departamento_ID: dep.Departamento_ID,
+		Numero:          dep.Numero,
+		Torre:           dep.Torre,
+		Piso:            dep.Piso,
+		Area:            dep.Area,
+		Propietario:     dep.Propietario,
+		Ocupantes:       dep.Ocupantes,
+	}
+}
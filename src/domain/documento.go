@@ -1,7 +1,8 @@
 package domain
 
 import (
-	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 
 	"github.com/google/uuid"
@@ -10,60 +11,261 @@ import (
 var (
 	BUCKET_NAME = os.Getenv("BUCKET_NAME")
 	BUCKET_KEY  = os.Getenv("BUCKET_KEY")
+
+	// URL base de la API, p. ej. https://<api>.execute-api.<región>.amazonaws.com/Prod; los
+	// enlaces a archivos de las respuestas apuntan a /document/{id}/file bajo ella.
+	API_BASE_URL = os.Getenv("API_BASE_URL")
+)
+
+// Estados del archivo subido según el análisis antimalware de sqs_consumer.
+const (
+	EstadoArchivoPendiente = "pending_scan"
+	EstadoArchivoLimpio    = "clean"
+	EstadoArchivoMalware   = "rejected_malware"
 )
 
 type DocumentoRequest struct {
 	Departamento   string `json:"departamento"`
 	Residente      string `json:"residente"`
+	ResidenteID    string `json:"id_residente,omitempty"`
 	FechaDePago    string `json:"fecha_de_pago"`
 	TipoDeServicio string `json:"tipo_de_servicio"`
+	NombreServicio string `json:"-"`
 	StateDocument	string `json:"estado_documento"`
+	AprobadoPor    string `json:"aprobado_por,omitempty"`
+	Monto          int64  `json:"monto,omitempty"`
+	Moneda         string `json:"moneda,omitempty"`
+	TipoCambio     string `json:"tipo_de_cambio,omitempty"`
+	Adjuntos       []Adjunto `json:"-"`
 }
 
 type Documento struct {
 	Documento_ID   string `dynamodbav:"id_documento" json:"id_documento"`
 	Departamento   string `dynamodbav:"departamento" json:"departamento"`
 	Residente      string `dynamodbav:"residente" json:"residente"`
+	ResidenteID    string `dynamodbav:"id_residente,omitempty" json:"id_residente"`
 	FechaDePago    string `dynamodbav:"fecha_de_pago" json:"fecha_de_pago"`
 	TipoDeServicio string `dynamodbav:"tipo_de_servicio" json:"tipo_de_servicio"`
+	NombreServicio string `dynamodbav:"nombre_servicio,omitempty" json:"nombre_servicio"`
 	StateDocument	string `dynamodbav:"estado_documento" json:"estado_documento"`
+	Monto          int64  `dynamodbav:"monto,omitempty" json:"monto"`
+	Moneda         string `dynamodbav:"moneda,omitempty" json:"moneda"`
+	TipoCambio     string `dynamodbav:"tipo_de_cambio,omitempty" json:"tipo_de_cambio"`
+	MontoAplicado  int64  `dynamodbav:"monto_aplicado,omitempty" json:"monto_aplicado"`
+	Cargos         []string `dynamodbav:"cargos,stringset,omitempty" json:"cargos"`
 	UrlPDF         string `dynamodbav:"url_pdf" json:"url_pdf"`
+	FileKey        string `dynamodbav:"file_key,omitempty" json:"file_key"`
+	FileChecksum   string `dynamodbav:"file_checksum,omitempty" json:"file_checksum"`
+	Checksums      []string `dynamodbav:"checksums,stringset,omitempty" json:"-"`
+	DuplicadoDe    string `dynamodbav:"duplicado_de,omitempty" json:"duplicado_de"`
+	ThumbnailKey   string `dynamodbav:"thumbnail_key,omitempty" json:"thumbnail_key"`
+	ThumbnailURL   string `dynamodbav:"thumbnail_url,omitempty" json:"thumbnail_url"`
+	AprobadoPor    string `dynamodbav:"aprobado_por,omitempty" json:"aprobado_por"`
+	FechaAprobacion string `dynamodbav:"fecha_aprobacion,omitempty" json:"fecha_aprobacion"`
+	NumeroRecibo   string `dynamodbav:"numero_recibo,omitempty" json:"numero_recibo"`
+	ReciboKey      string `dynamodbav:"recibo_key,omitempty" json:"recibo_key"`
+	UrlRecibo      string `dynamodbav:"url_recibo,omitempty" json:"url_recibo"`
+	EstadoArchivo  string `dynamodbav:"estado_archivo,omitempty" json:"estado_archivo"`
+	MalwareFirma   string `dynamodbav:"malware_firma,omitempty" json:"malware_firma"`
+	Cuarentena     []string `dynamodbav:"archivos_cuarentena,stringset,omitempty" json:"archivos_cuarentena"`
+	Extraccion     *ExtraccionPDF `dynamodbav:"extraccion,omitempty" json:"extraccion"`
+	Adjuntos       []Adjunto `dynamodbav:"adjuntos,omitempty" json:"adjuntos"`
+}
+
+// LogValue expone los campos como atributos para que el logger redacte los personales.
+func (req DocumentoRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("departamento", req.Departamento),
+		slog.String("residente", req.Residente),
+		slog.String("fecha_de_pago", req.FechaDePago),
+		slog.String("tipo_de_servicio", req.TipoDeServicio),
+		slog.String("estado_documento", req.StateDocument),
+		slog.Int64("monto", req.Monto),
+		slog.String("moneda", req.Moneda),
+	)
+}
+
+// LogValue expone los campos como atributos para que el logger redacte los personales.
+func (doc Documento) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id_documento", doc.Documento_ID),
+		slog.String("departamento", doc.Departamento),
+		slog.String("residente", doc.Residente),
+		slog.String("fecha_de_pago", doc.FechaDePago),
+		slog.String("tipo_de_servicio", doc.TipoDeServicio),
+		slog.String("estado_documento", doc.StateDocument),
+		slog.Int64("monto", doc.Monto),
+		slog.String("moneda", doc.Moneda),
+	)
 }
 
+// EnRevision indica si el documento espera aprobación: está pendiente y su archivo no
+// fue rechazado por malware.
+func (doc Documento) EnRevision() bool {
+	return IsPendiente(doc.StateDocument) && doc.EstadoArchivo != EstadoArchivoMalware
+}
+
+// ToDocumentoResponse arma la respuesta de la API. El bucket no es público: las URLs
+// guardadas solo ubican el objeto y al cliente se le entregan enlaces a
+// /document/{id}/file, que comprueban quién puede verlo.
 func (doc Documento) ToDocumentoResponse() DocumentoResponse {
+	fileKey := doc.FileKey
+	if fileKey == "" {
+		fileKey = KeyFromObjectURL(doc.UrlPDF)
+	}
+	thumbnailKey := doc.ThumbnailKey
+	if thumbnailKey == "" {
+		thumbnailKey = KeyFromObjectURL(doc.ThumbnailURL)
+	}
+	reciboKey := doc.ReciboKey
+	if reciboKey == "" {
+		reciboKey = KeyFromObjectURL(doc.UrlRecibo)
+	}
+
+	var urlPDF, thumbnailURL, urlRecibo string
+	if fileKey != "" || len(doc.Adjuntos) > 0 {
+		urlPDF = FileURL(doc.Documento_ID, nil)
+	}
+	if thumbnailKey != "" {
+		thumbnailURL = FileURL(doc.Documento_ID, url.Values{"archivo": {ArchivoMiniatura}})
+	}
+	if reciboKey != "" {
+		urlRecibo = FileURL(doc.Documento_ID, url.Values{"archivo": {ArchivoRecibo}})
+	}
+
 	return DocumentoResponse{
 		Documento_ID:   doc.Documento_ID,
 		Departamento:   doc.Departamento,
 		Residente:      doc.Residente,
+		ResidenteID:    doc.ResidenteID,
 		FechaDePago:    doc.FechaDePago,
 		TipoDeServicio: doc.TipoDeServicio,
+		NombreServicio: doc.NombreServicio,
 		StateDocument:  doc.StateDocument,
-		UrlPDF:         doc.UrlPDF,
+		Monto:          doc.Monto,
+		Moneda:         doc.Moneda,
+		TipoCambio:     doc.TipoCambio,
+		MontoAplicado:  doc.MontoAplicado,
+		Cargos:         doc.Cargos,
+		UrlPDF:         urlPDF,
+		FileKey:        fileKey,
+		FileChecksum:   doc.FileChecksum,
+		DuplicadoDe:    doc.DuplicadoDe,
+		ThumbnailKey:   thumbnailKey,
+		ThumbnailURL:   thumbnailURL,
+		AprobadoPor:    doc.AprobadoPor,
+		FechaAprobacion: doc.FechaAprobacion,
+		NumeroRecibo:   doc.NumeroRecibo,
+		ReciboKey:      reciboKey,
+		UrlRecibo:      urlRecibo,
+		EstadoArchivo:  doc.EstadoArchivo,
+		MalwareFirma:   doc.MalwareFirma,
+		Cuarentena:     doc.Cuarentena,
+		Extraccion:     doc.Extraccion,
+		Adjuntos:       doc.Adjuntos,
 	}
 }
 
 func (req DocumentoRequest) ToDocumento() Documento {
 	id := uuid.NewString()
-	url := fmt.Sprintf("https://%s.s3.amazonaws.com/%s%s.pdf", BUCKET_NAME, BUCKET_KEY, id)
+
+	adjuntos := make([]Adjunto, len(req.Adjuntos))
+	for i, adjunto := range req.Adjuntos {
+		adjunto.AssignKey(i == 0)
+		adjuntos[i] = adjunto
+	}
+
+	// La URL y el checksum salen del archivo principal; sqs_consumer los confirma al subirlo
+	var url, checksum, estado string
+	if len(adjuntos) > 0 {
+		url = ObjectURL(adjuntos[0].Key)
+		checksum = adjuntos[0].Checksum
+		estado = EstadoArchivoPendiente
+	}
 
 	return Documento{
 		Documento_ID:   id,
 		Departamento:   req.Departamento,
 		Residente:      req.Residente,
+		ResidenteID:    req.ResidenteID,
 		FechaDePago:    req.FechaDePago,
 		TipoDeServicio: req.TipoDeServicio,
+		NombreServicio: req.NombreServicio,
 		StateDocument:  req.StateDocument,
+		AprobadoPor:    req.AprobadoPor,
+		Monto:          req.Monto,
+		Moneda:         req.Moneda,
+		TipoCambio:     req.TipoCambio,
 		UrlPDF:         url,
+		FileChecksum:   checksum,
+		EstadoArchivo:  estado,
+		Checksums:      Checksums(adjuntos),
+		Adjuntos:       adjuntos,
+	}
+}
+
+// Checksums devuelve los checksums distintos de una lista de adjuntos.
+func Checksums(adjuntos []Adjunto) []string {
+	seen := map[string]bool{}
+	var checksums []string
+	for _, adjunto := range adjuntos {
+		if adjunto.Checksum != "" && !seen[adjunto.Checksum] {
+			seen[adjunto.Checksum] = true
+			checksums = append(checksums, adjunto.Checksum)
+		}
 	}
+	return checksums
 }
 
 type DocumentoResponse struct {
 	Documento_ID   string `json:"id_documento"`
 	Departamento   string `json:"departamento"`
 	Residente      string `json:"residente"`
+	ResidenteID    string `json:"id_residente,omitempty"`
 	FechaDePago    string `json:"fecha_de_pago"`
 	TipoDeServicio string `json:"tipo_de_servicio"`
+	NombreServicio string `json:"nombre_servicio,omitempty"`
+	Monto          int64  `json:"monto"`
+	Moneda         string `json:"moneda,omitempty"`
+	TipoCambio     string `json:"tipo_de_cambio,omitempty"`
+	MontoAplicado  int64  `json:"monto_aplicado,omitempty"`
+	Cargos         []string `json:"cargos,omitempty"`
 	UrlPDF         string `json:"url_pdf"`
+	FileKey        string `json:"file_key,omitempty"`
+	FileChecksum   string `json:"file_checksum,omitempty"`
+	DuplicadoDe    string `json:"duplicado_de,omitempty"`
+	ThumbnailKey   string `json:"thumbnail_key,omitempty"`
+	ThumbnailURL   string `json:"thumbnail_url,omitempty"`
+	AprobadoPor    string `json:"aprobado_por,omitempty"`
+	FechaAprobacion string `json:"fecha_aprobacion,omitempty"`
+	NumeroRecibo   string `json:"numero_recibo,omitempty"`
+	ReciboKey      string `json:"recibo_key,omitempty"`
+	UrlRecibo      string `json:"url_recibo,omitempty"`
+	EstadoArchivo  string `json:"estado_archivo,omitempty"`
+	MalwareFirma   string `json:"malware_firma,omitempty"`
+	Cuarentena     []string `json:"archivos_cuarentena,omitempty"`
+	Extraccion     *ExtraccionPDF `json:"extraccion,omitempty"`
 	StateDocument	string `json:"estado_documento"`
+	Adjuntos       []Adjunto `json:"adjuntos,omitempty"`
 	Message        string `json:"message"`
 }
+
+// ObjectKeys devuelve las keys de todos los archivos del documento en el bucket.
+func (res DocumentoResponse) ObjectKeys() []string {
+	seen := map[string]bool{}
+	var keys []string
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	add(res.FileKey)
+	add(res.ThumbnailKey)
+	add(res.ReciboKey)
+	for _, adjunto := range res.Adjuntos {
+		add(adjunto.Key)
+	}
+	return keys
+}
@@ -0,0 +1,14 @@
+package domain
+
+// ExtraccionPDF guarda lo leído del PDF principal del documento. Los valores sugeridos
+// solo prellenan el formulario de revisión; nunca reemplazan los datos del documento.
+type ExtraccionPDF struct {
+	Paginas         int    `dynamodbav:"paginas" json:"paginas"`
+	Productor       string `dynamodbav:"productor,omitempty" json:"productor,omitempty"`
+	Creador         string `dynamodbav:"creador,omitempty" json:"creador,omitempty"`
+	Texto           string `dynamodbav:"texto,omitempty" json:"texto,omitempty"`
+	TextoTruncado   bool   `dynamodbav:"texto_truncado,omitempty" json:"texto_truncado,omitempty"`
+	NumeroOperacion string `dynamodbav:"numero_operacion,omitempty" json:"numero_operacion,omitempty"`
+	Monto           string `dynamodbav:"monto,omitempty" json:"monto,omitempty"`
+	Fecha           string `dynamodbav:"fecha,omitempty" json:"fecha,omitempty"`
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Monedas aceptadas. Los montos se guardan en unidades mínimas (céntimos) como
+// enteros, nunca como float, para que las sumas no acumulen errores de redondeo.
+const (
+	MonedaPEN = "PEN"
+	MonedaUSD = "USD"
+)
+
+// Límite de un pago individual: 100 millones expresados en céntimos.
+const maxMonto = 10_000_000_000
+
+var (
+	ErrMontoInvalido      = errors.New("monto debe ser un entero no negativo en céntimos")
+	ErrMonedaInvalida     = errors.New("moneda debe ser PEN o USD")
+	ErrTipoCambioInvalido = errors.New("tipo_de_cambio debe ser un decimal positivo con hasta 6 decimales y solo aplica a USD")
+)
+
+var monedas = map[string]string{MonedaPEN: "S/", MonedaUSD: "US$"}
+
+var tipoCambioPattern = regexp.MustCompile(`^\d{1,4}(\.\d{1,6})?$`)
+
+// Validate comprueba el monto, la moneda y el tipo de cambio del pago. El monto es
+// opcional para no romper a los clientes anteriores; si se envía sin moneda es PEN.
+func (req *DocumentoRequest) Validate() error {
+	req.Moneda = strings.ToUpper(strings.TrimSpace(req.Moneda))
+	req.TipoCambio = strings.TrimSpace(req.TipoCambio)
+
+	if req.Monto < 0 || req.Monto > maxMonto {
+		return ErrMontoInvalido
+	}
+	if req.Moneda == "" && req.Monto > 0 {
+		req.Moneda = MonedaPEN
+	}
+	if _, ok := monedas[req.Moneda]; req.Moneda != "" && !ok {
+		return ErrMonedaInvalida
+	}
+	if req.TipoCambio != "" {
+		rate, ok := parseTipoCambio(req.TipoCambio)
+		if !ok || rate.Sign() <= 0 || req.Moneda != MonedaUSD {
+			return ErrTipoCambioInvalido
+		}
+	}
+	return nil
+}
+
+// ParseMonto lee un monto en unidades mínimas, p. ej. el campo "monto" de un formulario.
+func ParseMonto(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	monto, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || monto < 0 || monto > maxMonto {
+		return 0, ErrMontoInvalido
+	}
+	return monto, nil
+}
+
+// ParseMontoDecimal lee un monto decimal con punto y dos decimales ("1250.50", como lo
+// deja extraction.NormalizeMonto) y lo devuelve en unidades mínimas.
+func ParseMontoDecimal(value string) (int64, error) {
+	integer, decimals, ok := strings.Cut(strings.TrimSpace(value), ".")
+	if !ok || len(decimals) != 2 {
+		return 0, ErrMontoInvalido
+	}
+	return ParseMonto(integer + decimals)
+}
+
+// FormatMonto muestra un monto en unidades mínimas con su símbolo: "S/ 1,250.50".
+func FormatMonto(monto int64, moneda string) string {
+	sign := ""
+	if monto < 0 {
+		sign, monto = "-", -monto
+	}
+
+	integer := strconv.FormatInt(monto/100, 10)
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	symbol, ok := monedas[moneda]
+	if !ok {
+		symbol = moneda
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s%s.%02d", symbol, sign, grouped.String(), monto%100))
+}
+
+// ConvertirAPEN convierte un monto en USD a soles con el tipo de cambio del pago,
+// redondeando al céntimo más cercano (las mitades hacia arriba).
+func ConvertirAPEN(monto int64, tipoCambio string) (int64, error) {
+	rate, ok := parseTipoCambio(tipoCambio)
+	if !ok || rate.Sign() <= 0 || monto < 0 {
+		return 0, ErrTipoCambioInvalido
+	}
+
+	return redondear(new(big.Rat).Mul(new(big.Rat).SetInt64(monto), rate))
+}
+
+// redondear lleva un monto no negativo al céntimo más cercano, las mitades hacia arriba.
+func redondear(value *big.Rat) (int64, error) {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if remainder.Lsh(remainder, 1).Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if !quotient.IsInt64() {
+		return 0, ErrMontoInvalido
+	}
+	return quotient.Int64(), nil
+}
+
+func parseTipoCambio(value string) (*big.Rat, bool) {
+	if !tipoCambioPattern.MatchString(value) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(value)
+}
+
+// Totales resume los montos de una lista de documentos, por moneda y en soles.
+type Totales struct {
+	Documentos int              `json:"documentos"`
+	PorMoneda  map[string]int64 `json:"por_moneda"`
+	// TotalPEN suma los soles y los dólares con tipo de cambio; los pagos en dólares
+	// sin tipo de cambio se cuentan en SinTipoCambio y no entran en el total.
+	TotalPEN      int64 `json:"total_pen"`
+	SinTipoCambio int   `json:"sin_tipo_de_cambio"`
+}
+
+// Totalizar suma los montos en unidades mínimas, sin pasar por float.
+func Totalizar(documentos []DocumentoResponse) Totales {
+	totales := Totales{PorMoneda: map[string]int64{}}
+	for _, documento := range documentos {
+		totales.Documentos++
+		if documento.Monto == 0 || documento.Moneda == "" {
+			continue
+		}
+		totales.PorMoneda[documento.Moneda] += documento.Monto
+
+		switch documento.Moneda {
+		case MonedaPEN:
+			totales.TotalPEN += documento.Monto
+		case MonedaUSD:
+			soles, err := ConvertirAPEN(documento.Monto, documento.TipoCambio)
+			if err != nil {
+				totales.SinTipoCambio++
+				continue
+			}
+			totales.TotalPEN += soles
+		}
+	}
+	return totales
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMontoDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		monto   int64
+		wantErr bool
+	}{
+		{value: "1250.50", monto: 125050},
+		{value: "0.05", monto: 5},
+		{value: " 10.00 ", monto: 1000},
+		{value: "100000000.00", monto: maxMonto},
+		{value: "100000000.01", wantErr: true},
+		{value: "10", wantErr: true},
+		{value: "10.5", wantErr: true},
+		{value: "10.505", wantErr: true},
+		{value: "1,250.50", wantErr: true},
+		{value: "-1.00", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		monto, err := ParseMontoDecimal(tt.value)
+		if tt.wantErr {
+			if !errors.Is(err, ErrMontoInvalido) {
+				t.Errorf("ParseMontoDecimal(%q) = %d, %v; se esperaba ErrMontoInvalido", tt.value, monto, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMontoDecimal(%q): %v", tt.value, err)
+			continue
+		}
+		if monto != tt.monto {
+			t.Errorf("ParseMontoDecimal(%q) = %d, se esperaba %d", tt.value, monto, tt.monto)
+		}
+	}
+}
+
+func TestConvertirAPEN(t *testing.T) {
+	tests := []struct {
+		name       string
+		monto      int64
+		tipoCambio string
+		pen        int64
+		wantErr    bool
+	}{
+		{name: "exacto", monto: 10000, tipoCambio: "3.75", pen: 37500},
+		{name: "redondea hacia abajo", monto: 333, tipoCambio: "3.749", pen: 1248},
+		{name: "redondea hacia arriba", monto: 1, tipoCambio: "3.755", pen: 4},
+		{name: "mitad hacia arriba", monto: 2, tipoCambio: "3.75", pen: 8},
+		{name: "mitad de céntimo", monto: 1, tipoCambio: "0.5", pen: 1},
+		{name: "menos de media", monto: 1, tipoCambio: "0.499999", pen: 0},
+		{name: "seis decimales", monto: 100, tipoCambio: "3.123456", pen: 312},
+		{name: "monto cero", monto: 0, tipoCambio: "3.75", pen: 0},
+		{name: "tipo de cambio cero", monto: 100, tipoCambio: "0", wantErr: true},
+		{name: "siete decimales", monto: 100, tipoCambio: "3.1234567", wantErr: true},
+		{name: "negativo", monto: 100, tipoCambio: "-3.75", wantErr: true},
+		{name: "con coma", monto: 100, tipoCambio: "3,75", wantErr: true},
+		{name: "monto negativo", monto: -100, tipoCambio: "3.75", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pen, err := ConvertirAPEN(tt.monto, tt.tipoCambio)
+			if tt.wantErr {
+				if !errors.Is(err, ErrTipoCambioInvalido) {
+					t.Errorf("ConvertirAPEN(%d, %q) = %d, %v; se esperaba ErrTipoCambioInvalido", tt.monto, tt.tipoCambio, pen, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertirAPEN(%d, %q): %v", tt.monto, tt.tipoCambio, err)
+			}
+			if pen != tt.pen {
+				t.Errorf("ConvertirAPEN(%d, %q) = %d, se esperaba %d", tt.monto, tt.tipoCambio, pen, tt.pen)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Tramos de antigüedad de la deuda, en días de atraso desde el vencimiento.
+const (
+	Tramo0a30  = "0-30"
+	Tramo31a60 = "31-60"
+	Tramo61a90 = "61-90"
+	TramoMas90 = "90+"
+)
+
+var ErrFechaCorte = errors.New("fecha_corte debe tener formato AAAA-MM-DD")
+
+// TramoAtraso clasifica los días de atraso en su tramo.
+func TramoAtraso(dias int) string {
+	switch {
+	case dias <= 30:
+		return Tramo0a30
+	case dias <= 60:
+		return Tramo31a60
+	case dias <= 90:
+		return Tramo61a90
+	default:
+		return TramoMas90
+	}
+}
+
+// MorosidadDepartamento es la deuda vencida de un departamento en una moneda. Los
+// montos están en céntimos.
+type MorosidadDepartamento struct {
+	Departamento   string `json:"departamento"`
+	Moneda         string `json:"moneda"`
+	CargosVencidos int    `json:"cargos_vencidos"`
+	Saldo          int64  `json:"saldo"`
+	DiasAtraso     int    `json:"dias_atraso"`
+	PeriodoAntiguo string `json:"periodo_mas_antiguo"`
+	Tramo0a30      int64  `json:"tramo_0_30"`
+	Tramo31a60     int64  `json:"tramo_31_60"`
+	Tramo61a90     int64  `json:"tramo_61_90"`
+	TramoMas90     int64  `json:"tramo_90_mas"`
+	// PagosSinAplicar suma lo disponible de los documentos aprobados que aún no se
+	// aplicaron a cargos; DocumentosEnRevision cuenta los pagos que esperan aprobación.
+	PagosSinAplicar      int64 `json:"pagos_sin_aplicar"`
+	DocumentosEnRevision int   `json:"documentos_en_revision"`
+}
+
+// Morosidad es el reporte de deuda vencida a una fecha de corte.
+type Morosidad struct {
+	FechaCorte    string                  `json:"fecha_corte"`
+	Departamentos []MorosidadDepartamento `json:"departamentos"`
+	Totales       map[string]int64        `json:"totales"`
+	Message       string                  `json:"message"`
+}
+
+// CalcularMorosidad arma el reporte con los cargos con saldo cuyo vencimiento ya pasó a
+// la fecha de corte. Los cargos que aún no vencen no cuentan como deuda.
+func CalcularMorosidad(corte time.Time, cargos []Cargo, documentos []Documento) Morosidad {
+	dia := corte.Format("2006-01-02")
+	lineas := map[string]*MorosidadDepartamento{}
+	linea := func(departamento, moneda string) *MorosidadDepartamento {
+		key := departamento + "|" + moneda
+		if lineas[key] == nil {
+			lineas[key] = &MorosidadDepartamento{Departamento: departamento, Moneda: moneda}
+		}
+		return lineas[key]
+	}
+
+	for _, cargo := range cargos {
+		if cargo.Saldo() <= 0 || cargo.FechaVencimiento == "" || cargo.FechaVencimiento >= dia {
+			continue
+		}
+		vence, err := time.Parse("2006-01-02", cargo.FechaVencimiento)
+		if err != nil {
+			continue
+		}
+		dias := int(corte.Sub(vence).Hours() / 24)
+
+		l := linea(cargo.Departamento, cargo.Moneda)
+		l.CargosVencidos++
+		l.Saldo += cargo.Saldo()
+		if dias > l.DiasAtraso {
+			l.DiasAtraso = dias
+		}
+		if l.PeriodoAntiguo == "" || cargo.Periodo < l.PeriodoAntiguo {
+			l.PeriodoAntiguo = cargo.Periodo
+		}
+		switch TramoAtraso(dias) {
+		case Tramo0a30:
+			l.Tramo0a30 += cargo.Saldo()
+		case Tramo31a60:
+			l.Tramo31a60 += cargo.Saldo()
+		case Tramo61a90:
+			l.Tramo61a90 += cargo.Saldo()
+		default:
+			l.TramoMas90 += cargo.Saldo()
+		}
+	}
+
+	// Los documentos solo se suman a los departamentos que ya figuran como morosos
+	for _, documento := range documentos {
+		key := NormalizeDepartamentoID(documento.Departamento) + "|" + documento.Moneda
+		l := lineas[key]
+		if l == nil || documento.Monto <= 0 {
+			continue
+		}
+		if IsAprobado(documento.StateDocument) {
+			if disponible := documento.Disponible(); disponible > 0 {
+				l.PagosSinAplicar += disponible
+			}
+		} else if documento.EnRevision() {
+			l.DocumentosEnRevision++
+		}
+	}
+
+	reporte := Morosidad{FechaCorte: dia, Departamentos: []MorosidadDepartamento{}, Totales: map[string]int64{}}
+	for _, l := range lineas {
+		reporte.Departamentos = append(reporte.Departamentos, *l)
+		reporte.Totales[l.Moneda] += l.Saldo
+	}
+	// Primero los más atrasados; a igual atraso, por departamento
+	sort.Slice(reporte.Departamentos, func(i, j int) bool {
+		a, b := reporte.Departamentos[i], reporte.Departamentos[j]
+		if a.DiasAtraso != b.DiasAtraso {
+			return a.DiasAtraso > b.DiasAtraso
+		}
+		if a.Departamento != b.Departamento {
+			return a.Departamento < b.Departamento
+		}
+		return a.Moneda < b.Moneda
+	})
+	return reporte
+}
+
+// WriteCSV escribe el reporte con una fila por departamento y moneda. Los montos van en
+// decimal ("1250.50") para que las hojas de cálculo los lean como números.
+func (reporte Morosidad) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"fecha_corte", "departamento", "moneda", "cargos_vencidos", "saldo", "dias_atraso", "periodo_mas_antiguo",
+		"tramo_0_30", "tramo_31_60", "tramo_61_90", "tramo_90_mas", "pagos_sin_aplicar", "documentos_en_revision",
+	})
+	for _, l := range reporte.Departamentos {
+		writer.Write([]string{
+			reporte.FechaCorte, EscapeFormula(l.Departamento), EscapeFormula(l.Moneda), strconv.Itoa(l.CargosVencidos), MontoDecimal(l.Saldo),
+			strconv.Itoa(l.DiasAtraso), l.PeriodoAntiguo, MontoDecimal(l.Tramo0a30), MontoDecimal(l.Tramo31a60),
+			MontoDecimal(l.Tramo61a90), MontoDecimal(l.TramoMas90), MontoDecimal(l.PagosSinAplicar),
+			strconv.Itoa(l.DocumentosEnRevision),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// MontoDecimal muestra un monto en unidades mínimas como decimal sin símbolo: "1250.50".
+func MontoDecimal(monto int64) string {
+	sign := ""
+	if monto < 0 {
+		sign, monto = "-", -monto
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, monto/100, monto%100)
+}
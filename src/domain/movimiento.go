@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Estados de un movimiento del extracto bancario.
+const (
+	EstadoMovimientoSinCoincidencia = "sin_coincidencia"
+	EstadoMovimientoPropuesto       = "propuesto"
+	EstadoMovimientoConciliado      = "conciliado"
+	EstadoMovimientoDescartado      = "descartado"
+)
+
+// Acciones para resolver una propuesta de conciliación.
+const (
+	AccionConfirmar = "confirmar"
+	AccionDescartar = "descartar"
+)
+
+var (
+	// BANK_MATCH_WINDOW_DAYS es la diferencia máxima en días entre la fecha del
+	// movimiento y la fecha de pago del documento (3 por defecto).
+	BANK_MATCH_WINDOW_DAYS = os.Getenv("BANK_MATCH_WINDOW_DAYS")
+
+	// BANK_AUTO_APPROVE en "false" deja también las coincidencias exactas como propuestas.
+	BANK_AUTO_APPROVE = os.Getenv("BANK_AUTO_APPROVE")
+)
+
+// AprobadorConciliacion figura como aprobador de los documentos aprobados automáticamente.
+const AprobadorConciliacion = "conciliacion bancaria"
+
+var (
+	ErrMovimientoNotFound        = errors.New("movimiento no encontrado")
+	ErrMovimientoResuelto        = errors.New("el movimiento ya fue conciliado o descartado")
+	ErrAccionInvalida            = errors.New("accion debe ser confirmar o descartar")
+	ErrMovimientoDocumento       = errors.New("el documento no coincide con el monto y la moneda del movimiento")
+	ErrExtractoVacio             = errors.New("el extracto no tiene abonos")
+	ErrDocumentoAprobado         = errors.New("el documento ya está aprobado")
+	ErrAprobacionAutomatica      = errors.New("el documento cambió o su archivo no está analizado, queda para revisión")
+	ErrMovimientoSinDocumento    = errors.New("id_documento es obligatorio para confirmar un movimiento sin propuesta")
+	ErrConciliacionNoConfigurada = errors.New("MOVIMIENTO_TABLE_NAME es necesario para conciliar extractos")
+)
+
+// MovimientoBancario es un abono del extracto. Su ID sale de sus datos, así que
+// importar otra vez el mismo extracto no duplica movimientos.
+type MovimientoBancario struct {
+	Movimiento_ID    string `dynamodbav:"id_movimiento" json:"id_movimiento"`
+	Fecha            string `dynamodbav:"fecha" json:"fecha"`
+	Monto            int64  `dynamodbav:"monto" json:"monto"`
+	Moneda           string `dynamodbav:"moneda" json:"moneda"`
+	Descripcion      string `dynamodbav:"descripcion,omitempty" json:"descripcion"`
+	NumeroOperacion  string `dynamodbav:"numero_operacion,omitempty" json:"numero_operacion"`
+	Referencia       string `dynamodbav:"referencia,omitempty" json:"referencia"`
+	Estado           string `dynamodbav:"estado" json:"estado"`
+	Documento_ID     string `dynamodbav:"id_documento,omitempty" json:"id_documento"`
+	Motivo           string `dynamodbav:"motivo,omitempty" json:"motivo"`
+	FechaImportacion string `dynamodbav:"fecha_importacion" json:"fecha_importacion"`
+}
+
+// AssignID calcula el ID del movimiento a partir de sus datos. Ocurrencia distingue los
+// abonos idénticos de un mismo extracto, como dos pagos iguales el mismo día: cuenta
+// desde 0 y la primera conserva el ID que se calculaba sin ella.
+func (mov *MovimientoBancario) AssignID(ocurrencia int) {
+	fields := []string{
+		mov.Fecha, fmt.Sprint(mov.Monto), mov.Moneda, mov.NumeroOperacion, mov.Referencia, mov.Descripcion,
+	}
+	if ocurrencia > 0 {
+		fields = append(fields, strconv.Itoa(ocurrencia))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "|")))
+	mov.Movimiento_ID = hex.EncodeToString(sum[:16])
+}
+
+// VentanaConciliacion devuelve los días de tolerancia entre movimiento y documento.
+func VentanaConciliacion() int {
+	dias, err := strconv.Atoi(BANK_MATCH_WINDOW_DAYS)
+	if err != nil || dias < 0 {
+		return 3
+	}
+	return dias
+}
+
+// AutoAprobar indica si las coincidencias exactas aprueban el documento sin revisión.
+func AutoAprobar() bool {
+	return !strings.EqualFold(strings.TrimSpace(BANK_AUTO_APPROVE), "false")
+}
+
+// Resuelto indica si el movimiento ya no admite confirmación ni descarte.
+func (mov MovimientoBancario) Resuelto() bool {
+	return mov.Estado == EstadoMovimientoConciliado || mov.Estado == EstadoMovimientoDescartado
+}
+
+// Importacion resume la carga de un extracto.
+type Importacion struct {
+	Movimientos     int                  `json:"movimientos"`
+	Nuevos          int                  `json:"nuevos"`
+	Duplicados      int                  `json:"duplicados"`
+	Conciliados     int                  `json:"conciliados"`
+	Propuestos      int                  `json:"propuestos"`
+	SinCoincidencia int                  `json:"sin_coincidencia"`
+	Detalle         []MovimientoBancario `json:"detalle"`
+	Message         string               `json:"message"`
+}
+
+// ResolverMovimientoRequest confirma o descarta la propuesta de un movimiento. Al
+// confirmar, id_documento permite elegir otro documento que el propuesto.
+type ResolverMovimientoRequest struct {
+	Accion       string `json:"accion"`
+	Documento_ID string `json:"id_documento"`
+}
+
+// Coincidencia es el documento que el emparejador asocia a un movimiento. Es exacta
+// cuando además del monto, la moneda y la fecha coincide el número de operación.
+type Coincidencia struct {
+	Documento_ID string
+	Exacta       bool
+	Motivo       string
+
+	// Documento es el documento tal como se leyó al emparejarlo.
+	Documento Documento
+}
+
+// Emparejar busca entre los documentos pendientes el que corresponde al movimiento:
+// mismo monto y moneda, fecha de pago a no más de ventana días y, si ambos lo tienen,
+// el mismo número de operación. Usados excluye los documentos ya emparejados.
+func Emparejar(mov MovimientoBancario, pendientes []Documento, ventana int, usados map[string]bool) (Coincidencia, bool) {
+	fecha, err := time.Parse("2006-01-02", mov.Fecha)
+	if err != nil {
+		return Coincidencia{}, false
+	}
+	operacion := normalizeOperacion(mov.NumeroOperacion)
+
+	type candidato struct {
+		documento Documento
+		dias      int
+		operacion bool
+	}
+	var candidatos []candidato
+	for _, documento := range pendientes {
+		if usados[documento.Documento_ID] || documento.Monto != mov.Monto || documento.Moneda != mov.Moneda {
+			continue
+		}
+		pago, err := time.Parse("2006-01-02", documento.FechaDePago)
+		if err != nil {
+			continue
+		}
+		dias := int(fecha.Sub(pago).Hours() / 24)
+		if dias < 0 {
+			dias = -dias
+		}
+		if dias > ventana {
+			continue
+		}
+
+		var documentoOperacion string
+		if documento.Extraccion != nil {
+			documentoOperacion = normalizeOperacion(documento.Extraccion.NumeroOperacion)
+		}
+		// Dos números de operación distintos descartan al candidato
+		if operacion != "" && documentoOperacion != "" && operacion != documentoOperacion {
+			continue
+		}
+		// Sin número de operación en el extracto se busca en la descripción; los números
+		// cortos podrían aparecer por casualidad entre otros dígitos
+		coincide := documentoOperacion != "" && (documentoOperacion == operacion ||
+			(operacion == "" && len(documentoOperacion) >= 6 && strings.Contains(mov.Descripcion, documentoOperacion)))
+		candidatos = append(candidatos, candidato{documento: documento, dias: dias, operacion: coincide})
+	}
+	if len(candidatos) == 0 {
+		return Coincidencia{}, false
+	}
+
+	// Primero los que coinciden en número de operación, luego los de fecha más cercana
+	sort.SliceStable(candidatos, func(i, j int) bool {
+		if candidatos[i].operacion != candidatos[j].operacion {
+			return candidatos[i].operacion
+		}
+		return candidatos[i].dias < candidatos[j].dias
+	})
+
+	mejor := candidatos[0]
+	coincidencia := Coincidencia{Documento_ID: mejor.documento.Documento_ID, Documento: mejor.documento}
+	switch {
+	case mejor.operacion && (len(candidatos) == 1 || !candidatos[1].operacion):
+		coincidencia.Exacta = true
+		coincidencia.Motivo = "monto, moneda, fecha y número de operación"
+	case len(candidatos) == 1:
+		coincidencia.Motivo = fmt.Sprintf("monto y moneda, fecha a %d días", mejor.dias)
+	default:
+		coincidencia.Motivo = fmt.Sprintf("monto y moneda, fecha a %d días; %d documentos posibles", mejor.dias, len(candidatos))
+	}
+	return coincidencia, true
+}
+
+// normalizeOperacion deja solo los dígitos sin ceros a la izquierda: los bancos
+// rellenan el número de operación con ceros y lo separan con guiones o espacios.
+func normalizeOperacion(value string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, value)
+	return strings.TrimLeft(digits, "0")
+}
@@ -0,0 +1,110 @@
+package domain
+
+import "testing"
+
+func TestEmparejar(t *testing.T) {
+	documento := func(id string, monto int64, fecha, operacion string) Documento {
+		d := Documento{Documento_ID: id, Monto: monto, Moneda: MonedaPEN, FechaDePago: fecha}
+		if operacion != "" {
+			d.Extraccion = &ExtraccionPDF{NumeroOperacion: operacion}
+		}
+		return d
+	}
+
+	tests := []struct {
+		name       string
+		mov        MovimientoBancario
+		pendientes []Documento
+		usados     map[string]bool
+		id         string
+		exacta     bool
+		found      bool
+	}{
+		{
+			name:       "número de operación coincide",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN, NumeroOperacion: "000123456"},
+			pendientes: []Documento{documento("a", 15000, "2024-03-02", "123-456")},
+			id:         "a", exacta: true, found: true,
+		},
+		{
+			name:       "número de operación en la descripción",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN, Descripcion: "ABONO OP 987654 YAPE"},
+			pendientes: []Documento{documento("a", 15000, "2024-03-01", "987654")},
+			id:         "a", exacta: true, found: true,
+		},
+		{
+			name:       "número corto en la descripción solo propone",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN, Descripcion: "ABONO 12345"},
+			pendientes: []Documento{documento("a", 15000, "2024-03-01", "123")},
+			id:         "a", exacta: false, found: true,
+		},
+		{
+			name:       "sin número de operación solo propone",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN},
+			pendientes: []Documento{documento("a", 15000, "2024-03-03", "")},
+			id:         "a", exacta: false, found: true,
+		},
+		{
+			name: "dos documentos con la misma operación solo proponen",
+			mov:  MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN, NumeroOperacion: "555"},
+			pendientes: []Documento{
+				documento("a", 15000, "2024-03-03", "555"),
+				documento("b", 15000, "2024-03-01", "555"),
+			},
+			id: "b", exacta: false, found: true,
+		},
+		{
+			name: "prefiere la fecha más cercana",
+			mov:  MovimientoBancario{Fecha: "2024-03-05", Monto: 15000, Moneda: MonedaPEN},
+			pendientes: []Documento{
+				documento("a", 15000, "2024-03-02", ""),
+				documento("b", 15000, "2024-03-06", ""),
+			},
+			id: "b", exacta: false, found: true,
+		},
+		{
+			name:       "operación distinta descarta",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN, NumeroOperacion: "111"},
+			pendientes: []Documento{documento("a", 15000, "2024-03-01", "222")},
+		},
+		{
+			name:       "fuera de la ventana",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN},
+			pendientes: []Documento{documento("a", 15000, "2024-03-05", "")},
+		},
+		{
+			name:       "otro monto",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN},
+			pendientes: []Documento{documento("a", 15001, "2024-03-01", "")},
+		},
+		{
+			name:       "otra moneda",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaUSD},
+			pendientes: []Documento{documento("a", 15000, "2024-03-01", "")},
+		},
+		{
+			name:       "documento ya usado",
+			mov:        MovimientoBancario{Fecha: "2024-03-01", Monto: 15000, Moneda: MonedaPEN},
+			pendientes: []Documento{documento("a", 15000, "2024-03-01", "")},
+			usados:     map[string]bool{"a": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coincidencia, found := Emparejar(tt.mov, tt.pendientes, 3, tt.usados)
+			if found != tt.found {
+				t.Fatalf("Emparejar encontró = %v, se esperaba %v (%+v)", found, tt.found, coincidencia)
+			}
+			if !found {
+				return
+			}
+			if coincidencia.Documento_ID != tt.id || coincidencia.Exacta != tt.exacta {
+				t.Errorf("Emparejar = %s exacta=%v, se esperaba %s exacta=%v", coincidencia.Documento_ID, coincidencia.Exacta, tt.id, tt.exacta)
+			}
+			if coincidencia.Documento.Documento_ID != coincidencia.Documento_ID {
+				t.Errorf("Documento = %s, se esperaba el documento emparejado %s", coincidencia.Documento.Documento_ID, coincidencia.Documento_ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Extensiones canónicas de los tipos de archivo aceptados; la extensión de una key
+// siempre sale del contenido real, nunca del nombre enviado por el cliente.
+var fileExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/heic":      ".heic",
+}
+
+// FileExtension devuelve la extensión canónica de un tipo de contenido.
+func FileExtension(contentType string) string {
+	return fileExtensions[contentType]
+}
+
+const (
+	blobsFolder      = "blobs/"
+	thumbnailsFolder = "thumbnails/"
+	derivedFolder    = "derived/"
+
+	// Los archivos infectados quedan fuera de BUCKET_KEY para que ninguna lambda de
+	// lectura los sirva; la política del bucket niega su lectura pública.
+	quarantineFolder = "quarantine/"
+
+	// Los archivos recibidos por la API esperan aquí a que sqs_consumer los analice; por
+	// la cola solo viaja su key, porque un mensaje SQS no admite más de 256 KiB.
+	incomingFolder = "incoming/"
+
+	// Las exportaciones que no caben en una respuesta de Lambda se descargan de aquí con
+	// una URL prefirmada; la política del bucket niega su lectura pública.
+	exportsFolder = "exports/"
+)
+
+// ChecksumSHA256 devuelve el SHA-256 en hexadecimal del contenido de un archivo.
+func ChecksumSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// BlobObjectKey es la key direccionada por contenido de un archivo: dos subidas
+// idénticas comparten el mismo objeto en el bucket.
+func BlobObjectKey(checksum, extension string) string {
+	return fmt.Sprintf("%s%s%s%s", BUCKET_KEY, blobsFolder, checksum, extension)
+}
+
+// IsBlobKey indica si la key es un objeto direccionado por contenido.
+func IsBlobKey(key string) bool {
+	return strings.HasPrefix(key, BUCKET_KEY+blobsFolder)
+}
+
+// ThumbnailObjectKey es la key de la miniatura generada para el archivo con ese checksum.
+func ThumbnailObjectKey(checksum string) string {
+	return fmt.Sprintf("%s%s%s.jpg", BUCKET_KEY, thumbnailsFolder, checksum)
+}
+
+// DerivedObjectKey es la key de una variante transformada de otro objeto. El origen se
+// identifica por su checksum si es direccionado por contenido o, si no, por key y ETag,
+// para que una nueva versión del objeto no reutilice variantes viejas.
+func DerivedObjectKey(source, etag, variant string) string {
+	origin := ChecksumFromKey(source)
+	if origin == "" {
+		origin = ChecksumSHA256([]byte(source + "\x00" + etag))
+	}
+	return fmt.Sprintf("%s%s%s/%s", BUCKET_KEY, derivedFolder, origin, variant)
+}
+
+// QuarantineObjectKey es la key bajo la que se aísla un archivo infectado del documento.
+func QuarantineObjectKey(documentoID, checksum, extension string) string {
+	return fmt.Sprintf("%s%s/%s%s", quarantineFolder, documentoID, checksum, extension)
+}
+
+// IncomingObjectKey es la key temporal de un archivo recibido que aún no fue analizado.
+// Cada subida usa una key propia: así, al deshacer una subida se borran sus archivos sin
+// afectar a otra con el mismo contenido que todavía espera en la cola.
+func IncomingObjectKey(extension string) string {
+	return fmt.Sprintf("%s%s%s", incomingFolder, uuid.NewString(), extension)
+}
+
+// IsIncomingKey indica si la key es de un archivo pendiente de análisis.
+func IsIncomingKey(key string) bool {
+	return strings.HasPrefix(key, incomingFolder) && !strings.Contains(key, "..")
+}
+
+// ExportObjectKey es la key temporal de una exportación de documentos.
+func ExportObjectKey(name string) string {
+	return fmt.Sprintf("%s%s", exportsFolder, name)
+}
+
+// ChecksumFromKey extrae el checksum de una key direccionada por contenido, sea el
+// archivo original o su miniatura.
+func ChecksumFromKey(key string) string {
+	var name string
+	switch {
+	case IsBlobKey(key):
+		name = strings.TrimPrefix(key, BUCKET_KEY+blobsFolder)
+	case strings.HasPrefix(key, BUCKET_KEY+thumbnailsFolder):
+		name = strings.TrimPrefix(key, BUCKET_KEY+thumbnailsFolder)
+	default:
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// DocumentoObjectKey es la key histórica "<prefijo><id><ext>" del archivo principal,
+// usada por los documentos anteriores al almacenamiento direccionado por contenido.
+func DocumentoObjectKey(documentoID, extension string) string {
+	return fmt.Sprintf("%s%s%s", BUCKET_KEY, documentoID, extension)
+}
+
+// IsDocumentoKey indica si la key pertenece al prefijo de documentos del bucket.
+func IsDocumentoKey(key string) bool {
+	return strings.HasPrefix(key, BUCKET_KEY) && !strings.Contains(key, "..")
+}
+
+// ObjectURL devuelve la URL S3 de un objeto del bucket. El bucket no admite lectura
+// pública: la URL solo se guarda para ubicar el objeto, nunca se entrega al cliente.
+func ObjectURL(key string) string {
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", BUCKET_NAME, key)
+}
+
+// KeyFromObjectURL recupera la key de una URL generada por ObjectURL; devuelve "" si la
+// URL es de otro bucket.
+func KeyFromObjectURL(url string) string {
+	key, found := strings.CutPrefix(url, ObjectURL(""))
+	if !found {
+		return ""
+	}
+	return key
+}
+
+// FileURL es el enlace de la API que sirve un archivo del documento tras comprobar que
+// el usuario puede verlo; params elige el adjunto o el archivo derivado.
+func FileURL(documentoID string, params url.Values) string {
+	link := fmt.Sprintf("%s/document/%s/file", strings.TrimSuffix(API_BASE_URL, "/"), url.PathEscape(documentoID))
+	if len(params) > 0 {
+		link += "?" + params.Encode()
+	}
+	return link
+}
+
+// DocumentoIDFromKey recupera el ID de una key histórica generada por DocumentoObjectKey;
+// devuelve "" si la key no tiene esa forma.
+func DocumentoIDFromKey(key string) string {
+	if !IsDocumentoKey(key) {
+		return ""
+	}
+	name := strings.TrimPrefix(key, BUCKET_KEY)
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
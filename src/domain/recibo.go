@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Estados de un documento: se registra pendiente de revisión y la administración lo
+// aprueba o lo rechaza.
+const (
+	EstadoPendiente = "pendiente"
+	EstadoAprobado  = "aprobado"
+	EstadoRechazado = "rechazado"
+)
+
+const recibosFolder = "recibos/"
+
+var ErrDocumentoNoAprobado = errors.New("el documento no está aprobado, no se puede emitir la constancia")
+
+// IsAprobado indica si el estado corresponde a un pago aprobado.
+func IsAprobado(estado string) bool {
+	return strings.EqualFold(strings.TrimSpace(estado), EstadoAprobado)
+}
+
+// IsPendiente indica si el documento espera revisión; los registrados sin
+// estado_documento también la esperan.
+func IsPendiente(estado string) bool {
+	estado = strings.TrimSpace(estado)
+	return estado == "" || strings.EqualFold(estado, EstadoPendiente)
+}
+
+// FormatNumeroRecibo da formato al correlativo de las constancias ("R-000042").
+func FormatNumeroRecibo(numero int64) string {
+	return fmt.Sprintf("R-%06d", numero)
+}
+
+// ReciboObjectKey es la key de la constancia de pago con ese número.
+func ReciboObjectKey(numero string) string {
+	return fmt.Sprintf("%s%s%s.pdf", BUCKET_KEY, recibosFolder, numero)
+}
+
+// Recibo contiene los datos que se imprimen en la constancia de pago.
+type Recibo struct {
+	Numero          string
+	Documento_ID    string
+	Departamento    string
+	Residente       string
+	FechaDePago     string
+	TipoDeServicio  string
+	Monto           string
+	AprobadoPor     string
+	FechaAprobacion string
+}
+
+// ToRecibo arma la constancia de un documento aprobado con el número asignado.
+func (res DocumentoResponse) ToRecibo(numero string) Recibo {
+	return Recibo{
+		Numero:          numero,
+		Documento_ID:    res.Documento_ID,
+		Departamento:    res.Departamento,
+		Residente:       res.Residente,
+		FechaDePago:     res.FechaDePago,
+		TipoDeServicio:  nombreServicio(res),
+		Monto:           formatMontoRecibo(res),
+		AprobadoPor:     res.AprobadoPor,
+		FechaAprobacion: res.FechaAprobacion,
+	}
+}
+
+// nombreServicio prefiere el nombre del catálogo al código guardado en el documento.
+func nombreServicio(res DocumentoResponse) string {
+	if res.NombreServicio != "" {
+		return res.NombreServicio
+	}
+	return res.TipoDeServicio
+}
+
+// formatMontoRecibo muestra el monto pagado y, en dólares con tipo de cambio, su
+// equivalente en soles. Los documentos sin monto dejan el campo vacío.
+func formatMontoRecibo(res DocumentoResponse) string {
+	if res.Monto == 0 || res.Moneda == "" {
+		return ""
+	}
+	monto := FormatMonto(res.Monto, res.Moneda)
+	if res.Moneda == MonedaUSD && res.TipoCambio != "" {
+		if soles, err := ConvertirAPEN(res.Monto, res.TipoCambio); err == nil {
+			monto += fmt.Sprintf(" (%s, T.C. %s)", FormatMonto(soles, MonedaPEN), res.TipoCambio)
+		}
+	}
+	return monto
+}
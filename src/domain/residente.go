@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"errors"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles de un residente en su departamento.
+const (
+	RolPropietario = "propietario"
+	RolInquilino   = "inquilino"
+)
+
+var (
+	ErrResidenteNotFound     = errors.New("residente no encontrado")
+	ErrResidenteInexistente  = errors.New("el residente no está registrado")
+	ErrResidenteEnUso        = errors.New("el residente tiene documentos registrados, registre su fecha de salida en lugar de eliminarlo")
+	ErrResidenteDepartamento = errors.New("el residente no pertenece al departamento del documento")
+	ErrResidenteInvalido     = errors.New("nombre y departamento son obligatorios y rol debe ser propietario o inquilino")
+	ErrResidenteEmail        = errors.New("email no es válido")
+	ErrResidenteFechas       = errors.New("las fechas deben tener formato AAAA-MM-DD y la salida no puede ser anterior al ingreso")
+)
+
+type ResidenteRequest struct {
+	Nombre             string `json:"nombre"`
+	DocumentoIdentidad string `json:"documento_identidad"`
+	Email              string `json:"email"`
+	Telefono           string `json:"telefono"`
+	Rol                string `json:"rol"`
+	Departamento       string `json:"departamento"`
+	CognitoSub         string `json:"cognito_sub"`
+	FechaIngreso       string `json:"fecha_ingreso"`
+	FechaSalida        string `json:"fecha_salida"`
+}
+
+// Residente es una persona que vive o es dueña de un departamento. CognitoSub enlaza
+// al usuario con el que inicia sesión.
+type Residente struct {
+	Residente_ID       string `dynamodbav:"id_residente" json:"id_residente"`
+	Nombre             string `dynamodbav:"nombre" json:"nombre"`
+	DocumentoIdentidad string `dynamodbav:"documento_identidad,omitempty" json:"documento_identidad"`
+	Email              string `dynamodbav:"email,omitempty" json:"email"`
+	Telefono           string `dynamodbav:"telefono,omitempty" json:"telefono"`
+	Rol                string `dynamodbav:"rol" json:"rol"`
+	Departamento       string `dynamodbav:"departamento" json:"departamento"`
+	CognitoSub         string `dynamodbav:"cognito_sub,omitempty" json:"cognito_sub"`
+	FechaIngreso       string `dynamodbav:"fecha_ingreso,omitempty" json:"fecha_ingreso"`
+	FechaSalida        string `dynamodbav:"fecha_salida,omitempty" json:"fecha_salida"`
+}
+
+type ResidenteResponse struct {
+	Residente_ID       string `json:"id_residente"`
+	Nombre             string `json:"nombre"`
+	DocumentoIdentidad string `json:"documento_identidad,omitempty"`
+	Email              string `json:"email,omitempty"`
+	Telefono           string `json:"telefono,omitempty"`
+	Rol                string `json:"rol"`
+	Departamento       string `json:"departamento"`
+	CognitoSub         string `json:"cognito_sub,omitempty"`
+	FechaIngreso       string `json:"fecha_ingreso,omitempty"`
+	FechaSalida        string `json:"fecha_salida,omitempty"`
+	Activo             bool   `json:"activo"`
+	Message            string `json:"message"`
+}
+
+// Validate comprueba los campos obligatorios, el email y las fechas de ingreso y salida.
+func (req *ResidenteRequest) Validate() error {
+	req.Nombre = strings.TrimSpace(req.Nombre)
+	req.Rol = strings.ToLower(strings.TrimSpace(req.Rol))
+	req.Departamento = NormalizeDepartamentoID(req.Departamento)
+	req.Email = strings.TrimSpace(req.Email)
+
+	if req.Nombre == "" || req.Departamento == "" || (req.Rol != RolPropietario && req.Rol != RolInquilino) {
+		return ErrResidenteInvalido
+	}
+	if req.Email != "" {
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			return ErrResidenteEmail
+		}
+	}
+
+	ingreso, errIngreso := parseFecha(req.FechaIngreso)
+	salida, errSalida := parseFecha(req.FechaSalida)
+	if errIngreso != nil || errSalida != nil {
+		return ErrResidenteFechas
+	}
+	if !ingreso.IsZero() && !salida.IsZero() && salida.Before(ingreso) {
+		return ErrResidenteFechas
+	}
+	return nil
+}
+
+func parseFecha(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// LogValue expone los campos como atributos para que el logger redacte los personales.
+func (req ResidenteRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("residente", req.Nombre),
+		slog.String("departamento", req.Departamento),
+		slog.String("rol", req.Rol),
+		slog.Bool("cognito", req.CognitoSub != ""),
+	)
+}
+
+func (req ResidenteRequest) ToResidente() Residente {
+	return Residente{
+		Residente_ID:       uuid.NewString(),
+		Nombre:             req.Nombre,
+		DocumentoIdentidad: req.DocumentoIdentidad,
+		Email:              req.Email,
+		Telefono:           req.Telefono,
+		Rol:                req.Rol,
+		Departamento:       req.Departamento,
+		CognitoSub:         req.CognitoSub,
+		FechaIngreso:       req.FechaIngreso,
+		FechaSalida:        req.FechaSalida,
+	}
+}
+
+// IsActivo indica si el residente vive en el departamento en la fecha dada: ya ingresó
+// y todavía no registró su salida.
+func (res Residente) IsActivo(fecha time.Time) bool {
+	dia := fecha.Format("2006-01-02")
+	if res.FechaIngreso != "" && res.FechaIngreso > dia {
+		return false
+	}
+	return res.FechaSalida == "" || res.FechaSalida >= dia
+}
+
+func (res Residente) ToResidenteResponse() ResidenteResponse {
+	return ResidenteResponse{
+		Residente_ID:       res.Residente_ID,
+		Nombre:             res.Nombre,
+		DocumentoIdentidad: res.DocumentoIdentidad,
+		Email:              res.Email,
+		Telefono:           res.Telefono,
+		Rol:                res.Rol,
+		Departamento:       res.Departamento,
+		CognitoSub:         res.CognitoSub,
+		FechaIngreso:       res.FechaIngreso,
+		FechaSalida:        res.FechaSalida,
+		Activo:             res.IsActivo(time.Now()),
+	}
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"errors"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Periodicidad con la que se factura un servicio del catálogo.
+const (
+	PeriodicidadMensual    = "mensual"
+	PeriodicidadBimestral  = "bimestral"
+	PeriodicidadTrimestral = "trimestral"
+	PeriodicidadSemestral  = "semestral"
+	PeriodicidadAnual      = "anual"
+	PeriodicidadUnica      = "unica"
+)
+
+// Base de cálculo del monto: fijo por departamento o por metro cuadrado de área.
+const (
+	BaseDepartamento = "departamento"
+	BaseM2           = "m2"
+)
+
+var (
+	ErrServicioNotFound    = errors.New("servicio no encontrado")
+	ErrServicioExists      = errors.New("el servicio ya está registrado")
+	ErrServicioEnUso       = errors.New("el servicio tiene documentos registrados, desactívelo en lugar de eliminarlo")
+	ErrServicioInvalido    = errors.New("codigo (letras, números y _) y nombre son obligatorios")
+	ErrServicioInexistente = errors.New("tipo_de_servicio no está en el catálogo")
+	ErrServicioInactivo    = errors.New("el servicio está desactivado")
+	ErrPeriodicidad        = errors.New("periodicidad debe ser mensual, bimestral, trimestral, semestral, anual o unica")
+	ErrBaseCalculo         = errors.New("base_calculo debe ser departamento o m2")
+)
+
+// Meses entre dos cobros de cada periodicidad; unica se cobra una sola vez.
+var periodicidades = map[string]int{
+	PeriodicidadMensual:    1,
+	PeriodicidadBimestral:  2,
+	PeriodicidadTrimestral: 3,
+	PeriodicidadSemestral:  6,
+	PeriodicidadAnual:      12,
+	PeriodicidadUnica:      0,
+}
+
+var codigoServicioPattern = regexp.MustCompile(`^[a-z0-9_]{2,40}$`)
+
+type ServicioRequest struct {
+	Codigo       string `json:"codigo"`
+	Nombre       string `json:"nombre"`
+	Periodicidad string `json:"periodicidad"`
+	MontoDefecto int64  `json:"monto_defecto"`
+	Moneda       string `json:"moneda"`
+	BaseCalculo  string `json:"base_calculo"`
+	Activo       *bool  `json:"activo"`
+}
+
+// Servicio es una entrada del catálogo de servicios. Su código es el valor que los
+// documentos guardan en tipo_de_servicio. MontoDefecto está en céntimos; con base m2
+// es el monto por metro cuadrado.
+type Servicio struct {
+	Codigo       string `dynamodbav:"codigo" json:"codigo"`
+	Nombre       string `dynamodbav:"nombre" json:"nombre"`
+	Periodicidad string `dynamodbav:"periodicidad" json:"periodicidad"`
+	MontoDefecto int64  `dynamodbav:"monto_defecto" json:"monto_defecto"`
+	Moneda       string `dynamodbav:"moneda" json:"moneda"`
+	BaseCalculo  string `dynamodbav:"base_calculo" json:"base_calculo"`
+	Activo       bool   `dynamodbav:"activo" json:"activo"`
+}
+
+type ServicioResponse struct {
+	Codigo       string `json:"codigo"`
+	Nombre       string `json:"nombre"`
+	Periodicidad string `json:"periodicidad"`
+	MontoDefecto int64  `json:"monto_defecto"`
+	Moneda       string `json:"moneda"`
+	BaseCalculo  string `json:"base_calculo"`
+	Activo       bool   `json:"activo"`
+	Message      string `json:"message"`
+}
+
+// NormalizeServicioCodigo pasa el código a minúsculas y cambia los espacios por _, para
+// que "Cuota Extraordinaria" y "cuota_extraordinaria" sean el mismo servicio.
+func NormalizeServicioCodigo(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), "_"))
+}
+
+// Validate comprueba el código, la periodicidad, la base de cálculo y el monto. Sin
+// periodicidad el servicio es mensual, sin base es por departamento y sin moneda es PEN.
+func (req *ServicioRequest) Validate() error {
+	req.Codigo = NormalizeServicioCodigo(req.Codigo)
+	req.Nombre = strings.TrimSpace(req.Nombre)
+	req.Periodicidad = strings.ToLower(strings.TrimSpace(req.Periodicidad))
+	req.BaseCalculo = strings.ToLower(strings.TrimSpace(req.BaseCalculo))
+	req.Moneda = strings.ToUpper(strings.TrimSpace(req.Moneda))
+
+	if !codigoServicioPattern.MatchString(req.Codigo) || req.Nombre == "" {
+		return ErrServicioInvalido
+	}
+	if req.Periodicidad == "" {
+		req.Periodicidad = PeriodicidadMensual
+	}
+	if _, ok := periodicidades[req.Periodicidad]; !ok {
+		return ErrPeriodicidad
+	}
+	if req.BaseCalculo == "" {
+		req.BaseCalculo = BaseDepartamento
+	}
+	if req.BaseCalculo != BaseDepartamento && req.BaseCalculo != BaseM2 {
+		return ErrBaseCalculo
+	}
+	if req.Moneda == "" {
+		req.Moneda = MonedaPEN
+	}
+	if _, ok := monedas[req.Moneda]; !ok {
+		return ErrMonedaInvalida
+	}
+	if req.MontoDefecto < 0 || req.MontoDefecto > maxMonto {
+		return ErrMontoInvalido
+	}
+	return nil
+}
+
+// LogValue expone los campos como atributos para que el logger redacte los personales.
+func (req ServicioRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("codigo", req.Codigo),
+		slog.String("periodicidad", req.Periodicidad),
+		slog.Int64("monto_defecto", req.MontoDefecto),
+		slog.String("base_calculo", req.BaseCalculo),
+	)
+}
+
+func (req ServicioRequest) ToServicio() Servicio {
+	return Servicio{
+		Codigo:       req.Codigo,
+		Nombre:       req.Nombre,
+		Periodicidad: req.Periodicidad,
+		MontoDefecto: req.MontoDefecto,
+		Moneda:       req.Moneda,
+		BaseCalculo:  req.BaseCalculo,
+		Activo:       req.Activo == nil || *req.Activo,
+	}
+}
+
+func (srv Servicio) ToServicioResponse() ServicioResponse {
+	return ServicioResponse{
+		Codigo:       srv.Codigo,
+		Nombre:       srv.Nombre,
+		Periodicidad: srv.Periodicidad,
+		MontoDefecto: srv.MontoDefecto,
+		Moneda:       srv.Moneda,
+		BaseCalculo:  srv.BaseCalculo,
+		Activo:       srv.Activo,
+	}
+}
+
+// MontoPara calcula lo que corresponde pagar a un departamento: el monto por defecto o,
+// con base m2, el monto por metro cuadrado multiplicado por el área, redondeado al céntimo.
+func (srv Servicio) MontoPara(dep Departamento) (int64, error) {
+	if srv.BaseCalculo != BaseM2 {
+		return srv.MontoDefecto, nil
+	}
+
+	// El área pasa por su representación decimal para no arrastrar el error del float
+	area, ok := new(big.Rat).SetString(strconv.FormatFloat(dep.Area, 'f', -1, 64))
+	if !ok || area.Sign() < 0 {
+		return 0, ErrDepartamentoInvalido
+	}
+	return redondear(new(big.Rat).Mul(new(big.Rat).SetInt64(srv.MontoDefecto), area))
+}
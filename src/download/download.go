@@ -0,0 +1,365 @@
+package download
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/src/domain"
+	"main/src/logger"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	READ_MAX_INLINE_BYTES = os.Getenv("READ_MAX_INLINE_BYTES")
+	READ_PRESIGN_TTL      = os.Getenv("READ_PRESIGN_TTL")
+	READ_CACHE_CONTROL    = os.Getenv("READ_CACHE_CONTROL")
+)
+
+const (
+	// Lambda admite respuestas de hasta 6 MB y el cuerpo viaja en base64 (+33 %)
+	defaultMaxInlineBytes = 4 << 20
+	defaultPresignTTL     = 5 * time.Minute
+	defaultCacheControl   = "private, max-age=300"
+
+	// Las keys direccionadas por contenido nunca cambian de contenido
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+var corsHeaders = map[string]string{
+	"Access-Control-Allow-Origin":   "*",
+	"Access-Control-Allow-Methods":  "DELETE,GET,HEAD,POST,PUT",
+	"Access-Control-Allow-Headers":  "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token,Range,If-None-Match,If-Modified-Since,If-Range",
+	"Access-Control-Expose-Headers": "Content-Range,Content-Length,Content-Disposition,ETag,Last-Modified,Accept-Ranges",
+}
+
+// Options define cómo se entrega el objeto al cliente.
+type Options struct {
+	// ContentDisposition se envía tal cual; vacío usa "attachment".
+	ContentDisposition string
+	// MaxInlineBytes es el tamaño máximo devuelto en el cuerpo; por encima se redirige a S3.
+	MaxInlineBytes int64
+	PresignTTL     time.Duration
+	// Private marca las respuestas que dependen de la autorización del usuario: ninguna
+	// caché compartida puede guardarlas, aunque el objeto sea inmutable.
+	Private bool
+}
+
+// DefaultOptions devuelve las opciones configuradas por entorno o sus valores por defecto.
+func DefaultOptions() Options {
+	opts := Options{
+		MaxInlineBytes: defaultMaxInlineBytes,
+		PresignTTL:     defaultPresignTTL,
+	}
+	if n, err := strconv.ParseInt(READ_MAX_INLINE_BYTES, 10, 64); err == nil && n > 0 {
+		opts.MaxInlineBytes = n
+	}
+	if ttl, err := time.ParseDuration(READ_PRESIGN_TTL); err == nil && ttl > 0 {
+		opts.PresignTTL = ttl
+	}
+	return opts
+}
+
+// Serve entrega un objeto del bucket respetando las cabeceras HTTP de la petición:
+// peticiones condicionales (304), rangos (206/416) y redirección a una URL prefirmada
+// cuando el contenido no cabe en una respuesta de Lambda.
+func Serve(ctx context.Context, s3client *s3.Client, request events.APIGatewayProxyRequest, key string, opts Options) (events.APIGatewayProxyResponse, error) {
+	log := logger.FromContext(ctx)
+
+	head, err := s3client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(domain.BUCKET_NAME),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return response(http.StatusNotFound, nil, "Object not found."), nil
+		}
+		log.Error("Error retrieving object metadata", "key", key, "error", err)
+		return response(http.StatusInternalServerError, nil, ""), err
+	}
+
+	size := head.ContentLength
+	etag := aws.ToString(head.ETag)
+	lastModified := aws.ToTime(head.LastModified)
+
+	headers := map[string]string{
+		"Accept-Ranges": "bytes",
+		"Cache-Control": cacheControl(key, aws.ToString(head.CacheControl), opts.Private),
+	}
+	if etag != "" {
+		headers["ETag"] = etag
+	}
+	if !lastModified.IsZero() {
+		headers["Last-Modified"] = lastModified.UTC().Format(http.TimeFormat)
+	}
+
+	if notModified(request, etag, lastModified) {
+		log.Info("Object not modified", "key", key)
+		return response(http.StatusNotModified, headers, ""), nil
+	}
+
+	// Un rango con If-Range que no coincide se ignora y se entrega el objeto completo
+	rangeHeader := Header(request.Headers, "Range")
+	if ifRange := Header(request.Headers, "If-Range"); ifRange != "" && !ifRangeMatches(ifRange, etag, lastModified) {
+		rangeHeader = ""
+	}
+
+	start, end, partial, ok := parseRange(rangeHeader, size)
+	if !ok {
+		headers["Content-Range"] = fmt.Sprintf("bytes */%d", size)
+		return response(http.StatusRequestedRangeNotSatisfiable, headers, ""), nil
+	}
+
+	contentType := aws.ToString(head.ContentType)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := opts.ContentDisposition
+	if disposition == "" {
+		disposition = "attachment"
+	}
+
+	// Demasiado grande para el cuerpo de la respuesta: el cliente lo descarga de S3
+	length := end - start + 1
+	if opts.MaxInlineBytes > 0 && length > opts.MaxInlineBytes {
+		url, err := presign(ctx, s3client, key, contentType, disposition, headers["Cache-Control"], opts.PresignTTL)
+		if err != nil {
+			log.Error("Error presigning object URL", "key", key, "error", err)
+			return response(http.StatusInternalServerError, nil, ""), err
+		}
+		log.Info("Redirecting to presigned URL", "key", key, "size", length)
+		return response(http.StatusFound, map[string]string{"Location": url, "Cache-Control": "no-store"}, ""), nil
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(domain.BUCKET_NAME),
+		Key:    aws.String(key),
+	}
+	if partial {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", start, end))
+	}
+	// La lectura falla si el objeto cambió desde el HEAD
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+
+	object, err := s3client.GetObject(ctx, input)
+	if err != nil {
+		log.Error("Error retrieving object", "key", key, "error", err)
+		return response(http.StatusInternalServerError, nil, ""), err
+	}
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		log.Error("Error reading object data", "key", key, "error", err)
+		return response(http.StatusInternalServerError, nil, ""), err
+	}
+
+	headers["Content-Type"] = contentType
+	headers["Content-Disposition"] = disposition
+	headers["Content-Length"] = strconv.Itoa(len(data))
+
+	status := http.StatusOK
+	if partial {
+		status = http.StatusPartialContent
+		headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/%d", start, end, size)
+	}
+
+	log.Info("Returning object", "key", key, "content_type", contentType, "status", status, "size", len(data))
+
+	resp := response(status, headers, base64.StdEncoding.EncodeToString(data))
+	resp.IsBase64Encoded = true
+	return resp, nil
+}
+
+// ContentDisposition arma la cabecera con el nombre original del archivo: se eliminan
+// rutas y caracteres de control, se incluye una versión ASCII en filename y el nombre
+// completo en UTF-8 en filename* (RFC 6266).
+func ContentDisposition(dispositionType, filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return dispositionType
+	}
+
+	fallback := strings.Map(func(r rune) rune {
+		if r > 0x7e {
+			return '_'
+		}
+		return r
+	}, name)
+
+	disposition := fmt.Sprintf("%s; filename=\"%s\"", dispositionType, fallback)
+	if fallback != name {
+		disposition += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return disposition
+}
+
+// encodeRFC5987 codifica con porcentajes todo lo que no sea attr-char.
+func encodeRFC5987(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Header devuelve una cabecera de la petición sin distinguir mayúsculas.
+func Header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// notModified evalúa If-None-Match y, solo si no viene, If-Modified-Since (RFC 9110).
+func notModified(request events.APIGatewayProxyRequest, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := Header(request.Headers, "If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+	if since, err := http.ParseTime(Header(request.Headers, "If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// etagMatches compara con una lista de ETags; la comparación débil ignora el prefijo W/.
+func etagMatches(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func ifRangeMatches(value, etag string, lastModified time.Time) bool {
+	if date, err := http.ParseTime(value); err == nil {
+		return !lastModified.IsZero() && lastModified.Truncate(time.Second).Equal(date)
+	}
+	// If-Range exige comparación fuerte
+	return !strings.HasPrefix(value, "W/") && value == etag
+}
+
+// parseRange interpreta un único rango "bytes=a-b", "bytes=a-" o "bytes=-n". Devuelve
+// partial=false para entregar el objeto completo (sin rango o con uno mal formado, que
+// se ignora) y ok=false si el rango no es satisfacible.
+func parseRange(value string, size int64) (start, end int64, partial, ok bool) {
+	full := func() (int64, int64, bool, bool) { return 0, max(size-1, 0), false, true }
+
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes=")
+	// Sin rango, otra unidad o varios rangos: se entrega el objeto completo
+	if !found || strings.Contains(spec, ",") || size == 0 {
+		return full()
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return full()
+	}
+
+	if first == "" {
+		// Sufijo: los últimos n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return full()
+		}
+		if n == 0 {
+			return 0, 0, false, false
+		}
+		return max(size-n, 0), size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return full()
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return full()
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, false, false
+	}
+	return start, end, true, true
+}
+
+func cacheControl(key, stored string, private bool) string {
+	value := defaultCacheControl
+	switch {
+	case stored != "":
+		value = stored
+	case domain.ChecksumFromKey(key) != "":
+		value = immutableCacheControl
+	case READ_CACHE_CONTROL != "":
+		value = READ_CACHE_CONTROL
+	}
+	if !private || strings.Contains(value, "private") || strings.Contains(value, "no-store") {
+		return value
+	}
+	if strings.Contains(value, "public") {
+		return strings.Replace(value, "public", "private", 1)
+	}
+	return "private, " + value
+}
+
+func presign(ctx context.Context, s3client *s3.Client, key, contentType, disposition, cache string, ttl time.Duration) (string, error) {
+	presigner := s3.NewPresignClient(s3client)
+	request, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(domain.BUCKET_NAME),
+		Key:                        aws.String(key),
+		ResponseContentType:        aws.String(contentType),
+		ResponseContentDisposition: aws.String(disposition),
+		ResponseCacheControl:       aws.String(cache),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+func response(status int, headers map[string]string, body string) events.APIGatewayProxyResponse {
+	all := map[string]string{}
+	for key, value := range corsHeaders {
+		all[key] = value
+	}
+	for key, value := range headers {
+		all[key] = value
+	}
+	return events.APIGatewayProxyResponse{StatusCode: status, Headers: all, Body: body}
+}
@@ -0,0 +1,248 @@
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"main/src/domain"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	// EXPORT_COLUMNS son las columnas por defecto de la exportación, separadas por comas.
+	EXPORT_COLUMNS = os.Getenv("EXPORT_COLUMNS")
+
+	// EXPORT_DATE_FORMAT es el layout de Go para las fechas cuando la solicitud no indica
+	// idioma (02/01/2006 por defecto).
+	EXPORT_DATE_FORMAT = os.Getenv("EXPORT_DATE_FORMAT")
+
+	// EXPORT_TIMEZONE es la zona horaria de las fechas con hora, como la de aprobación.
+	EXPORT_TIMEZONE = os.Getenv("EXPORT_TIMEZONE")
+)
+
+const (
+	defaultColumns    = "id_documento,departamento,residente,fecha_de_pago,tipo_de_servicio,monto,moneda,estado_documento,numero_recibo"
+	defaultDateFormat = "02/01/2006"
+	defaultTimezone   = "America/Lima"
+)
+
+// dateFormats relaciona el idioma de la solicitud con el formato de fecha.
+var dateFormats = map[string]string{
+	"es":  "02/01/2006",
+	"en":  "01/02/2006",
+	"iso": "2006-01-02",
+}
+
+var ErrColumna = errors.New("columna de exportación desconocida")
+
+// Column es una columna de la exportación de documentos.
+type Column struct {
+	Name    string
+	Numeric bool
+	value   func(domain.DocumentoResponse, Options) string
+}
+
+// Options son los ajustes de presentación de la exportación.
+type Options struct {
+	DateFormat string
+	Location   *time.Location
+}
+
+// documentColumns son las columnas disponibles, con el nombre del campo en la API. Los
+// montos salen en decimal ("1250.50") para que la hoja de cálculo los lea como números.
+var documentColumns = []Column{
+	{Name: "id_documento", value: func(d domain.DocumentoResponse, _ Options) string { return d.Documento_ID }},
+	{Name: "departamento", value: func(d domain.DocumentoResponse, _ Options) string { return d.Departamento }},
+	{Name: "residente", value: func(d domain.DocumentoResponse, _ Options) string { return d.Residente }},
+	{Name: "id_residente", value: func(d domain.DocumentoResponse, _ Options) string { return d.ResidenteID }},
+	{Name: "fecha_de_pago", value: func(d domain.DocumentoResponse, o Options) string { return o.date(d.FechaDePago) }},
+	{Name: "tipo_de_servicio", value: func(d domain.DocumentoResponse, _ Options) string { return d.TipoDeServicio }},
+	{Name: "nombre_servicio", value: func(d domain.DocumentoResponse, _ Options) string { return d.NombreServicio }},
+	{Name: "monto", Numeric: true, value: func(d domain.DocumentoResponse, _ Options) string { return amount(d.Monto, d.Moneda) }},
+	{Name: "moneda", value: func(d domain.DocumentoResponse, _ Options) string { return d.Moneda }},
+	{Name: "tipo_de_cambio", Numeric: true, value: func(d domain.DocumentoResponse, _ Options) string { return d.TipoCambio }},
+	{Name: "monto_aplicado", Numeric: true, value: func(d domain.DocumentoResponse, _ Options) string { return amount(d.MontoAplicado, d.Moneda) }},
+	{Name: "estado_documento", value: func(d domain.DocumentoResponse, _ Options) string { return d.StateDocument }},
+	{Name: "aprobado_por", value: func(d domain.DocumentoResponse, _ Options) string { return d.AprobadoPor }},
+	{Name: "fecha_aprobacion", value: func(d domain.DocumentoResponse, o Options) string { return o.timestamp(d.FechaAprobacion) }},
+	{Name: "numero_recibo", value: func(d domain.DocumentoResponse, _ Options) string { return d.NumeroRecibo }},
+	{Name: "estado_archivo", value: func(d domain.DocumentoResponse, _ Options) string { return d.EstadoArchivo }},
+	{Name: "duplicado_de", value: func(d domain.DocumentoResponse, _ Options) string { return d.DuplicadoDe }},
+	{Name: "file_key", value: func(d domain.DocumentoResponse, _ Options) string { return d.FileKey }},
+	{Name: "adjuntos", Numeric: true, value: func(d domain.DocumentoResponse, _ Options) string { return fmt.Sprint(len(d.Adjuntos)) }},
+}
+
+// Columns lee las columnas pedidas en ?columnas=, o las de EXPORT_COLUMNS.
+func Columns(request events.APIGatewayProxyRequest) ([]Column, error) {
+	names := request.QueryStringParameters["columnas"]
+	if names == "" {
+		names = EXPORT_COLUMNS
+	}
+	if names == "" {
+		names = defaultColumns
+	}
+
+	var columns []Column
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		column, ok := findColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrColumna, name)
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: la lista está vacía", ErrColumna)
+	}
+	return columns, nil
+}
+
+func findColumn(name string) (Column, bool) {
+	for _, column := range documentColumns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// OptionsFor elige el formato de fecha según ?idioma= o Accept-Language ("es-PE",
+// "en-US", "iso"); sin idioma reconocido usa EXPORT_DATE_FORMAT.
+func OptionsFor(request events.APIGatewayProxyRequest) Options {
+	options := Options{DateFormat: EXPORT_DATE_FORMAT, Location: location()}
+	if options.DateFormat == "" {
+		options.DateFormat = defaultDateFormat
+	}
+
+	languages := request.QueryStringParameters["idioma"]
+	if languages == "" {
+		languages = header(request, "Accept-Language")
+	}
+	for _, language := range strings.Split(languages, ",") {
+		// "es-PE;q=0.9" -> "es"
+		language, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(language)), ";")
+		language, _, _ = strings.Cut(language, "-")
+		if layout, ok := dateFormats[language]; ok {
+			options.DateFormat = layout
+			break
+		}
+	}
+	return options
+}
+
+func location() *time.Location {
+	name := EXPORT_TIMEZONE
+	if name == "" {
+		name = defaultTimezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// date cambia una fecha AAAA-MM-DD al formato de la exportación; si no puede leerla la
+// deja como está.
+func (o Options) date(value string) string {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return value
+	}
+	return t.Format(o.DateFormat)
+}
+
+// timestamp muestra una fecha RFC 3339 en la zona horaria de la exportación.
+func (o Options) timestamp(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.In(o.Location).Format(o.DateFormat + " 15:04")
+}
+
+func amount(monto int64, moneda string) string {
+	if monto == 0 && moneda == "" {
+		return ""
+	}
+	return domain.MontoDecimal(monto)
+}
+
+// DocumentWriter escribe documentos en CSV o XLSX a medida que llegan, así una
+// exportación grande no necesita tener todos los documentos en memoria.
+type DocumentWriter struct {
+	columns []Column
+	options Options
+	numeric []bool
+	csv     *csv.Writer
+	xlsx    *xlsxWriter
+	count   int
+}
+
+// NewDocumentWriter escribe la cabecera con los nombres de las columnas.
+func NewDocumentWriter(w io.Writer, format string, columns []Column, options Options) (*DocumentWriter, error) {
+	writer := &DocumentWriter{columns: columns, options: options}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+		writer.numeric = append(writer.numeric, column.Numeric)
+	}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		writer.csv = csv.NewWriter(w)
+		err = writer.csv.Write(header)
+	case FormatXLSX:
+		if writer.xlsx, err = newXLSXWriter(w, "documentos"); err == nil {
+			// La cabecera es texto aunque la columna sea numérica
+			err = writer.xlsx.Write(header, nil)
+		}
+	default:
+		err = fmt.Errorf("formato de exportación no soportado: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *DocumentWriter) Write(documento domain.DocumentoResponse) error {
+	row := make([]string, len(writer.columns))
+	for i, column := range writer.columns {
+		row[i] = column.value(documento, writer.options)
+		// Residente, departamento y demás textos del usuario no deben ejecutarse como fórmula
+		if !column.Numeric || !isNumber(row[i]) {
+			row[i] = domain.EscapeFormula(row[i])
+		}
+	}
+	writer.count++
+
+	if writer.xlsx != nil {
+		return writer.xlsx.Write(row, writer.numeric)
+	}
+	return writer.csv.Write(row)
+}
+
+// Count devuelve cuántos documentos se escribieron, sin contar la cabecera.
+func (writer *DocumentWriter) Count() int {
+	return writer.count
+}
+
+func (writer *DocumentWriter) Close() error {
+	if writer.xlsx != nil {
+		return writer.xlsx.Close()
+	}
+	writer.csv.Flush()
+	return writer.csv.Error()
+}
@@ -0,0 +1,75 @@
+// Package export decide en qué formato responder los reportes y listados que pueden
+// descargarse como archivo.
+package export
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Formatos de respuesta soportados.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+const (
+	ContentTypeCSV  = "text/csv; charset=utf-8"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Format elige el formato de la respuesta: el parámetro ?formato= tiene prioridad sobre
+// el encabezado Accept; sin ninguno de los dos se responde JSON.
+func Format(request events.APIGatewayProxyRequest) string {
+	switch strings.ToLower(request.QueryStringParameters["formato"]) {
+	case FormatCSV:
+		return FormatCSV
+	case FormatXLSX:
+		return FormatXLSX
+	case FormatJSON:
+		return FormatJSON
+	}
+
+	for _, value := range strings.Split(header(request, "Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return FormatCSV
+		case ContentTypeXLSX:
+			return FormatXLSX
+		case "application/json":
+			return FormatJSON
+		}
+	}
+	return FormatJSON
+}
+
+// ContentType devuelve el Content-Type de un formato de archivo.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return ContentTypeXLSX
+	}
+	return ContentTypeCSV
+}
+
+// Disposition arma el Content-Disposition para descargar la respuesta como archivo.
+func Disposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+// header busca un encabezado sin distinguir mayúsculas: API Gateway los entrega tal como
+// los envió el cliente.
+func header(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package extraction
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"main/src/domain"
+	"main/src/pdf"
+)
+
+var (
+	// EXTRACT_PDF desactiva la extracción con "false".
+	EXTRACT_PDF       = os.Getenv("EXTRACT_PDF")
+	EXTRACT_MAX_PAGES = os.Getenv("EXTRACT_MAX_PAGES")
+	EXTRACT_MAX_TEXT  = os.Getenv("EXTRACT_MAX_TEXT")
+	// EXTRACT_PATTERNS reemplaza los patrones de un campo, p. ej.
+	// {"numero_operacion": ["Operación:\\s*(\\d+)"]}. Se usa el grupo "valor" o el primero.
+	EXTRACT_PATTERNS = os.Getenv("EXTRACT_PATTERNS")
+)
+
+const (
+	defaultMaxPages = 5
+	// El texto se guarda en el ítem de DynamoDB, que no puede superar los 400 KB
+	defaultMaxText = 16 * 1024
+)
+
+// Campos que se intentan extraer del texto del comprobante.
+const (
+	NumeroOperacion = "numero_operacion"
+	Monto           = "monto"
+	Fecha           = "fecha"
+)
+
+// Patrones por defecto para las constancias de los bancos y billeteras más usados; se
+// prueban en orden y gana la primera coincidencia con un valor válido.
+var defaultPatterns = map[string][]string{
+	NumeroOperacion: {
+		`(?i)(?:n[°º.]|nro\.?|n[uú]mero)\s*(?:de\s+)?operaci[oó]n\s*[:#]?\s*(\d{4,20})`,
+		`(?i)operaci[oó]n\s*(?:n[°º.]|nro\.?|n[uú]mero)?\s*[:#]?\s*(\d{4,20})`,
+		`(?i)c[oó]digo\s+de\s+(?:operaci[oó]n|transacci[oó]n)\s*[:#]?\s*(\d{4,20})`,
+	},
+	Monto: {
+		`(?i)(?:monto|importe|total)(?:\s+(?:pagado|transferido|enviado))?\s*:?\s*(?:S/\.?|US\$|\$|PEN|USD)?\s*(\d[\d.,]*)`,
+		`(?i)(?:S/\.?|US\$|PEN|USD)\s*(\d[\d.,]*)`,
+	},
+	Fecha: {
+		`(\d{1,2}[/-]\d{1,2}[/-]\d{4})`,
+		`(\d{4}-\d{2}-\d{2})`,
+		`(?i)(\d{1,2}\s+(?:de\s+)?(?:ene|feb|mar|abr|may|jun|jul|ago|sep|set|oct|nov|dic)[a-z]*\.?\s+(?:de\s+)?\d{4})`,
+	},
+}
+
+// Extractor lee el PDF y aplica los patrones configurados a su texto.
+type Extractor struct {
+	patterns map[string][]*regexp.Regexp
+	maxPages int
+	maxText  int
+}
+
+// Enabled indica si la extracción de texto está activa.
+func Enabled() bool {
+	return EXTRACT_PDF != "false"
+}
+
+// New compila los patrones por defecto y los configurados en EXTRACT_PATTERNS.
+func New() (*Extractor, error) {
+	sources := map[string][]string{}
+	for field, patterns := range defaultPatterns {
+		sources[field] = patterns
+	}
+
+	if EXTRACT_PATTERNS != "" {
+		var custom map[string][]string
+		if err := json.Unmarshal([]byte(EXTRACT_PATTERNS), &custom); err != nil {
+			return nil, fmt.Errorf("EXTRACT_PATTERNS: %w", err)
+		}
+		for field, patterns := range custom {
+			if _, ok := defaultPatterns[field]; !ok {
+				return nil, fmt.Errorf("EXTRACT_PATTERNS: campo %q desconocido", field)
+			}
+			sources[field] = patterns
+		}
+	}
+
+	extractor := &Extractor{
+		patterns: map[string][]*regexp.Regexp{},
+		maxPages: envInt(EXTRACT_MAX_PAGES, defaultMaxPages),
+		maxText:  envInt(EXTRACT_MAX_TEXT, defaultMaxText),
+	}
+	for field, patterns := range sources {
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("EXTRACT_PATTERNS %s: %w", field, err)
+			}
+			extractor.patterns[field] = append(extractor.patterns[field], re)
+		}
+	}
+	return extractor, nil
+}
+
+// Extract lee el PDF y devuelve sus metadatos, el texto y los valores sugeridos.
+func (e *Extractor) Extract(data []byte) (domain.ExtraccionPDF, error) {
+	info, err := pdf.Read(data, e.maxPages)
+	if err != nil {
+		return domain.ExtraccionPDF{}, err
+	}
+
+	extraccion := domain.ExtraccionPDF{
+		Paginas:         info.Pages,
+		Productor:       info.Producer,
+		Creador:         info.Creator,
+		NumeroOperacion: e.find(NumeroOperacion, info.Text, normalizeOperacion),
+		Monto:           e.find(Monto, info.Text, NormalizeMonto),
+		Fecha:           e.find(Fecha, info.Text, NormalizeFecha),
+	}
+	extraccion.Texto, extraccion.TextoTruncado = truncate(info.Text, e.maxText)
+	return extraccion, nil
+}
+
+// find devuelve el primer valor que coincide con los patrones del campo y que la
+// función de normalización acepta.
+func (e *Extractor) find(field, text string, normalize func(string) (string, bool)) string {
+	for _, re := range e.patterns[field] {
+		for _, match := range re.FindAllStringSubmatch(text, -1) {
+			if value, ok := normalize(capture(re, match)); ok {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+func capture(re *regexp.Regexp, match []string) string {
+	if i := re.SubexpIndex("valor"); i > 0 {
+		return match[i]
+	}
+	if len(match) > 1 {
+		return match[1]
+	}
+	return match[0]
+}
+
+func normalizeOperacion(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	return value, value != ""
+}
+
+// NormalizeMonto convierte "1,250.50", "1.250,50" o "1250" en "1250.50". El último
+// separador seguido de uno o dos dígitos es el decimal; los demás son de miles.
+func NormalizeMonto(value string) (string, bool) {
+	value = strings.Trim(value, " .,")
+	if value == "" {
+		return "", false
+	}
+
+	integer, decimals := value, ""
+	if i := strings.LastIndexAny(value, ".,"); i >= 0 && len(value)-i-1 <= 2 {
+		integer, decimals = value[:i], value[i+1:]
+	}
+	integer = strings.NewReplacer(".", "", ",", "").Replace(integer)
+	for len(decimals) < 2 {
+		decimals += "0"
+	}
+
+	if _, err := strconv.ParseUint(integer+decimals, 10, 64); err != nil {
+		return "", false
+	}
+	integer = strings.TrimLeft(integer, "0")
+	if integer == "" {
+		integer = "0"
+	}
+	return integer + "." + decimals, true
+}
+
+var meses = map[string]time.Month{
+	"ene": time.January, "feb": time.February, "mar": time.March, "abr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "ago": time.August,
+	"sep": time.September, "set": time.September, "oct": time.October,
+	"nov": time.November, "dic": time.December,
+}
+
+var fechaTextual = regexp.MustCompile(`(?i)^(\d{1,2})\s+(?:de\s+)?([a-z]{3})[a-z]*\.?\s+(?:de\s+)?(\d{4})$`)
+
+// NormalizeFecha convierte "19/10/2023", "19-10-2023", "2023-10-19" o "19 de octubre de
+// 2023" al formato de fecha_de_pago, "2023-10-19". Los comprobantes locales usan día/mes.
+func NormalizeFecha(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range []string{"2006-01-02", "2/1/2006", "2-1-2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+
+	if m := fechaTextual.FindStringSubmatch(value); m != nil {
+		month, ok := meses[strings.ToLower(m[2])]
+		if !ok {
+			return "", false
+		}
+		day, _ := strconv.Atoi(m[1])
+		year, _ := strconv.Atoi(m[3])
+		t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		if t.Day() != day {
+			return "", false
+		}
+		return t.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+// truncate recorta el texto a max bytes sin partir un carácter UTF-8.
+func truncate(text string, max int) (string, bool) {
+	if len(text) <= max {
+		return text, false
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut], true
+}
+
+func envInt(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
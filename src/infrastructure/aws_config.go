@@ -0,0 +1,78 @@
+package infrastructure
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Variables de entorno para ajustar los clientes AWS sin recompilar.
+var (
+	AWS_MAX_ATTEMPTS    = os.Getenv("AWS_MAX_ATTEMPTS")
+	AWS_MAX_BACKOFF_MS  = os.Getenv("AWS_MAX_BACKOFF_MS")
+	AWS_HTTP_TIMEOUT_MS = os.Getenv("AWS_HTTP_TIMEOUT_MS")
+)
+
+var (
+	awsConfigMu     sync.Mutex
+	awsConfig       aws.Config
+	awsConfigLoaded bool
+)
+
+// GetAWSConfig carga la configuración compartida una sola vez por cold start.
+// Las invocaciones "warm" reutilizan la misma configuración y sus clientes. Un error
+// no se guarda: la siguiente invocación vuelve a intentarlo.
+func GetAWSConfig(ctx context.Context) (aws.Config, error) {
+	awsConfigMu.Lock()
+	defer awsConfigMu.Unlock()
+	if awsConfigLoaded {
+		return awsConfig, nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRetryer(newRetryer),
+		config.WithHTTPClient(newHTTPClient()),
+	)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	tracing.InstrumentAWS(&cfg)
+	awsConfig, awsConfigLoaded = cfg, true
+	return awsConfig, nil
+}
+
+func newRetryer() aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		if attempts := envInt(AWS_MAX_ATTEMPTS); attempts > 0 {
+			o.MaxAttempts = attempts
+		}
+		if backoff := envInt(AWS_MAX_BACKOFF_MS); backoff > 0 {
+			o.MaxBackoff = time.Duration(backoff) * time.Millisecond
+		}
+	})
+}
+
+func newHTTPClient() *awshttp.BuildableClient {
+	client := awshttp.NewBuildableClient()
+	if timeout := envInt(AWS_HTTP_TIMEOUT_MS); timeout > 0 {
+		client = client.WithTimeout(time.Duration(timeout) * time.Millisecond)
+	}
+	return client
+}
+
+func envInt(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return n
+}
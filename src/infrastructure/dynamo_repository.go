@@ -2,14 +2,25 @@ package infrastructure
 
 import (
 	"context"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"sync"
+
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
+var (
+	dynamoMu     sync.Mutex
+	dynamoClient *dynamodb.Client
+)
+
 func GetDynamoClient(ctx context.Context) (*dynamodb.Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return nil, err
+	dynamoMu.Lock()
+	defer dynamoMu.Unlock()
+	if dynamoClient == nil {
+		cfg, err := GetAWSConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		dynamoClient = dynamodb.NewFromConfig(cfg)
 	}
-	return dynamodb.NewFromConfig(cfg), nil
-}
\ No newline at end of file
+	return dynamoClient, nil
+}
@@ -0,0 +1,151 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"main/src/domain"
+	"main/src/logger"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// FileMessage es el mensaje SQS con el que los productores entregan un archivo a sqs_consumer.
+type FileMessage struct {
+	SourceKey    string `json:"source_key"`     // Key en el bucket donde el productor dejó el archivo
+	FileContents string `json:"file_contents"`  // Contenido en base64, solo en mensajes del formato anterior
+	FileName     string `json:"file_name"`      // Nombre real del archivo
+	RealFileName string `json:"real_file_name"` // ID del documento al que pertenece
+	ContentType  string `json:"content_type"`   // Tipo de contenido detectado por sus magic bytes
+	Key          string `json:"key"`            // Key destino en el bucket, si ya fue asignada
+	Principal    bool   `json:"principal"`      // Si es el archivo principal del documento
+}
+
+// IsPrincipal indica si el archivo es el principal del documento; los mensajes sin key
+// asignada son del formato anterior, que solo transportaba el archivo principal.
+func (m FileMessage) IsPrincipal() bool {
+	return m.Principal || m.Key == ""
+}
+
+// NewFileMessage arma el mensaje de un archivo ya subido con StageFile.
+func NewFileMessage(documentoID, fileName, contentType, key, sourceKey string) FileMessage {
+	return FileMessage{
+		SourceKey:    sourceKey,
+		FileName:     fileName,
+		RealFileName: documentoID,
+		ContentType:  contentType,
+		Key:          key,
+	}
+}
+
+// StageFile sube el archivo bajo incoming/ a la espera de sqs_consumer y devuelve su
+// key. Por la cola viaja solo esa key, así el tamaño del archivo no depende del límite
+// de 256 KiB de un mensaje SQS.
+func StageFile(ctx context.Context, bucket, contentType string, data []byte) (string, error) {
+	client, err := GetS3Client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	key := domain.IncomingObjectKey(domain.FileExtension(contentType))
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// DiscardStagedFiles borra de incoming/ los archivos de una subida que no llegó a
+// registrarse; las keys fuera de incoming/ se ignoran.
+func DiscardStagedFiles(ctx context.Context, bucket string, keys []string) error {
+	seen := map[string]bool{}
+	var objects []s3types.ObjectIdentifier
+	for _, key := range keys {
+		if domain.IsIncomingKey(key) && !seen[key] {
+			seen[key] = true
+			objects = append(objects, s3types.ObjectIdentifier{Key: aws.String(key)})
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	client, err := GetS3Client(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3types.Delete{Objects: objects, Quiet: true},
+	})
+	return err
+}
+
+// Máximo de mensajes que admite una llamada a SendMessageBatch.
+const maxBatchMessages = 10
+
+// SendFileMessages publica los mensajes de una subida en lotes de SendMessageBatch,
+// propagando el ID de correlación y la traza, y devuelve error si alguno no entró en la
+// cola. Los que sí entraron no se pueden retirar: el llamador deshace el documento o los
+// adjuntos y sqs_consumer los descarta.
+func SendFileMessages(ctx context.Context, queueURL string, messages []FileMessage) error {
+	client, err := GetSQSClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	var attributes map[string]types.MessageAttributeValue
+	if correlationID := logger.CorrelationID(ctx); correlationID != "" {
+		attributes = map[string]types.MessageAttributeValue{
+			logger.CorrelationAttribute: {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(correlationID),
+			},
+		}
+	}
+
+	for start := 0; start < len(messages); start += maxBatchMessages {
+		batch := messages[start:min(start+maxBatchMessages, len(messages))]
+		entries := make([]types.SendMessageBatchRequestEntry, len(batch))
+		for i, message := range batch {
+			body, err := json.Marshal(message)
+			if err != nil {
+				return err
+			}
+			entries[i] = types.SendMessageBatchRequestEntry{
+				Id:                      aws.String(strconv.Itoa(start + i)),
+				MessageBody:             aws.String(string(body)),
+				MessageAttributes:       attributes,
+				MessageSystemAttributes: tracing.MessageSystemAttributes(ctx),
+			}
+		}
+
+		output, err := client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+		// La llamada puede tener éxito aunque algunos mensajes hayan sido rechazados
+		if len(output.Failed) > 0 {
+			failed := output.Failed[0]
+			return fmt.Errorf("%d de %d mensajes no entraron en la cola: %s %s", len(output.Failed), len(batch),
+				aws.ToString(failed.Code), aws.ToString(failed.Message))
+		}
+	}
+	return nil
+}
@@ -2,15 +2,44 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
+	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	s3Mu     sync.Mutex
+	s3Client *s3.Client
 )
 
 func GetS3Client(ctx context.Context) (*s3.Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+	s3Mu.Lock()
+	defer s3Mu.Unlock()
+	if s3Client == nil {
+		cfg, err := GetAWSConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s3Client = s3.NewFromConfig(cfg)
+	}
+	return s3Client, nil
+}
+
+// ObjectExists indica si el objeto existe en el bucket.
+func ObjectExists(ctx context.Context, client *s3.Client, bucket, key string) (bool, error) {
+	_, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		return nil, err
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, err
 	}
-	return s3.NewFromConfig(cfg), nil
-}
\ No newline at end of file
+	return true, nil
+}
@@ -0,0 +1,26 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+var (
+	sqsMu     sync.Mutex
+	sqsClient *sqs.Client
+)
+
+func GetSQSClient(ctx context.Context) (*sqs.Client, error) {
+	sqsMu.Lock()
+	defer sqsMu.Unlock()
+	if sqsClient == nil {
+		cfg, err := GetAWSConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sqsClient = sqs.NewFromConfig(cfg)
+	}
+	return sqsClient, nil
+}
@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/google/uuid"
+)
+
+var (
+	LOG_LEVEL = os.Getenv("LOG_LEVEL")
+)
+
+const (
+	// Cabecera HTTP con la que el cliente puede enviar su propio ID de correlación.
+	CorrelationHeader = "X-Correlation-Id"
+	// Atributo de mensaje SQS que transporta el ID de correlación hasta el consumidor.
+	CorrelationAttribute = "correlation_id"
+
+	redacted = "[REDACTED]"
+)
+
+// Campos con datos personales de los residentes que nunca deben llegar a CloudWatch.
+var personalFields = map[string]bool{
+	"residente":           true,
+	"propietario":         true,
+	"ocupantes":           true,
+	"email":               true,
+	"telefono":            true,
+	"phone":               true,
+	"documento_identidad": true,
+	"file_contents":       true,
+	"file_name":           true,
+	"body":                true,
+	"authorization":       true,
+	"cookie":              true,
+}
+
+type contextKey struct{}
+
+type correlationKey struct{}
+
+// Init configura el logger JSON como logger por defecto del proceso.
+func Init() {
+	slog.SetDefault(New())
+}
+
+// New construye un logger JSON sobre stdout con el nivel indicado en LOG_LEVEL
+// y redacción automática de los campos personales.
+func New() *slog.Logger {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       parseLevel(LOG_LEVEL),
+		ReplaceAttr: redact,
+	})
+	return slog.New(handler)
+}
+
+// WithAPIGatewayRequest asocia al contexto un logger con el request ID de API Gateway
+// y el ID de correlación (recibido en la cabecera o generado a partir del request ID).
+func WithAPIGatewayRequest(ctx context.Context, request events.APIGatewayProxyRequest) (context.Context, *slog.Logger) {
+	requestID := request.RequestContext.RequestID
+	correlationID := header(request.Headers, CorrelationHeader)
+	if correlationID == "" {
+		correlationID = requestID
+	}
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+
+	log := slog.Default().With(
+		"request_id", requestID,
+		"correlation_id", correlationID,
+		"path", request.Path,
+		"method", request.HTTPMethod,
+	)
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		log = log.With("aws_request_id", lc.AwsRequestID)
+	}
+
+	ctx = context.WithValue(ctx, correlationKey{}, correlationID)
+	return context.WithValue(ctx, contextKey{}, log), log
+}
+
+// WithSQSMessage asocia al contexto un logger con el message ID de SQS y el ID de
+// correlación propagado como atributo del mensaje.
+func WithSQSMessage(ctx context.Context, message events.SQSMessage) (context.Context, *slog.Logger) {
+	correlationID := message.MessageId
+	if attr, ok := message.MessageAttributes[CorrelationAttribute]; ok && attr.StringValue != nil {
+		correlationID = *attr.StringValue
+	}
+
+	log := slog.Default().With(
+		"message_id", message.MessageId,
+		"correlation_id", correlationID,
+	)
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		log = log.With("aws_request_id", lc.AwsRequestID)
+	}
+
+	ctx = context.WithValue(ctx, correlationKey{}, correlationID)
+	return context.WithValue(ctx, contextKey{}, log), log
+}
+
+// FromContext devuelve el logger asociado al contexto o el logger por defecto.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if log, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return log
+		}
+	}
+	return slog.Default()
+}
+
+// CorrelationID devuelve el ID de correlación asociado al contexto.
+func CorrelationID(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(correlationKey{}).(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if personalFields[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	METRICS_NAMESPACE = os.Getenv("METRICS_NAMESPACE")
+)
+
+const (
+	defaultNamespace = "Residentes"
+	unknownDimension = "desconocido"
+	maxDimensionLen  = 64
+)
+
+type Unit string
+
+const (
+	Count        Unit = "Count"
+	Bytes        Unit = "Bytes"
+	Milliseconds Unit = "Milliseconds"
+)
+
+// Nombres de las métricas de negocio publicadas por el backend.
+const (
+	DocumentsCreated      = "DocumentsCreated"
+	DocumentsUpdated      = "DocumentsUpdated"
+	DocumentsDeleted      = "DocumentsDeleted"
+	StateTransitions      = "StateTransitions"
+	UploadBytes           = "UploadBytes"
+	SQSProcessingLatency  = "SQSProcessingLatency"
+	SQSProcessingFailures = "SQSProcessingFailures"
+	DuplicateUploads      = "DuplicateUploads"
+	ConvertedToPDF        = "ConvertedToPDF"
+	MalwareDetected       = "MalwareDetected"
+)
+
+// Dimensions agrupa las dimensiones de una métrica (p. ej. tipo_de_servicio).
+type Dimensions map[string]string
+
+var (
+	// Output es el destino de las líneas EMF; CloudWatch Logs las convierte en métricas.
+	Output io.Writer = os.Stdout
+	mu     sync.Mutex
+)
+
+type metricDefinition struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type metricDirective struct {
+	Namespace  string             `json:"Namespace"`
+	Dimensions [][]string         `json:"Dimensions"`
+	Metrics    []metricDefinition `json:"Metrics"`
+}
+
+type metadata struct {
+	Timestamp         int64             `json:"Timestamp"`
+	CloudWatchMetrics []metricDirective `json:"CloudWatchMetrics"`
+}
+
+// Emit escribe una línea en CloudWatch Embedded Metric Format con una sola métrica.
+func Emit(name string, value float64, unit Unit, dims Dimensions) {
+	keys := make([]string, 0, len(dims))
+	line := map[string]interface{}{}
+	for key, val := range dims {
+		keys = append(keys, key)
+		line[key] = safeDimension(val)
+	}
+	sort.Strings(keys)
+
+	namespace := METRICS_NAMESPACE
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	line["_aws"] = metadata{
+		Timestamp: time.Now().UnixMilli(),
+		CloudWatchMetrics: []metricDirective{{
+			Namespace:  namespace,
+			Dimensions: [][]string{keys},
+			Metrics:    []metricDefinition{{Name: name, Unit: unit}},
+		}},
+	}
+	line[name] = value
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	Output.Write(append(data, '\n'))
+}
+
+// Increment publica una métrica de conteo con valor 1.
+func Increment(name string, dims Dimensions) {
+	Emit(name, 1, Count, dims)
+}
+
+// DocumentDimensions construye las dimensiones seguras de un documento. El departamento
+// no es dimensión: es texto libre y cada valor distinto crearía una métrica nueva.
+func DocumentDimensions(tipoDeServicio string) Dimensions {
+	return Dimensions{
+		"tipo_de_servicio": tipoDeServicio,
+	}
+}
+
+// StateTransition publica el cambio de estado de un documento.
+func StateTransition(from, to string) {
+	Increment(StateTransitions, Dimensions{
+		"estado_anterior": from,
+		"estado_nuevo":    to,
+	})
+}
+
+// safeDimension normaliza los valores libres para acotar la cardinalidad.
+func safeDimension(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return unknownDimension
+	}
+	if len(value) > maxDimensionLen {
+		value = value[:maxDimensionLen]
+	}
+	return value
+}
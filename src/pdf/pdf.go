@@ -0,0 +1,218 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Tamaño A4 en puntos PDF (1/72 de pulgada).
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Document es un PDF mínimo de una o más páginas, suficiente para los archivos que
+// genera el sistema; no depende de librerías externas.
+type Document struct {
+	pages []*Page
+}
+
+// Font es una de las fuentes estándar de PDF, que los visores traen incorporadas.
+type Font string
+
+const (
+	Helvetica     Font = "F1"
+	HelveticaBold Font = "F2"
+)
+
+var fontNames = map[Font]string{
+	Helvetica:     "Helvetica",
+	HelveticaBold: "Helvetica-Bold",
+}
+
+// Page es una página con su contenido gráfico y las imágenes que referencia.
+type Page struct {
+	Width   float64
+	Height  float64
+	content bytes.Buffer
+	images  []jpegImage
+	text    bool
+}
+
+type jpegImage struct {
+	data   []byte
+	width  int
+	height int
+}
+
+// New crea un documento vacío.
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage agrega una página del tamaño indicado en puntos.
+func (d *Document) AddPage(width, height float64) *Page {
+	page := &Page{Width: width, Height: height}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// JPEG dibuja una imagen JPEG RGB de width×height píxeles en el rectángulo (x, y, w, h),
+// con el origen en la esquina inferior izquierda de la página.
+func (p *Page) JPEG(data []byte, width, height int, x, y, w, h float64) {
+	name := fmt.Sprintf("Im%d", len(p.images)+1)
+	p.images = append(p.images, jpegImage{data: data, width: width, height: height})
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, y, name)
+}
+
+// Text escribe una línea de texto con la línea base en (x, y). Los caracteres fuera de
+// Latin-1 se reemplazan por "?", ya que las fuentes estándar usan WinAnsiEncoding.
+func (p *Page) Text(x, y, size float64, font Font, c color.Color, text string) {
+	p.text = true
+	fmt.Fprintf(&p.content, "BT %s /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", fillColor(c), font, size, x, y, escape(text))
+}
+
+// Rect dibuja un rectángulo relleno con la esquina inferior izquierda en (x, y).
+func (p *Page) Rect(x, y, w, h float64, c color.Color) {
+	fmt.Fprintf(&p.content, "q %s %.2f %.2f %.2f %.2f re f Q\n", fillColor(c), x, y, w, h)
+}
+
+// Line dibuja un segmento del grosor indicado.
+func (p *Page) Line(x1, y1, x2, y2, width float64, c color.Color) {
+	r, g, b := rgb(c)
+	fmt.Fprintf(&p.content, "q %.3f %.3f %.3f RG %.2f w %.2f %.2f m %.2f %.2f l S Q\n", r, g, b, width, x1, y1, x2, y2)
+}
+
+// Bytes serializa el documento. La salida es determinista: el mismo contenido produce
+// siempre los mismos bytes, lo que permite direccionarlo por su checksum.
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		return nil, fmt.Errorf("el documento no tiene páginas")
+	}
+
+	w := &writer{}
+	w.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objetos 1 y 2: catálogo y árbol de páginas; el resto se numera en orden
+	catalog, pages := w.reserve(), w.reserve()
+
+	// Las fuentes se declaran una sola vez y las comparten todas las páginas
+	var fonts string
+	for _, page := range d.pages {
+		if page.text {
+			fonts = fmt.Sprintf("/Font << /%s %d 0 R /%s %d 0 R >> ",
+				Helvetica, w.object(fontObject(Helvetica)), HelveticaBold, w.object(fontObject(HelveticaBold)))
+			break
+		}
+	}
+
+	var kids []int
+	for _, page := range d.pages {
+		var xobjects bytes.Buffer
+		for i, img := range page.images {
+			ref := w.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode",
+				img.width, img.height), img.data)
+			fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, ref)
+		}
+		content := w.stream("", page.content.Bytes())
+
+		resources := "<< "
+		if page.text {
+			resources += fonts
+		}
+		if xobjects.Len() > 0 {
+			resources += "/XObject << " + xobjects.String() + ">> "
+		}
+		resources += ">>"
+
+		kids = append(kids, w.object(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			pages, page.Width, page.Height, resources, content)))
+	}
+
+	var refs bytes.Buffer
+	for _, kid := range kids {
+		fmt.Fprintf(&refs, "%d 0 R ", kid)
+	}
+	w.define(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", refs.String(), len(kids)))
+	w.define(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	return w.finish(catalog), nil
+}
+
+func fontObject(font Font) string {
+	return fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[font])
+}
+
+func fillColor(c color.Color) string {
+	r, g, b := rgb(c)
+	return fmt.Sprintf("%.3f %.3f %.3f rg", r, g, b)
+}
+
+func rgb(c color.Color) (float64, float64, float64) {
+	r, g, b, _ := c.RGBA()
+	return float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff
+}
+
+// escape codifica el texto en Latin-1 y escapa los delimitadores de cadenas PDF.
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// writer numera los objetos del PDF y guarda sus offsets para la tabla xref.
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *writer) reserve() int {
+	w.offsets = append(w.offsets, -1)
+	return len(w.offsets)
+}
+
+func (w *writer) object(body string) int {
+	id := w.reserve()
+	w.define(id, body)
+	return id
+}
+
+func (w *writer) define(id int, body string) {
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (w *writer) stream(dict string, data []byte) int {
+	id := w.reserve()
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", id, dict, len(data))
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return id
+}
+
+func (w *writer) finish(root int) []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, root, xref)
+	return w.buf.Bytes()
+}
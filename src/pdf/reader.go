@@ -0,0 +1,96 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	pdfreader "github.com/ledongthuc/pdf"
+)
+
+// Info resume un PDF subido: número de páginas, metadatos y el texto por líneas.
+type Info struct {
+	Pages    int
+	Producer string
+	Creator  string
+	Text     string
+}
+
+// Read extrae los metadatos y el texto de un PDF. Los comprobantes escaneados no tienen
+// capa de texto y devuelven Text vacío; solo se leen las primeras maxPages páginas.
+func Read(data []byte, maxPages int) (info Info, err error) {
+	// El lector entra en pánico con algunos archivos mal formados
+	defer func() {
+		if r := recover(); r != nil {
+			info, err = Info{}, fmt.Errorf("pdf: archivo ilegible: %v", r)
+		}
+	}()
+
+	reader, err := pdfreader.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Info{}, fmt.Errorf("pdf: %w", err)
+	}
+
+	info.Pages = reader.NumPage()
+	metadata := reader.Trailer().Key("Info")
+	info.Producer = strings.TrimSpace(metadata.Key("Producer").Text())
+	info.Creator = strings.TrimSpace(metadata.Key("Creator").Text())
+
+	var text strings.Builder
+	for i := 1; i <= info.Pages && (maxPages <= 0 || i <= maxPages); i++ {
+		for _, line := range lines(reader.Page(i).Content().Text) {
+			text.WriteString(line)
+			text.WriteByte('\n')
+		}
+	}
+	info.Text = text.String()
+	return info, nil
+}
+
+// lines agrupa los glifos en líneas por su coordenada Y y los ordena de arriba abajo y
+// de izquierda a derecha, separando con un espacio los que no están contiguos.
+func lines(glyphs []pdfreader.Text) []string {
+	type line struct {
+		y      float64
+		glyphs []pdfreader.Text
+	}
+	var grouped []*line
+
+	for _, glyph := range glyphs {
+		var current *line
+		for _, l := range grouped {
+			if math.Abs(l.y-glyph.Y) <= math.Max(glyph.FontSize, 1)/3 {
+				current = l
+				break
+			}
+		}
+		if current == nil {
+			current = &line{y: glyph.Y}
+			grouped = append(grouped, current)
+		}
+		current.glyphs = append(current.glyphs, glyph)
+	}
+
+	sort.SliceStable(grouped, func(i, j int) bool { return grouped[i].y > grouped[j].y })
+
+	var result []string
+	for _, l := range grouped {
+		sort.SliceStable(l.glyphs, func(i, j int) bool { return l.glyphs[i].X < l.glyphs[j].X })
+
+		var b strings.Builder
+		end := math.Inf(-1)
+		for _, glyph := range l.glyphs {
+			if b.Len() > 0 && glyph.X-end > math.Max(glyph.FontSize, 1)*0.2 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(glyph.S)
+			end = glyph.X + glyph.W
+		}
+		if text := strings.Join(strings.Fields(b.String()), " "); text != "" {
+			result = append(result, text)
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package preview
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"os"
+
+	"main/src/pdf"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	CONVERT_TO_PDF  = os.Getenv("CONVERT_TO_PDF")
+	CONVERT_MAX_PX  = os.Getenv("CONVERT_MAX_PX")
+	CONVERT_QUALITY = os.Getenv("CONVERT_QUALITY")
+)
+
+const (
+	// 2480 px es el lado mayor de un A4 a 300 ppp.
+	defaultConvertMaxPx   = 2480
+	defaultConvertQuality = 85
+
+	// Margen alrededor de la imagen en la página, en puntos.
+	pageMargin = 18
+)
+
+// ConvertEnabled indica si las fotos se convierten a PDF; está activo salvo que
+// CONVERT_TO_PDF sea "false".
+func ConvertEnabled() bool {
+	return CONVERT_TO_PDF != "false"
+}
+
+// IsConvertible indica si el tipo de contenido puede convertirse a PDF.
+func IsConvertible(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// ConvertOptions devuelve las opciones de conversión configuradas por entorno.
+func ConvertOptions() Options {
+	return Options{
+		MaxPx:   envInt(CONVERT_MAX_PX, defaultConvertMaxPx),
+		Quality: envInt(CONVERT_QUALITY, defaultConvertQuality),
+	}
+}
+
+// ToPDF envuelve una foto JPEG/PNG en un PDF de una página A4, con la orientación
+// EXIF corregida y la resolución limitada a opts.MaxPx.
+func ToPDF(data []byte, contentType string, opts Options) ([]byte, error) {
+	img, err := Decode(data, contentType, opts.MaxPx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fondo blanco para las transparencias PNG; el PDF recibe un JPEG RGB
+	bounds := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), img, bounds.Min, draw.Over)
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, canvas, &jpeg.Options{Quality: opts.Quality}); err != nil {
+		return nil, err
+	}
+
+	// La página sigue la orientación de la foto
+	pageWidth, pageHeight := pdf.A4Width, pdf.A4Height
+	if bounds.Dx() > bounds.Dy() {
+		pageWidth, pageHeight = pageHeight, pageWidth
+	}
+
+	// La imagen se ajusta al área útil conservando su proporción y se centra
+	areaWidth, areaHeight := pageWidth-2*pageMargin, pageHeight-2*pageMargin
+	scale := min(areaWidth/float64(bounds.Dx()), areaHeight/float64(bounds.Dy()))
+	width, height := float64(bounds.Dx())*scale, float64(bounds.Dy())*scale
+
+	doc := pdf.New()
+	page := doc.AddPage(pageWidth, pageHeight)
+	page.JPEG(encoded.Bytes(), bounds.Dx(), bounds.Dy(), (pageWidth-width)/2, (pageHeight-height)/2, width, height)
+	return doc.Bytes()
+}
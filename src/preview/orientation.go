@@ -0,0 +1,168 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+
+	"golang.org/x/image/webp"
+)
+
+var (
+	IMAGE_MAX_PIXELS = os.Getenv("IMAGE_MAX_PIXELS")
+)
+
+// Una foto de 48 MP ocupa unos 200 MB decodificada; más que eso no cabe en la memoria
+// de las lambdas.
+const defaultMaxPixels = 50_000_000
+
+var ErrTooLarge = errors.New("la imagen supera el máximo de píxeles permitido")
+
+// Decode decodifica una imagen JPEG/PNG/WebP reducida a maxPx y con la orientación EXIF
+// aplicada, de modo que las fotos tomadas con el teléfono girado queden derechas. Las
+// dimensiones se leen primero de la cabecera: una imagen pequeña en bytes puede declarar
+// millones de píxeles y agotar la memoria al decodificarla.
+func Decode(data []byte, contentType string, maxPx int) (image.Image, error) {
+	var decode func(r *bytes.Reader) (image.Image, error)
+	var decodeConfig func(r *bytes.Reader) (image.Config, error)
+	switch contentType {
+	case "image/jpeg":
+		decode = func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) }
+		decodeConfig = func(r *bytes.Reader) (image.Config, error) { return jpeg.DecodeConfig(r) }
+	case "image/png":
+		decode = func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) }
+		decodeConfig = func(r *bytes.Reader) (image.Config, error) { return png.DecodeConfig(r) }
+	case "image/webp":
+		decode = func(r *bytes.Reader) (image.Image, error) { return webp.Decode(r) }
+		decodeConfig = func(r *bytes.Reader) (image.Config, error) { return webp.DecodeConfig(r) }
+	default:
+		return nil, ErrUnsupported
+	}
+
+	config, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if limit := envInt(IMAGE_MAX_PIXELS, defaultMaxPixels); int64(config.Width)*int64(config.Height) > int64(limit) {
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "image/jpeg" {
+		return Resize(img, maxPx), nil
+	}
+	// Se reduce antes de rotar: rotar la foto original píxel a píxel es costoso
+	return Orient(Resize(img, maxPx), exifOrientation(data)), nil
+}
+
+// Orient aplica una de las ocho orientaciones EXIF (1 = sin cambios).
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	// Para cada píxel destino se calcula el píxel origen
+	source := func(x, y int) (int, int) {
+		switch orientation {
+		case 2: // espejo horizontal
+			return w - 1 - x, y
+		case 3: // rotación 180°
+			return w - 1 - x, h - 1 - y
+		case 4: // espejo vertical
+			return x, h - 1 - y
+		case 5: // transpuesta
+			return y, x
+		case 6: // rotación 90° horaria
+			return y, h - 1 - x
+		case 7: // transversa
+			return w - 1 - y, h - 1 - x
+		default: // 8: rotación 90° antihoraria
+			return w - 1 - y, x
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := source(x, y)
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// exifOrientation lee la etiqueta Orientation (0x0112) del segmento APP1 de un JPEG;
+// devuelve 1 si no existe o el EXIF está malformado.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// SOS: empiezan los datos de imagen, ya no hay más metadatos
+		if marker == 0xDA {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,143 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	THUMBNAIL_MAX_PX  = os.Getenv("THUMBNAIL_MAX_PX")
+	THUMBNAIL_QUALITY = os.Getenv("THUMBNAIL_QUALITY")
+)
+
+const (
+	defaultMaxPx   = 320
+	defaultQuality = 75
+
+	// ContentType es el tipo de contenido de todas las miniaturas generadas.
+	ContentType = "image/jpeg"
+)
+
+var ErrUnsupported = errors.New("tipo de archivo sin miniatura")
+
+// Options define el tamaño máximo del lado mayor y la calidad JPEG de la miniatura.
+type Options struct {
+	MaxPx   int
+	Quality int
+}
+
+// DefaultOptions devuelve las opciones configuradas por entorno o sus valores por defecto.
+func DefaultOptions() Options {
+	return Options{
+		MaxPx:   envInt(THUMBNAIL_MAX_PX, defaultMaxPx),
+		Quality: envInt(THUMBNAIL_QUALITY, defaultQuality),
+	}
+}
+
+// Thumbnail genera una miniatura JPEG del archivo. Las imágenes JPEG/PNG se orientan
+// según su EXIF y se reducen conservando la proporción; los PDF y HEIC, que no pueden
+// decodificarse en Go puro, reciben una imagen genérica de documento.
+func Thumbnail(data []byte, contentType string, opts Options) ([]byte, error) {
+	var img image.Image
+	var err error
+
+	switch contentType {
+	case "image/jpeg", "image/png":
+		img, err = Decode(data, contentType, opts.MaxPx)
+	case "application/pdf", "image/heic":
+		img = placeholder(opts.MaxPx, placeholderColors[contentType])
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(Resize(img, opts.MaxPx), opts.Quality)
+}
+
+// Resize reduce la imagen para que su lado mayor no supere maxPx; nunca la amplía.
+func Resize(img image.Image, maxPx int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxPx <= 0 || (width <= maxPx && height <= maxPx) {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxPx/width)
+		width = maxPx
+	} else {
+		width = max(1, width*maxPx/height)
+		height = maxPx
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, quality int) ([]byte, error) {
+	// JPEG no tiene transparencia: se compone sobre fondo blanco
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Color de la franja del placeholder según el tipo de archivo.
+var placeholderColors = map[string]color.RGBA{
+	"application/pdf": {R: 0xd3, G: 0x2f, B: 0x2f, A: 0xff},
+	"image/heic":      {R: 0x19, G: 0x76, B: 0xd2, A: 0xff},
+}
+
+// placeholder dibuja una hoja con la esquina doblada y líneas de texto simuladas.
+func placeholder(size int, accent color.RGBA) image.Image {
+	if size <= 0 {
+		size = defaultMaxPx
+	}
+	width, height := size*3/4, size
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	border := color.RGBA{R: 0xbd, G: 0xbd, B: 0xbd, A: 0xff}
+	line := color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: border}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(2, 2, width-2, height-2), image.White, image.Point{}, draw.Src)
+
+	// Esquina doblada
+	fold := width / 5
+	for y := 0; y < fold; y++ {
+		for x := width - fold + y; x < width; x++ {
+			img.Set(x, y, line)
+		}
+	}
+
+	// Franja con el color del tipo de archivo y renglones de texto
+	margin := width / 8
+	draw.Draw(img, image.Rect(margin, height/6, width-margin, height/6+height/12), &image.Uniform{C: accent}, image.Point{}, draw.Src)
+	for y := height / 3; y < height-margin; y += height / 12 {
+		draw.Draw(img, image.Rect(margin, y, width-margin, y+height/40+1), &image.Uniform{C: line}, image.Point{}, draw.Src)
+	}
+
+	return img
+}
+
+func envInt(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
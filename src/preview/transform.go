@@ -0,0 +1,192 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	TRANSFORM_MAX_PX = os.Getenv("TRANSFORM_MAX_PX")
+)
+
+const defaultTransformMaxPx = 2048
+
+// Modos de ajuste al recuadro width×height.
+const (
+	FitContain = "contain" // cabe dentro del recuadro, conserva la proporción
+	FitCover   = "cover"   // cubre el recuadro y recorta el sobrante centrado
+	FitFill    = "fill"    // ocupa el recuadro exacto, deforma si hace falta
+)
+
+var fits = map[string]bool{FitContain: true, FitCover: true, FitFill: true}
+
+// Formatos de salida; WebP solo se acepta como entrada porque no hay codificador en Go puro.
+var formats = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+}
+
+// Transform describe la variante pedida de una imagen.
+type Transform struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// ParseTransform lee width, height, fit, format y quality de los parámetros de la
+// petición. Devuelve ok=false si no se pidió ninguna transformación.
+func ParseTransform(params map[string]string) (t Transform, ok bool, err error) {
+	limit := envInt(TRANSFORM_MAX_PX, defaultTransformMaxPx)
+
+	dimension := func(name string) (int, error) {
+		value := params[name]
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > limit {
+			return 0, fmt.Errorf("%s debe ser un entero entre 1 y %d", name, limit)
+		}
+		return n, nil
+	}
+
+	if t.Width, err = dimension("width"); err != nil {
+		return Transform{}, false, err
+	}
+	if t.Height, err = dimension("height"); err != nil {
+		return Transform{}, false, err
+	}
+
+	t.Fit = params["fit"]
+	if t.Fit == "" {
+		t.Fit = FitContain
+	}
+	if !fits[t.Fit] {
+		return Transform{}, false, fmt.Errorf("fit debe ser contain, cover o fill")
+	}
+
+	t.Format = params["format"]
+	if t.Format == "jpg" {
+		t.Format = "jpeg"
+	}
+	if t.Format != "" && formats[t.Format] == "" {
+		return Transform{}, false, fmt.Errorf("format %q no soportado, use jpeg o png", t.Format)
+	}
+
+	t.Quality = defaultQuality
+	if value := params["quality"]; value != "" {
+		q, err := strconv.Atoi(value)
+		if err != nil || q < 1 || q > 100 {
+			return Transform{}, false, fmt.Errorf("quality debe ser un entero entre 1 y 100")
+		}
+		t.Quality = q
+	}
+
+	ok = t.Width > 0 || t.Height > 0 || t.Format != "" || params["quality"] != ""
+	if (t.Fit == FitCover || t.Fit == FitFill) && (t.Width == 0 || t.Height == 0) {
+		return Transform{}, false, fmt.Errorf("fit=%s requiere width y height", t.Fit)
+	}
+	return t, ok, nil
+}
+
+// ContentType devuelve el tipo de la imagen resultante; sin format se conserva el
+// original salvo WebP, que se entrega como JPEG.
+func (t Transform) ContentType(source string) string {
+	if t.Format != "" {
+		return formats[t.Format]
+	}
+	if source == "image/png" {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// Variant identifica la transformación en la key derivada, p. ej. "w320-h0-contain-q75.jpg".
+func (t Transform) Variant(source string) string {
+	return fmt.Sprintf("w%d-h%d-%s-q%d%s", t.Width, t.Height, t.Fit, t.Quality, extension(t.ContentType(source)))
+}
+
+// Apply decodifica la imagen (orientación EXIF incluida), la ajusta y la codifica. La
+// imagen se reduce a TRANSFORM_MAX_PX antes de orientarla y ajustarla: ninguna variante
+// supera ese tamaño y así no se rota la foto original a resolución completa.
+func (t Transform) Apply(data []byte, contentType string) ([]byte, error) {
+	img, err := Decode(data, contentType, envInt(TRANSFORM_MAX_PX, defaultTransformMaxPx))
+	if err != nil {
+		return nil, err
+	}
+
+	img = t.resize(img)
+
+	if t.ContentType(contentType) == "image/png" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return encode(img, t.Quality)
+}
+
+func (t Transform) resize(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if t.Width == 0 && t.Height == 0 {
+		return img
+	}
+
+	switch t.Fit {
+	case FitFill:
+		return scale(img, bounds, t.Width, t.Height)
+	case FitCover:
+		// Se recorta del original el área con la proporción del recuadro y luego se escala
+		ratio := float64(t.Width) / float64(t.Height)
+		cropWidth, cropHeight := width, int(float64(width)/ratio)
+		if cropHeight > height {
+			cropWidth, cropHeight = int(float64(height)*ratio), height
+		}
+		x := bounds.Min.X + (width-cropWidth)/2
+		y := bounds.Min.Y + (height-cropHeight)/2
+		crop := image.Rect(x, y, x+cropWidth, y+cropHeight)
+		return scale(img, crop, min(t.Width, cropWidth), min(t.Height, cropHeight))
+	}
+
+	// contain: el lado limitante manda y nunca se amplía
+	factor := 1.0
+	if t.Width > 0 {
+		factor = min(factor, float64(t.Width)/float64(width))
+	}
+	if t.Height > 0 {
+		factor = min(factor, float64(t.Height)/float64(height))
+	}
+	if factor >= 1 {
+		return img
+	}
+	return scale(img, bounds, max(1, int(float64(width)*factor)), max(1, int(float64(height)*factor)))
+}
+
+func scale(img image.Image, src image.Rectangle, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Over, nil)
+	return dst
+}
+
+func extension(contentType string) string {
+	if contentType == "image/png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// IsTransformable indica si el tipo de contenido puede decodificarse para transformarlo.
+func IsTransformable(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png" || contentType == "image/webp"
+}
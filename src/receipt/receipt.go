@@ -0,0 +1,183 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"os"
+	"time"
+	_ "time/tzdata"
+
+	"main/src/application"
+	"main/src/domain"
+	"main/src/infrastructure"
+	"main/src/logger"
+	"main/src/pdf"
+	"main/src/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var (
+	RECEIPT_ORG_NAME    = os.Getenv("RECEIPT_ORG_NAME")
+	RECEIPT_ORG_ADDRESS = os.Getenv("RECEIPT_ORG_ADDRESS")
+	RECEIPT_TIMEZONE    = os.Getenv("RECEIPT_TIMEZONE")
+)
+
+const (
+	defaultOrgName  = "Residentes"
+	defaultTimezone = "America/Lima"
+
+	ContentType = "application/pdf"
+)
+
+var (
+	brand = color.RGBA{R: 0x1f, G: 0x4e, B: 0x79, A: 0xff}
+	muted = color.RGBA{R: 0x6b, G: 0x72, B: 0x80, A: 0xff}
+	rule  = color.RGBA{R: 0xd1, G: 0xd5, B: 0xdb, A: 0xff}
+	black = color.Black
+	white = color.White
+)
+
+// Generate emite la constancia de pago de un documento aprobado: asigna su número,
+// renderiza el PDF, lo guarda en el bucket y lo enlaza al documento. Si la constancia
+// ya existe solo se vuelve a generar con force, conservando el mismo número.
+func Generate(ctx context.Context, service application.DocumentoService, id string, force bool) (response domain.DocumentoResponse, err error) {
+	ctx, end := tracing.Start(ctx, "receipt.Generate")
+	defer func() { end(err) }()
+	log := logger.FromContext(ctx)
+
+	documento, err := service.GetDocument(id)
+	if err != nil {
+		return domain.DocumentoResponse{}, err
+	}
+	if !domain.IsAprobado(documento.StateDocument) {
+		return domain.DocumentoResponse{}, domain.ErrDocumentoNoAprobado
+	}
+	if documento.ReciboKey != "" && !force {
+		return documento, nil
+	}
+
+	numero, err := service.AssignReceiptNumber(id)
+	if err != nil {
+		return domain.DocumentoResponse{}, err
+	}
+
+	data, err := Render(documento.ToRecibo(numero), time.Now())
+	if err != nil {
+		return domain.DocumentoResponse{}, err
+	}
+
+	s3client, err := infrastructure.GetS3Client(ctx)
+	if err != nil {
+		return domain.DocumentoResponse{}, err
+	}
+
+	key := domain.ReciboObjectKey(numero)
+	_, err = s3client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(domain.BUCKET_NAME),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(ContentType),
+	})
+	if err != nil {
+		return domain.DocumentoResponse{}, err
+	}
+	log.Info("Receipt stored", "id_documento", id, "numero_recibo", numero, "key", key)
+
+	return service.UpdateReceipt(id, key)
+}
+
+// Render dibuja la constancia de pago en una página A4.
+func Render(recibo domain.Recibo, emitido time.Time) ([]byte, error) {
+	location := timezone()
+	doc := pdf.New()
+	page := doc.AddPage(pdf.A4Width, pdf.A4Height)
+
+	const margin = 56.0
+	right := pdf.A4Width - margin
+	y := pdf.A4Height - margin
+
+	// Encabezado con el nombre de la organización
+	page.Rect(0, pdf.A4Height-120, pdf.A4Width, 120, brand)
+	page.Text(margin, y-20, 22, pdf.HelveticaBold, white, orgName())
+	if RECEIPT_ORG_ADDRESS != "" {
+		page.Text(margin, y-40, 10, pdf.Helvetica, white, RECEIPT_ORG_ADDRESS)
+	}
+
+	y = pdf.A4Height - 170
+	page.Text(margin, y, 18, pdf.HelveticaBold, black, "CONSTANCIA DE PAGO")
+	page.Text(right-150, y, 14, pdf.HelveticaBold, brand, "N° "+recibo.Numero)
+	y -= 16
+	page.Text(margin, y, 9, pdf.Helvetica, muted, "Documento "+recibo.Documento_ID)
+	y -= 18
+	page.Line(margin, y, right, y, 1, rule)
+
+	fields := []struct{ label, value string }{
+		{"Departamento", recibo.Departamento},
+		{"Residente", recibo.Residente},
+		{"Servicio", recibo.TipoDeServicio},
+		{"Fecha de pago", recibo.FechaDePago},
+		{"Monto", recibo.Monto},
+	}
+	y -= 30
+	for _, field := range fields {
+		page.Text(margin, y, 11, pdf.Helvetica, muted, field.label)
+		page.Text(margin+140, y, 12, pdf.HelveticaBold, black, valueOrDash(field.value))
+		y -= 26
+	}
+
+	y -= 4
+	page.Line(margin, y, right, y, 1, rule)
+	y -= 30
+	page.Text(margin, y, 11, pdf.HelveticaBold, black, "Aprobación")
+	y -= 22
+	page.Text(margin, y, 11, pdf.Helvetica, muted, "Aprobado por")
+	page.Text(margin+140, y, 11, pdf.Helvetica, black, valueOrDash(recibo.AprobadoPor))
+	y -= 20
+	page.Text(margin, y, 11, pdf.Helvetica, muted, "Fecha de aprobación")
+	page.Text(margin+140, y, 11, pdf.Helvetica, black, valueOrDash(localTime(recibo.FechaAprobacion, location)))
+
+	// Pie con la fecha de emisión
+	page.Line(margin, 90, right, 90, 0.5, rule)
+	page.Text(margin, 72, 9, pdf.Helvetica, muted, "Emitido el "+emitido.In(location).Format("02/01/2006 15:04")+
+		" - Constancia generada automáticamente, válida sin firma.")
+
+	return doc.Bytes()
+}
+
+// localTime convierte una fecha RFC 3339 a la zona horaria de las constancias.
+func localTime(value string, location *time.Location) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.In(location).Format("02/01/2006 15:04")
+}
+
+func timezone() *time.Location {
+	name := RECEIPT_TIMEZONE
+	if name == "" {
+		name = defaultTimezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+func orgName() string {
+	if RECEIPT_ORG_NAME == "" {
+		return defaultOrgName
+	}
+	return RECEIPT_ORG_NAME
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
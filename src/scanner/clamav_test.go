@@ -0,0 +1,245 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eicar es el archivo de prueba estándar que todo antivirus detecta como infectado.
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// readStream decodifica lo escrito por stream: el comando y los bloques hasta el de
+// longitud cero.
+func readStream(r io.Reader) (command string, chunks [][]byte, err error) {
+	reader := bufio.NewReader(r)
+
+	if command, err = reader.ReadString(0); err != nil {
+		return "", nil, err
+	}
+
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, size); err != nil {
+			return "", nil, err
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			return command, chunks, nil
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return "", nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestStreamFraming(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		chunks []int
+	}{
+		{name: "vacío", data: nil, chunks: nil},
+		{name: "un bloque", data: []byte("hola"), chunks: []int{4}},
+		{name: "bloque exacto", data: bytes.Repeat([]byte("a"), chunkSize), chunks: []int{chunkSize}},
+		{name: "varios bloques", data: bytes.Repeat([]byte("b"), 2*chunkSize+10), chunks: []int{chunkSize, chunkSize, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := stream(&buf, bytes.NewReader(tt.data)); err != nil {
+				t.Fatalf("stream: %v", err)
+			}
+
+			command, chunks, err := readStream(&buf)
+			if err != nil {
+				t.Fatalf("readStream: %v", err)
+			}
+			if command != "zINSTREAM\x00" {
+				t.Errorf("comando = %q, se esperaba zINSTREAM", command)
+			}
+			if len(chunks) != len(tt.chunks) {
+				t.Fatalf("%d bloques, se esperaban %d", len(chunks), len(tt.chunks))
+			}
+			for i, chunk := range chunks {
+				if len(chunk) != tt.chunks[i] {
+					t.Errorf("bloque %d: %d bytes, se esperaban %d", i, len(chunk), tt.chunks[i])
+				}
+			}
+			if got := bytes.Join(chunks, nil); !bytes.Equal(got, tt.data) {
+				t.Error("el contenido reensamblado no coincide con el original")
+			}
+			if buf.Len() != 0 {
+				t.Errorf("quedaron %d bytes después del bloque final", buf.Len())
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("lectura fallida")
+}
+
+func TestStreamReadError(t *testing.T) {
+	if err := stream(io.Discard, failingReader{}); err == nil {
+		t.Fatal("se esperaba el error del lector")
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{reply: "stream: OK\x00", infected: false},
+		{reply: "stream: OK\n", infected: false},
+		{reply: "stream: Eicar-Signature FOUND\x00", infected: true, signature: "Eicar-Signature"},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", infected: true, signature: "Win.Test.EICAR_HDB-1"},
+		{reply: "INSTREAM size limit exceeded. ERROR\x00", wantErr: true},
+		{reply: "stream: Can't allocate memory ERROR", wantErr: true},
+		{reply: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		result, err := parseReply(tt.reply)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseReply(%q): se esperaba error, se obtuvo %+v", tt.reply, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseReply(%q): %v", tt.reply, err)
+			continue
+		}
+		if result.Infected != tt.infected || result.Signature != tt.signature {
+			t.Errorf("parseReply(%q) = %+v, se esperaba infected=%v signature=%q", tt.reply, result, tt.infected, tt.signature)
+		}
+	}
+}
+
+// fakeClamd atiende conexiones INSTREAM en localhost y responde con reply(contenido).
+func fakeClamd(t *testing.T, reply func(data []byte) string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no se puede escuchar en localhost: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				_, chunks, err := readStream(conn)
+				if err != nil {
+					return
+				}
+				conn.Write([]byte(reply(bytes.Join(chunks, nil)) + "\x00"))
+			}(conn)
+		}
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestScanDecision(t *testing.T) {
+	address := fakeClamd(t, func(data []byte) string {
+		switch {
+		case strings.Contains(string(data), "EICAR"):
+			return "stream: Eicar-Signature FOUND"
+		case len(data) > 100:
+			return "INSTREAM size limit exceeded. ERROR"
+		}
+		return "stream: OK"
+	})
+	clamav := NewClamAV(address, 5*time.Second)
+
+	tests := []struct {
+		name   string
+		data   string
+		action Action
+	}{
+		{name: "limpio", data: "comprobante", action: Store},
+		{name: "infectado", data: eicar, action: Quarantine},
+		{name: "límite de tamaño", data: strings.Repeat("x", 200), action: Retry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := clamav.Scan(context.Background(), strings.NewReader(tt.data))
+			if got := Decide(result, err); got != tt.action {
+				t.Errorf("Decide = %v, se esperaba %v (result=%+v, err=%v)", got, tt.action, result, err)
+			}
+		})
+	}
+}
+
+func TestScanUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no se puede escuchar en localhost: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	result, err := NewClamAV(address, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	if err == nil {
+		t.Fatal("se esperaba error con clamd caído")
+	}
+	if Decide(result, err) != Retry {
+		t.Error("un antivirus caído debe reintentar, nunca aceptar el archivo")
+	}
+}
+
+func TestDecide(t *testing.T) {
+	if Decide(Result{}, nil) != Store {
+		t.Error("un archivo limpio debe guardarse")
+	}
+	if Decide(Result{Infected: true, Signature: "x"}, nil) != Quarantine {
+		t.Error("un archivo infectado debe ir a cuarentena")
+	}
+	if Decide(Result{Infected: true}, errors.New("clamd")) != Retry {
+		t.Error("con error el mensaje debe reintentarse")
+	}
+}
+
+// TestClamd analiza el archivo EICAR con un clamd real; se ejecuta solo si
+// CLAMD_TEST_ADDRESS apunta a uno (p. ej. docker run -p 3310:3310 clamav/clamav).
+func TestClamd(t *testing.T) {
+	address := os.Getenv("CLAMD_TEST_ADDRESS")
+	if address == "" {
+		t.Skip("CLAMD_TEST_ADDRESS no está configurada")
+	}
+	clamav := NewClamAV(address, 30*time.Second)
+
+	result, err := clamav.Scan(context.Background(), strings.NewReader("comprobante de pago"))
+	if err != nil || result.Infected {
+		t.Fatalf("archivo limpio: result=%+v err=%v", result, err)
+	}
+
+	result, err = clamav.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil {
+		t.Fatalf("EICAR: %v", err)
+	}
+	if !result.Infected || Decide(result, err) != Quarantine {
+		t.Fatalf("EICAR no fue detectado: %+v", result)
+	}
+}
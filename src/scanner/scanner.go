@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// SCANNER elige el adaptador: "clamav" o "none" (por defecto).
+	SCANNER            = os.Getenv("SCANNER")
+	CLAMD_ADDRESS      = os.Getenv("CLAMD_ADDRESS")
+	SCANNER_TIMEOUT_MS = os.Getenv("SCANNER_TIMEOUT_MS")
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+
+	ClamAV = "clamav"
+	None   = "none"
+)
+
+// Result es el veredicto del análisis de un archivo.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Action es lo que se hace con un archivo según el resultado del análisis.
+type Action int
+
+const (
+	// Store guarda el archivo limpio en BUCKET_KEY.
+	Store Action = iota
+	// Quarantine aísla el archivo infectado y rechaza el documento.
+	Quarantine
+	// Retry devuelve el mensaje a la cola: sin veredicto el archivo no se acepta.
+	Retry
+)
+
+// Decide traduce el resultado de Scan en la acción sobre el archivo. Un error nunca
+// equivale a un archivo limpio.
+func Decide(result Result, err error) Action {
+	switch {
+	case err != nil:
+		return Retry
+	case result.Infected:
+		return Quarantine
+	}
+	return Store
+}
+
+// Scanner analiza el contenido de un archivo subido antes de aceptarlo.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// New devuelve el adaptador configurado en SCANNER.
+func New() (Scanner, error) {
+	switch SCANNER {
+	case "", None:
+		return NoOp{}, nil
+	case ClamAV:
+		if CLAMD_ADDRESS == "" {
+			return nil, fmt.Errorf("SCANNER=clamav requiere CLAMD_ADDRESS")
+		}
+		return NewClamAV(CLAMD_ADDRESS, timeout()), nil
+	}
+	return nil, fmt.Errorf("scanner %q no soportado, use clamav o none", SCANNER)
+}
+
+// NoOp acepta todos los archivos; se usa cuando no hay un antivirus desplegado.
+type NoOp struct{}
+
+func (NoOp) Name() string {
+	return None
+}
+
+func (NoOp) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
+
+func timeout() time.Duration {
+	ms, err := strconv.Atoi(SCANNER_TIMEOUT_MS)
+	if err != nil || ms <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/aws-xray-sdk-go/header"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// Atributo de sistema de SQS con el que X-Ray enlaza el productor y el consumidor.
+const TraceHeaderAttribute = "AWSTraceHeader"
+
+// InstrumentAWS registra el middleware de X-Ray en todos los clientes creados con cfg.
+func InstrumentAWS(cfg *aws.Config) {
+	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+}
+
+// Start abre un subsegmento con el nombre indicado; end debe llamarse con el error final.
+func Start(ctx context.Context, name string) (context.Context, func(error)) {
+	ctx, seg := xray.BeginSubsegment(ctx, name)
+	return ctx, seg.Close
+}
+
+// Annotate agrega una anotación indexable al subsegmento actual.
+func Annotate(ctx context.Context, key string, value interface{}) {
+	if xray.GetSegment(ctx) != nil {
+		xray.AddAnnotation(ctx, key, value)
+	}
+}
+
+// TraceHeader devuelve la cabecera X-Ray para propagar la traza a servicios posteriores.
+func TraceHeader(ctx context.Context) string {
+	seg := xray.GetSegment(ctx)
+	if seg == nil {
+		return ""
+	}
+	return seg.DownstreamHeader().String()
+}
+
+// MessageSystemAttributes devuelve los atributos de sistema de SQS que propagan la traza.
+func MessageSystemAttributes(ctx context.Context) map[string]types.MessageSystemAttributeValue {
+	header := TraceHeader(ctx)
+	if header == "" {
+		return nil
+	}
+	return map[string]types.MessageSystemAttributeValue{
+		TraceHeaderAttribute: {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(header),
+		},
+	}
+}
+
+// StartMessage abre el segmento de un mensaje SQS. Si el productor propagó su traza en
+// AWSTraceHeader, el segmento continúa esa traza (mismo TraceID, con el segmento del
+// productor como padre) para ver la solicitud de punta a punta; si no, es un subsegmento
+// de la invocación.
+func StartMessage(ctx context.Context, message events.SQSMessage) (context.Context, func(error)) {
+	upstream := message.Attributes[TraceHeaderAttribute]
+	if upstream == "" {
+		ctx, end := Start(ctx, "sqs_message")
+		Annotate(ctx, "message_id", message.MessageId)
+		return ctx, end
+	}
+
+	h := header.FromString(upstream)
+	name := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if name == "" {
+		name = "sqs_message"
+	}
+	// La solicitud solo sirve para que el SDK respete la decisión de muestreo del productor
+	request := &http.Request{Method: http.MethodPost, Host: "sqs", URL: &url.URL{Path: "/" + name}}
+	ctx, seg := xray.NewSegmentFromHeader(ctx, name, request, h)
+	Annotate(ctx, "message_id", message.MessageId)
+	return ctx, seg.Close
+}
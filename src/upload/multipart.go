@@ -0,0 +1,273 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"main/src/domain"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	UPLOAD_MAX_FILE_BYTES  = os.Getenv("UPLOAD_MAX_FILE_BYTES")
+	UPLOAD_MAX_TOTAL_BYTES = os.Getenv("UPLOAD_MAX_TOTAL_BYTES")
+)
+
+const (
+	defaultMaxFileBytes  = 5 << 20
+	defaultMaxTotalBytes = 6 << 20
+	defaultMaxFieldBytes = 64 << 10
+
+	// Bytes necesarios para identificar el tipo de contenido por sus "magic bytes".
+	sniffLen = 512
+)
+
+// Tipos de contenido aceptados en las subidas de comprobantes.
+const (
+	ContentTypePDF  = "application/pdf"
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypePNG  = "image/png"
+	ContentTypeHEIC = "image/heic"
+)
+
+var allowedContentTypes = map[string]bool{
+	ContentTypePDF:  true,
+	ContentTypeJPEG: true,
+	ContentTypePNG:  true,
+	ContentTypeHEIC: true,
+}
+
+// Limits define los tamaños máximos aceptados por el parser.
+type Limits struct {
+	MaxFileBytes  int64
+	MaxTotalBytes int64
+	MaxFieldBytes int64
+}
+
+// DefaultLimits devuelve los límites configurados por entorno o sus valores por defecto.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxFileBytes:  envBytes(UPLOAD_MAX_FILE_BYTES, defaultMaxFileBytes),
+		MaxTotalBytes: envBytes(UPLOAD_MAX_TOTAL_BYTES, defaultMaxTotalBytes),
+		MaxFieldBytes: defaultMaxFieldBytes,
+	}
+}
+
+// File es un archivo recibido en una parte "file" del formulario.
+type File struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// Size devuelve el tamaño del archivo en bytes.
+func (f File) Size() int64 {
+	return int64(len(f.Data))
+}
+
+// Extension devuelve la extensión canónica según el contenido real del archivo.
+func (f File) Extension() string {
+	return Extension(f.ContentType)
+}
+
+// Form contiene los campos de texto y los archivos de un formulario multipart.
+type Form struct {
+	Fields map[string]string
+	Files  []File
+}
+
+// Value devuelve el valor del campo de texto indicado.
+func (f *Form) Value(name string) string {
+	return f.Fields[name]
+}
+
+// Error es un error del parser con el código HTTP que debe devolverse al cliente.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(status int, format string, args ...interface{}) *Error {
+	return &Error{StatusCode: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// StatusCode devuelve el código HTTP asociado a err (500 si no es un error del parser).
+func StatusCode(err error) int {
+	var uploadErr *Error
+	if errors.As(err, &uploadErr) {
+		return uploadErr.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// Parse lee el cuerpo multipart de una petición de API Gateway parte por parte,
+// aplicando los límites de tamaño y validando el tipo real de cada archivo.
+func Parse(request events.APIGatewayProxyRequest, limits Limits) (*Form, error) {
+	contentType := header(request.Headers, "Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, newError(http.StatusUnsupportedMediaType, "content type %q is not multipart/form-data", contentType)
+	}
+	if params["boundary"] == "" {
+		return nil, newError(http.StatusBadRequest, "multipart boundary is missing")
+	}
+
+	var body io.Reader = strings.NewReader(request.Body)
+	if request.IsBase64Encoded {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	total := &limitedReader{r: body, max: limits.MaxTotalBytes}
+
+	form := &Form{Fields: map[string]string{}}
+	reader := multipart.NewReader(total, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if total.exceeded {
+				return nil, newError(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", limits.MaxTotalBytes)
+			}
+			return nil, newError(http.StatusBadRequest, "malformed multipart body: %s", err)
+		}
+
+		if part.FileName() == "" {
+			value, err := readPart(part, limits.MaxFieldBytes, total)
+			if err != nil {
+				return nil, err
+			}
+			form.Fields[part.FormName()] = string(value)
+			continue
+		}
+
+		data, err := readPart(part, limits.MaxFileBytes, total)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, newError(http.StatusBadRequest, "file %q is empty", part.FileName())
+		}
+
+		detected := DetectContentType(data)
+		if !allowedContentTypes[detected] {
+			return nil, newError(http.StatusUnsupportedMediaType, "file %q has unsupported content type %s", part.FileName(), detected)
+		}
+
+		form.Files = append(form.Files, File{
+			FieldName:   part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: detected,
+			Data:        data,
+		})
+	}
+
+	return form, nil
+}
+
+// DetectContentType identifica el tipo de contenido a partir de los primeros bytes.
+func DetectContentType(data []byte) string {
+	head := data
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	if isHEIC(head) {
+		return ContentTypeHEIC
+	}
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return detected
+}
+
+// Extension devuelve la extensión canónica de un tipo de contenido aceptado.
+func Extension(contentType string) string {
+	return domain.FileExtension(contentType)
+}
+
+// IsAllowed indica si el tipo de contenido está en la lista permitida.
+func IsAllowed(contentType string) bool {
+	return allowedContentTypes[contentType]
+}
+
+// isHEIC reconoce la caja "ftyp" de los contenedores HEIF/HEIC, que net/http no detecta.
+func isHEIC(head []byte) bool {
+	if len(head) < 12 || !bytes.Equal(head[4:8], []byte("ftyp")) {
+		return false
+	}
+	switch string(head[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+func readPart(part *multipart.Part, max int64, total *limitedReader) ([]byte, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(part, max+1))
+	if err != nil {
+		if total.exceeded {
+			return nil, newError(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", total.max)
+		}
+		return nil, newError(http.StatusBadRequest, "error reading part %q: %s", part.FormName(), err)
+	}
+	if n > max {
+		return nil, newError(http.StatusRequestEntityTooLarge, "part %q exceeds %d bytes", part.FormName(), max)
+	}
+	return buf.Bytes(), nil
+}
+
+// limitedReader corta la lectura al superar el tamaño total permitido.
+type limitedReader struct {
+	r        io.Reader
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		l.exceeded = true
+		return 0, errors.New("request body too large")
+	}
+	if left := l.max + 1 - l.read; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		l.exceeded = true
+		return n, errors.New("request body too large")
+	}
+	return n, err
+}
+
+func header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+func envBytes(value string, fallback int64) int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}